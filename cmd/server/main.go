@@ -1,359 +1,211 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
-	"time"
+	"os/signal"
+	"syscall"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 
-	"github.com/hperssn/hound/internal/domain"
-	httpapi "github.com/hperssn/hound/internal/http"
+	"github.com/hperssn/hound/internal/config"
+	"github.com/hperssn/hound/internal/logging"
+	"github.com/hperssn/hound/internal/metrics"
+	"github.com/hperssn/hound/internal/mqtt"
+	"github.com/hperssn/hound/internal/push"
 	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/scheduler"
+	"github.com/hperssn/hound/internal/server"
 	"github.com/hperssn/hound/internal/storage"
-)
-
-const (
-	TargetGreat = 1.20
-	TargetOK    = 1.15
-	TargetFail  = 0.90
+	"github.com/hperssn/hound/internal/tracing"
+	"github.com/hperssn/hound/internal/webhook"
 )
 
 func main() {
-
-	repo, err := initRepository()
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
 	if err != nil {
-		log.Fatal("failed to initialize database:", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	defer repo.Close()
-	manager := runner.NewSessionManager()
-
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-
-	r.Get("/", serveIndex)
-	r.Get("/health", healthCheck)
-	fs := http.FileServer(http.Dir("./static"))
-	r.Handle("/static/*", http.StripPrefix("/static/", fs))
-
-	r.Group(func(r chi.Router) {
-		r.Use(ExtractUserMiddleware)
 
-		r.Post("/sessions", startSession(manager, repo))
-		r.Get("/sessions/{id}", getSession(manager))
-		r.Post("/sessions/{id}/complete", completeSession(manager, repo))
-		r.Post("/sessions/{id}/steps/{idx}/start", startStep(manager))
-		r.Post("/sessions/{id}/steps/{idx}/stop", stopStep(manager))
-		r.Post("/sessions/{id}/stop", stopSession(manager))
-		r.Get("/sessions/{id}/events", httpapi.StreamSessionEvents(manager))
-		r.Get("/sessions/{id}/status", getSessionStatus(manager))
-		r.Get("/next-target", getNextTarget(repo))
-
-		r.Get("/history", getHistory(repo))
-		r.Get("/stats", getStats(repo))
-
-	})
-
-	log.Println("listening on :8080")
-	if err := http.ListenAndServe(":8080", r); err != nil {
-		log.Fatal(err)
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-}
+	slog.SetDefault(logger)
 
-func healthCheck(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
-}
+	prom := metrics.NewPrometheus()
 
-func serveIndex(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "./static/index.html")
-}
-
-func initRepository() (storage.Repository, error) {
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL != "" {
-		log.Println("Using Postgres database")
-		return storage.NewPostgresRepository(dbURL)
+	tp, shutdownTracing, err := tracing.NewProvider(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to initialize tracing", err)
 	}
-
-	log.Println("Using local SQLite database")
-	return storage.NewSQLiteRepository("./hound.db")
-}
-
-func startSession(m *runner.SessionManager, repo storage.Repository) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		userId := GetUserId(r)
-		if userId == "" {
-			respondError(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		var req struct {
-			TargetSec *int `json:"targetSec,omitempty"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondError(w, "invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		var targetSec int
-
-		if req.TargetSec != nil && *req.TargetSec > 0 {
-			targetSec = *req.TargetSec
-		} else {
-			calculated, err := calculateNextTarget(repo, userId)
-			if err != nil {
-				log.Printf("Failed to calculate target: %v, using default", err)
-				targetSec = 300
-			} else {
-				targetSec = calculated
-			}
-		}
-
-		session := domain.NewSession("", userId, targetSec)
-
-		if err := m.StartSession(session); err != nil {
-			respondError(w, err.Error(), http.StatusConflict)
-			return
-		}
-
-		respondJSON(w, session, http.StatusCreated)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Tracing.Enabled() {
+		slog.Info("exporting traces", "endpoint", cfg.Tracing.Endpoint)
 	}
-}
 
-func calculateNextTarget(repo storage.Repository, userID string) (int, error) {
-	sessions, err := repo.GetRecentSessions(userID, time.Now().AddDate(0, 0, -30))
+	repo, err := initRepository(cfg.Database)
 	if err != nil {
-		return 0, err
-	}
-
-	if len(sessions) == 0 {
-		return 300, nil // 5 minutes
+		fatal("failed to initialize database", err)
 	}
+	repo = storage.Instrument(storage.Trace(storage.Timeout(repo, cfg.Database.QueryTimeout), tp), prom)
+	defer repo.Close()
 
-	lastSession := sessions[0]
-	lastTarget := lastSession.TargetSec
-
-	var next float64
-	switch lastSession.Success {
-	case storage.SuccessLevelGreat:
-		next = float64(lastTarget) * TargetGreat
-	case storage.SuccessLevelOK:
-		next = float64(lastTarget) * TargetOK
-	case storage.SuccessLevelFail:
-		next = float64(lastTarget) * TargetFail
-	default:
-		next = float64(lastTarget)
+	hooks := webhook.New(repo,
+		webhook.WithRetries(cfg.Webhooks.MaxAttempts, cfg.Webhooks.Backoff),
+		webhook.WithAllowedNetworks(cfg.Webhooks.AllowedNetworks),
+		webhook.WithLogger(logger),
+	)
+
+	// reminders go to the log unless Web Push is set up
+	var notifier scheduler.Notifier = scheduler.LogNotifier{Log: logger}
+	managerOpts := []runner.Option{
+		runner.WithAuthorizer(server.NewAuthorizer(repo)),
+		runner.WithCleanup(cfg.Sessions.CleanupInterval, cfg.Sessions.CompletedTTL),
+		runner.WithCheckpointer(repo),
+		runner.WithArchiver(server.NewArchiver(repo, hooks)),
+		runner.WithNotifier(hooks),
+		runner.WithIdleTimeout(cfg.Sessions.AbandonAfter),
+		runner.WithMaxSessionsPerUser(cfg.Sessions.MaxPerUser),
+		runner.WithMaxPause(cfg.Sessions.MaxPause),
+		runner.WithMetrics(prom),
+		runner.WithLogger(logger),
+		runner.WithTracerProvider(tp),
+	}
+	var pushKey string
+	if cfg.Push.Enabled() {
+		sender, err := push.NewSender(push.Keys{
+			PublicKey:  cfg.Push.VAPIDPublicKey,
+			PrivateKey: cfg.Push.VAPIDPrivateKey,
+		}, cfg.Push.Subject, nil)
+		if err != nil {
+			fatal("failed to initialize web push", err)
+		}
+		pushNotifier := push.NewNotifier(repo, sender, logger)
+		notifier = pushNotifier
+		managerOpts = append(managerOpts, runner.WithNotifier(pushNotifier))
+		pushKey = sender.PublicKey()
+		slog.Info("sending web push notifications")
+	}
+	manager := runner.NewSessionManager(managerOpts...)
+
+	// stopped after the manager, so the server_restarting events go out
+	mqttCtx, stopMQTT := context.WithCancel(context.Background())
+	mqttDone := make(chan struct{})
+	if cfg.MQTT.Enabled() {
+		events, unsubscribe := manager.SubscribeAll()
+		defer unsubscribe()
+		go func() {
+			mqtt.New(cfg.MQTT, logger).Run(mqttCtx, events)
+			close(mqttDone)
+		}()
+		slog.Info("publishing session events over mqtt", "broker", cfg.MQTT.Broker)
+	} else {
+		close(mqttDone)
+	}
+
+	checkpoints, err := repo.TakeCheckpoints(context.Background())
+	if err != nil {
+		slog.Error("failed to load session checkpoints", "err", err)
 	}
-
-	if next <= float64(lastTarget) && lastSession.Success != storage.SuccessLevelFail {
-		next = float64(lastTarget + 1)
+	if len(checkpoints) > 0 {
+		manager.Restore(checkpoints)
+		slog.Info("restored sessions from checkpoint", "count", len(checkpoints))
 	}
 
-	return int(next), nil
-}
-
-func getNextTarget(repo storage.Repository) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		userId := GetUserId(r)
-		if userId == "" {
-			respondError(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		target, err := calculateNextTarget(repo, userId)
+	var auth *server.OIDCAuth
+	if cfg.OIDC.Enabled() {
+		auth, err = server.NewOIDCAuth(context.Background(), cfg.OIDC)
 		if err != nil {
-			log.Printf("Failed to calculate next target: %v", err)
-			respondError(w, "failed to calculate next target", http.StatusInternalServerError)
-			return
+			fatal("failed to initialize oidc", err)
 		}
-
-		respondJSON(w, map[string]int{"nextTarget": target}, http.StatusOK)
+		slog.Info("using OIDC login", "issuer", cfg.OIDC.IssuerURL)
 	}
-}
-
-func getSession(m *runner.SessionManager) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
 
-		session, ok := m.GetSession(id)
-		if !ok {
-			respondError(w, "session not found", http.StatusNotFound)
-			return
-		}
+	handler := server.New(server.Config{
+		StaticDir:      cfg.StaticDir,
+		Targets:        cfg.Targets,
+		ShareSecret:    []byte(cfg.ShareSecret),
+		Webhooks:       hooks,
+		PushPublicKey:  pushKey,
+		OIDC:           auth,
+		Logger:         logger,
+		Metrics:        prom,
+		MetricsHandler: prom.Handler(),
+		TracerProvider: tp,
+	}, repo, manager)
 
-		respondJSON(w, session, http.StatusOK)
+	srv := &http.Server{
+		Addr:    cfg.ListenAddr,
+		Handler: handler,
 	}
-}
 
-func completeSession(m *runner.SessionManager, repo storage.Repository) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-		var req struct {
-			Success storage.SuccessLevel `json:"success"`
-			Comment string               `json:"comment"`
-		}
+	reminders := scheduler.New(repo, notifier,
+		scheduler.WithInterval(cfg.Reminders.CheckInterval),
+		scheduler.WithMaxDelay(cfg.Reminders.MaxDelay),
+		scheduler.WithStarter(server.NewSessionStarter(repo, manager, cfg.Targets)),
+		scheduler.WithLogger(logger),
+	)
+	go reminders.Run(ctx)
 
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondError(w, "invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		session, ok := m.GetSession(id)
-		if !ok {
-			respondError(w, "session not found", http.StatusNotFound)
-			return
-		}
-
-		record := storage.FromDomainSession(session, req.Success, req.Comment)
-		if err := repo.SaveSession(record); err != nil {
-			log.Printf("failed to save session: %v", err)
-			respondError(w, "failed to save session", http.StatusInternalServerError)
-			return
-		}
-		if err := m.CompleteSession(id); err != nil {
-			log.Printf("failed to mark session complete: %v", err)
-		}
-
-		respondJSON(w, map[string]string{"status": "saved"}, http.StatusOK)
-	}
-}
-
-func getSessionStatus(m *runner.SessionManager) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-
-		session, ok := m.GetSession(id)
-		if !ok {
-			respondError(w, "session not found", http.StatusNotFound)
-			return
-		}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", cfg.ListenAddr)
+		serveErr <- srv.ListenAndServe()
+	}()
 
-		status := struct {
-			ID        string `json:"id"`
-			Completed bool   `json:"completed"`
-			Current   int    `json:"currentStep"`
-		}{
-			ID:        session.ID,
-			Completed: session.Completed,
-			Current:   session.CurrentIdx,
-		}
-
-		respondJSON(w, status, http.StatusOK)
-	}
-}
-
-func stopSession(m *runner.SessionManager) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-
-		if err := m.StopSession(id); err != nil {
-			respondError(w, err.Error(), http.StatusNotFound)
-			return
-		}
-
-		w.WriteHeader(http.StatusNoContent)
+	select {
+	case err := <-serveErr:
+		fatal("server failed", err)
+	case <-ctx.Done():
 	}
-}
-
-func startStep(m *runner.SessionManager) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		stepIdx, err := parseStepIndex(r)
-
-		if err != nil {
-			respondError(w, "invalid step index", http.StatusBadRequest)
-			return
-		}
 
-		if err := m.StartStep(id, stepIdx); err != nil {
-			respondError(w, err.Error(), http.StatusNotFound)
-			return
-		}
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-		w.WriteHeader(http.StatusNoContent)
+	// the manager goes first: it ends the SSE streams that would otherwise
+	// keep srv.Shutdown waiting until the deadline
+	if err := manager.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to checkpoint sessions", "err", err)
 	}
-}
-
-func stopStep(m *runner.SessionManager) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		stepIdx, err := parseStepIndex(r)
-		if err != nil {
-			respondError(w, "invalid step index", http.StatusBadRequest)
-			return
-		}
-
-		if err := m.StopStep(id, stepIdx); err != nil {
-			respondError(w, err.Error(), http.StatusNotFound)
-			return
-		}
-
-		w.WriteHeader(http.StatusNoContent)
+	stopMQTT()
+	<-mqttDone
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("http shutdown", "err", err)
 	}
-}
-
-func getHistory(repo storage.Repository) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		userID := GetUserId(r)
-		if userID == "" {
-			respondError(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		sessions, err := repo.GetSessionsByUser(userID)
-		if err != nil {
-			log.Printf("Failed to get history: %v", err)
-			respondError(w, "failed to retrieve history", http.StatusInternalServerError)
-			return
-		}
-
-		respondJSON(w, sessions, http.StatusOK)
+	// after the manager, whose archived sessions are reported as saved
+	if err := hooks.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to finish webhook deliveries", "err", err)
 	}
-}
-
-func getStats(repo storage.Repository) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		userID := GetUserId(r)
-		if userID == "" {
-			respondError(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		stats, err := repo.GetSessionStats(userID)
-		if err != nil {
-			log.Printf("Failed to get stats: %v", err)
-			respondError(w, "failed to retrieve stats", http.StatusInternalServerError)
-			return
-		}
-
-		respondJSON(w, stats, http.StatusOK)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("failed to flush traces", "err", err)
 	}
 }
 
-// helpers below
-func parseStepIndex(r *http.Request) (int, error) {
-	idxStr := chi.URLParam(r, "idx")
-	return strconv.Atoi(idxStr)
+// fatal logs err and exits. Deferred calls do not run, so it is only meant
+// for startup failures.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
 
-func respondJSON(w http.ResponseWriter, data any, status int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("failed to encode response: %v", err)
+func initRepository(cfg config.DatabaseConfig) (storage.Repository, error) {
+	if cfg.Driver() == "postgres" {
+		slog.Info("using Postgres database")
+		return storage.NewPostgresRepository(cfg.DSN)
 	}
-}
 
-func respondError(w http.ResponseWriter, message string, status int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+	slog.Info("using local SQLite database", "path", cfg.DSN)
+	return storage.NewSQLiteRepository(cfg.DSN)
 }
@@ -0,0 +1,20 @@
+// Command vapidkeys prints a new VAPID key pair for Web Push, in the form
+// of the environment variables the server reads it from.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hperssn/hound/internal/push"
+)
+
+func main() {
+	keys, err := push.GenerateKeys()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("HOUND_VAPID_PUBLIC_KEY=%s\n", keys.PublicKey)
+	fmt.Printf("HOUND_VAPID_PRIVATE_KEY=%s\n", keys.PrivateKey)
+}
@@ -0,0 +1,539 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every tunable of the server. Values are resolved from
+// defaults, then an optional YAML file, then HOUND_* environment
+// variables, then command-line flags; later sources win.
+type Config struct {
+	ListenAddr string `yaml:"listenAddr"`
+	// ShutdownTimeout bounds graceful shutdown after SIGTERM; keep it below
+	// the pod's termination grace period.
+	ShutdownTimeout time.Duration   `yaml:"shutdownTimeout"`
+	StaticDir       string          `yaml:"staticDir"`
+	Database        DatabaseConfig  `yaml:"database"`
+	Sessions        SessionsConfig  `yaml:"sessions"`
+	Targets         TargetsConfig   `yaml:"targets"`
+	Reminders       RemindersConfig `yaml:"reminders"`
+	Push            PushConfig      `yaml:"push"`
+	Webhooks        WebhooksConfig  `yaml:"webhooks"`
+	MQTT            MQTTConfig      `yaml:"mqtt"`
+	OIDC            OIDCConfig      `yaml:"oidc"`
+	Log             LogConfig       `yaml:"log"`
+	Tracing         TracingConfig   `yaml:"tracing"`
+	// ShareSecret signs share links, defaulting to OIDC.SessionSecret. Each
+	// use derives its own key from the secret.
+	ShareSecret string `yaml:"shareSecret"`
+}
+
+type DatabaseConfig struct {
+	// DSN is a Postgres URL or connection string, or a SQLite path/URI.
+	DSN string `yaml:"dsn"`
+	// QueryTimeout bounds each repository call.
+	QueryTimeout time.Duration `yaml:"queryTimeout"`
+}
+
+type SessionsConfig struct {
+	CleanupInterval time.Duration `yaml:"cleanupInterval"`
+	// CompletedTTL is how long completed sessions stay in memory.
+	CompletedTTL time.Duration `yaml:"completedTTL"`
+	// AbandonAfter is how long a session may sit idle without being
+	// completed before it is evicted and saved to history as abandoned.
+	AbandonAfter time.Duration `yaml:"abandonAfter"`
+	// MaxPerUser limits how many sessions a user may have in progress at
+	// once. Zero means no limit.
+	MaxPerUser int `yaml:"maxPerUser"`
+	// MaxPause is how long a step may stay paused before it is aborted.
+	// Zero means no limit.
+	MaxPause time.Duration `yaml:"maxPause"`
+}
+
+type RemindersConfig struct {
+	// CheckInterval is how often reminders are checked for being due.
+	CheckInterval time.Duration `yaml:"checkInterval"`
+	// MaxDelay is how late a reminder may still be sent, such as after a
+	// restart. Reminders missed by longer are skipped.
+	MaxDelay time.Duration `yaml:"maxDelay"`
+}
+
+// PushConfig holds the VAPID key pair Web Push messages are signed with.
+// Generate one with cmd/vapidkeys.
+type PushConfig struct {
+	VAPIDPublicKey  string `yaml:"vapidPublicKey"`
+	VAPIDPrivateKey string `yaml:"vapidPrivateKey"`
+	// Subject is a mailto: or https: contact for push service operators.
+	Subject string `yaml:"subject"`
+}
+
+func (c PushConfig) Enabled() bool {
+	return c.VAPIDPrivateKey != ""
+}
+
+type WebhooksConfig struct {
+	// MaxAttempts is how often an event is tried before it is given up.
+	MaxAttempts int `yaml:"maxAttempts"`
+	// Backoff is the wait before the first retry. It doubles with each
+	// further retry, up to ten minutes.
+	Backoff time.Duration `yaml:"backoff"`
+	// AllowedNetworks are private networks webhooks may post to, such as
+	// the LAN a home automation server is on. Other targets must be public.
+	AllowedNetworks []netip.Prefix `yaml:"allowedNetworks"`
+}
+
+// MQTTConfig controls publishing session events to an MQTT broker.
+type MQTTConfig struct {
+	// Broker is the broker URL, e.g. tcp://mqtt.local:1883. Publishing is
+	// off when it is empty.
+	Broker   string `yaml:"broker"`
+	ClientID string `yaml:"clientID"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Topic is the topic template; {user}, {session} and {event} are
+	// replaced with the session's user, its ID and the event type.
+	Topic string `yaml:"topic"`
+	// QoS is the quality of service events are published with: 0, 1 or 2.
+	// Ticks are always published at QoS 0.
+	QoS int `yaml:"qos"`
+	// MaxReconnectInterval caps the wait between attempts to reconnect to
+	// the broker.
+	MaxReconnectInterval time.Duration `yaml:"maxReconnectInterval"`
+}
+
+func (c MQTTConfig) Enabled() bool {
+	return c.Broker != ""
+}
+
+// TargetsConfig controls how the next target is derived from the last
+// session's result.
+type TargetsConfig struct {
+	Great       float64 `yaml:"great"`
+	OK          float64 `yaml:"ok"`
+	Fail        float64 `yaml:"fail"`
+	DefaultSec  int     `yaml:"defaultSec"`
+	HistoryDays int     `yaml:"historyDays"`
+}
+
+type OIDCConfig struct {
+	IssuerURL    string `yaml:"issuerURL"`
+	ClientID     string `yaml:"clientID"`
+	ClientSecret string `yaml:"clientSecret"`
+	RedirectURL  string `yaml:"redirectURL"`
+	// UserClaim selects the ID token claim used as the UserID, "sub" or "email".
+	UserClaim     string `yaml:"userClaim"`
+	SessionSecret string `yaml:"sessionSecret"`
+	CookieSecure  bool   `yaml:"cookieSecure"`
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is text or json.
+	Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP collector URL, e.g.
+	// http://otel-collector:4318. Tracing is off when it is empty.
+	Endpoint string `yaml:"endpoint"`
+	// SampleRatio is the fraction of new traces recorded; traces started
+	// by a caller follow the caller's decision.
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+func (c TracingConfig) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != ""
+}
+
+// Driver returns the database/sql driver name for the DSN.
+func (c DatabaseConfig) Driver() string {
+	dsn := strings.ToLower(c.DSN)
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") || strings.Contains(dsn, "host=") {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+func Default() Config {
+	return Config{
+		ListenAddr:      ":8080",
+		ShutdownTimeout: 25 * time.Second,
+		StaticDir:       "./static",
+		Database: DatabaseConfig{
+			DSN:          "./hound.db",
+			QueryTimeout: 5 * time.Second,
+		},
+		Sessions: SessionsConfig{
+			CleanupInterval: 5 * time.Minute,
+			CompletedTTL:    time.Hour,
+			AbandonAfter:    2 * time.Hour,
+			MaxPerUser:      3,
+			MaxPause:        15 * time.Minute,
+		},
+		Reminders: RemindersConfig{
+			CheckInterval: time.Minute,
+			MaxDelay:      15 * time.Minute,
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts: 5,
+			Backoff:     10 * time.Second,
+		},
+		MQTT: MQTTConfig{
+			ClientID:             "hound",
+			Topic:                "hound/{user}/{session}/step",
+			MaxReconnectInterval: time.Minute,
+		},
+		Targets: TargetsConfig{
+			Great:       1.20,
+			OK:          1.15,
+			Fail:        0.90,
+			DefaultSec:  300,
+			HistoryDays: 30,
+		},
+		OIDC: OIDCConfig{
+			UserClaim:    "sub",
+			CookieSecure: true,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Tracing: TracingConfig{
+			SampleRatio: 1,
+		},
+	}
+}
+
+// setting binds one value to its environment variable and flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{"HOUND_LISTEN_ADDR", "listen", "address to listen on", setString(func(c *Config) *string { return &c.ListenAddr })},
+	{"HOUND_SHUTDOWN_TIMEOUT", "shutdown-timeout", "time allowed for graceful shutdown", setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{"HOUND_STATIC_DIR", "static-dir", "directory holding the web UI", setString(func(c *Config) *string { return &c.StaticDir })},
+	{"HOUND_DB_DSN", "db-dsn", "Postgres URL or SQLite path", setString(func(c *Config) *string { return &c.Database.DSN })},
+	{"HOUND_DB_QUERY_TIMEOUT", "db-query-timeout", "time allowed for each database call", setDuration(func(c *Config) *time.Duration { return &c.Database.QueryTimeout })},
+	{"HOUND_CLEANUP_INTERVAL", "cleanup-interval", "how often finished sessions are evicted", setDuration(func(c *Config) *time.Duration { return &c.Sessions.CleanupInterval })},
+	{"HOUND_COMPLETED_SESSION_TTL", "completed-session-ttl", "how long completed sessions stay in memory", setDuration(func(c *Config) *time.Duration { return &c.Sessions.CompletedTTL })},
+	{"HOUND_ABANDON_AFTER", "abandon-after", "how long an idle session waits before it is saved as abandoned", setDuration(func(c *Config) *time.Duration { return &c.Sessions.AbandonAfter })},
+	{"HOUND_MAX_SESSIONS_PER_USER", "max-sessions-per-user", "sessions a user may have in progress at once (0 for no limit)", setInt(func(c *Config) *int { return &c.Sessions.MaxPerUser })},
+	{"HOUND_MAX_PAUSE", "max-pause", "how long a step may stay paused before it is aborted (0 for no limit)", setDuration(func(c *Config) *time.Duration { return &c.Sessions.MaxPause })},
+	{"HOUND_REMINDER_CHECK_INTERVAL", "reminder-check-interval", "how often reminders are checked", setDuration(func(c *Config) *time.Duration { return &c.Reminders.CheckInterval })},
+	{"HOUND_REMINDER_MAX_DELAY", "reminder-max-delay", "how late a missed reminder may still be sent", setDuration(func(c *Config) *time.Duration { return &c.Reminders.MaxDelay })},
+	{"HOUND_VAPID_PUBLIC_KEY", "vapid-public-key", "VAPID public key for Web Push", setString(func(c *Config) *string { return &c.Push.VAPIDPublicKey })},
+	{"HOUND_VAPID_PRIVATE_KEY", "vapid-private-key", "VAPID private key, enables Web Push", setString(func(c *Config) *string { return &c.Push.VAPIDPrivateKey })},
+	{"HOUND_VAPID_SUBJECT", "vapid-subject", "mailto: or https: contact sent to push services", setString(func(c *Config) *string { return &c.Push.Subject })},
+	{"HOUND_WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "attempts made to deliver a webhook event", setInt(func(c *Config) *int { return &c.Webhooks.MaxAttempts })},
+	{"HOUND_WEBHOOK_BACKOFF", "webhook-backoff", "wait before the first webhook retry, doubling after", setDuration(func(c *Config) *time.Duration { return &c.Webhooks.Backoff })},
+	{"HOUND_WEBHOOK_ALLOWED_NETWORKS", "webhook-allowed-networks", "comma-separated private networks webhooks may post to", setPrefixes(func(c *Config) *[]netip.Prefix { return &c.Webhooks.AllowedNetworks })},
+	{"HOUND_MQTT_BROKER", "mqtt-broker", "MQTT broker URL, enables publishing session events", setString(func(c *Config) *string { return &c.MQTT.Broker })},
+	{"HOUND_MQTT_CLIENT_ID", "mqtt-client-id", "MQTT client ID", setString(func(c *Config) *string { return &c.MQTT.ClientID })},
+	{"HOUND_MQTT_USERNAME", "mqtt-username", "MQTT username", setString(func(c *Config) *string { return &c.MQTT.Username })},
+	{"HOUND_MQTT_PASSWORD", "mqtt-password", "MQTT password", setString(func(c *Config) *string { return &c.MQTT.Password })},
+	{"HOUND_MQTT_TOPIC", "mqtt-topic", "MQTT topic template ({user}, {session}, {event})", setString(func(c *Config) *string { return &c.MQTT.Topic })},
+	{"HOUND_MQTT_QOS", "mqtt-qos", "MQTT quality of service (0, 1 or 2)", setInt(func(c *Config) *int { return &c.MQTT.QoS })},
+	{"HOUND_MQTT_MAX_RECONNECT_INTERVAL", "mqtt-max-reconnect-interval", "longest wait between MQTT reconnect attempts", setDuration(func(c *Config) *time.Duration { return &c.MQTT.MaxReconnectInterval })},
+	{"HOUND_TARGET_GREAT", "target-great", "next target multiplier after a great session", setFloat(func(c *Config) *float64 { return &c.Targets.Great })},
+	{"HOUND_TARGET_OK", "target-ok", "next target multiplier after an ok session", setFloat(func(c *Config) *float64 { return &c.Targets.OK })},
+	{"HOUND_TARGET_FAIL", "target-fail", "next target multiplier after a failed session", setFloat(func(c *Config) *float64 { return &c.Targets.Fail })},
+	{"HOUND_TARGET_DEFAULT_SEC", "target-default-sec", "target used without recent history", setInt(func(c *Config) *int { return &c.Targets.DefaultSec })},
+	{"HOUND_TARGET_HISTORY_DAYS", "target-history-days", "days of history considered for the next target", setInt(func(c *Config) *int { return &c.Targets.HistoryDays })},
+	{"HOUND_OIDC_ISSUER", "oidc-issuer", "OIDC issuer URL, enables OIDC login", setString(func(c *Config) *string { return &c.OIDC.IssuerURL })},
+	{"HOUND_OIDC_CLIENT_ID", "oidc-client-id", "OIDC client ID", setString(func(c *Config) *string { return &c.OIDC.ClientID })},
+	{"HOUND_OIDC_CLIENT_SECRET", "oidc-client-secret", "OIDC client secret", setString(func(c *Config) *string { return &c.OIDC.ClientSecret })},
+	{"HOUND_OIDC_REDIRECT_URL", "oidc-redirect-url", "OIDC callback URL", setString(func(c *Config) *string { return &c.OIDC.RedirectURL })},
+	{"HOUND_OIDC_USER_CLAIM", "oidc-user-claim", "ID token claim used as user ID (sub or email)", setString(func(c *Config) *string { return &c.OIDC.UserClaim })},
+	{"HOUND_SESSION_SECRET", "session-secret", "key signing login cookies", setString(func(c *Config) *string { return &c.OIDC.SessionSecret })},
+	{"HOUND_COOKIE_SECURE", "cookie-secure", "mark login cookies Secure", setBool(func(c *Config) *bool { return &c.OIDC.CookieSecure })},
+	{"HOUND_SHARE_SECRET", "share-secret", "key signing share links", setString(func(c *Config) *string { return &c.ShareSecret })},
+	{"HOUND_LOG_LEVEL", "log-level", "minimum log level (debug, info, warn, error)", setString(func(c *Config) *string { return &c.Log.Level })},
+	{"HOUND_LOG_FORMAT", "log-format", "log output format (text or json)", setString(func(c *Config) *string { return &c.Log.Format })},
+	{"HOUND_OTLP_ENDPOINT", "otlp-endpoint", "OTLP/HTTP collector URL, enables tracing", setString(func(c *Config) *string { return &c.Tracing.Endpoint })},
+	{"HOUND_TRACE_SAMPLE_RATIO", "trace-sample-ratio", "fraction of traces recorded (0 to 1)", setFloat(func(c *Config) *float64 { return &c.Tracing.SampleRatio })},
+}
+
+// Load resolves the configuration from args (without the program name)
+// and the environment. The config file is named by -config or
+// HOUND_CONFIG.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	fs := flag.NewFlagSet("hound", flag.ContinueOnError)
+
+	configPath := fs.String("config", "", "path to a YAML config file")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.flag] = fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	path := *configPath
+	if path == "" {
+		path, _ = lookupEnv("HOUND_CONFIG")
+	}
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+
+	// DATABASE_URL predates HOUND_DB_DSN and is still honoured
+	if v, ok := lookupEnv("DATABASE_URL"); ok && v != "" {
+		cfg.Database.DSN = v
+	}
+
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env); ok && v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name {
+				if err := s.set(&cfg, *flagValues[s.flag]); err != nil {
+					errs = append(errs, fmt.Errorf("-%s: %w", s.flag, err))
+				}
+			}
+		}
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if cfg.ShareSecret == "" {
+		cfg.ShareSecret = cfg.OIDC.SessionSecret
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid value at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.ListenAddr == "" {
+		errs = append(errs, errors.New("listenAddr must not be empty"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdownTimeout must be positive"))
+	}
+	if c.StaticDir == "" {
+		errs = append(errs, errors.New("staticDir must not be empty"))
+	}
+	if c.Database.DSN == "" {
+		errs = append(errs, errors.New("database.dsn must not be empty"))
+	}
+	if c.Database.QueryTimeout <= 0 {
+		errs = append(errs, errors.New("database.queryTimeout must be positive"))
+	}
+	if c.Sessions.CleanupInterval <= 0 {
+		errs = append(errs, errors.New("sessions.cleanupInterval must be positive"))
+	}
+	if c.Sessions.CompletedTTL <= 0 {
+		errs = append(errs, errors.New("sessions.completedTTL must be positive"))
+	}
+	if c.Sessions.AbandonAfter <= 0 {
+		errs = append(errs, errors.New("sessions.abandonAfter must be positive"))
+	}
+	if c.Sessions.MaxPerUser < 0 {
+		errs = append(errs, errors.New("sessions.maxPerUser must not be negative"))
+	}
+	if c.Sessions.MaxPause < 0 {
+		errs = append(errs, errors.New("sessions.maxPause must not be negative"))
+	}
+	if c.Reminders.CheckInterval <= 0 {
+		errs = append(errs, errors.New("reminders.checkInterval must be positive"))
+	}
+	if c.Reminders.MaxDelay <= 0 {
+		errs = append(errs, errors.New("reminders.maxDelay must be positive"))
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, errors.New("webhooks.maxAttempts must be at least 1"))
+	}
+	if c.Webhooks.Backoff <= 0 {
+		errs = append(errs, errors.New("webhooks.backoff must be positive"))
+	}
+	if c.Targets.Great <= 0 || c.Targets.OK <= 0 || c.Targets.Fail <= 0 {
+		errs = append(errs, errors.New("targets multipliers must be positive"))
+	}
+	if c.Targets.DefaultSec <= 0 {
+		errs = append(errs, errors.New("targets.defaultSec must be positive"))
+	}
+	if c.Targets.HistoryDays <= 0 {
+		errs = append(errs, errors.New("targets.historyDays must be positive"))
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log.format must be text or json, got %q", c.Log.Format))
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sampleRatio must be between 0 and 1, got %v", c.Tracing.SampleRatio))
+	}
+
+	if c.Push.VAPIDPublicKey != "" || c.Push.VAPIDPrivateKey != "" {
+		if c.Push.VAPIDPublicKey == "" || c.Push.VAPIDPrivateKey == "" {
+			errs = append(errs, errors.New("push.vapidPublicKey and push.vapidPrivateKey must be set together"))
+		}
+		if !strings.HasPrefix(c.Push.Subject, "mailto:") && !strings.HasPrefix(c.Push.Subject, "https://") {
+			errs = append(errs, fmt.Errorf("push.subject must be a mailto: or https: URL, got %q", c.Push.Subject))
+		}
+	}
+
+	if c.MQTT.Enabled() {
+		if u, err := url.Parse(c.MQTT.Broker); err != nil || u.Host == "" || !slices.Contains([]string{"tcp", "ssl", "tls", "ws", "wss", "mqtt", "mqtts"}, u.Scheme) {
+			errs = append(errs, fmt.Errorf("mqtt.broker must be a URL such as tcp://host:1883, got %q", c.MQTT.Broker))
+		}
+		if c.MQTT.Topic == "" || strings.ContainsAny(c.MQTT.Topic, "+#") {
+			errs = append(errs, fmt.Errorf("mqtt.topic must be set and free of wildcards, got %q", c.MQTT.Topic))
+		}
+		if c.MQTT.QoS < 0 || c.MQTT.QoS > 2 {
+			errs = append(errs, fmt.Errorf("mqtt.qos must be 0, 1 or 2, got %d", c.MQTT.QoS))
+		}
+		if c.MQTT.MaxReconnectInterval <= 0 {
+			errs = append(errs, errors.New("mqtt.maxReconnectInterval must be positive"))
+		}
+	}
+
+	if c.OIDC.Enabled() {
+		if c.OIDC.ClientID == "" {
+			errs = append(errs, errors.New("oidc.clientID is required when OIDC is enabled"))
+		}
+		if c.OIDC.RedirectURL == "" {
+			errs = append(errs, errors.New("oidc.redirectURL is required when OIDC is enabled"))
+		}
+		if len(c.OIDC.SessionSecret) < 32 {
+			errs = append(errs, errors.New("oidc.sessionSecret must be at least 32 characters"))
+		}
+		if c.OIDC.UserClaim != "sub" && c.OIDC.UserClaim != "email" {
+			errs = append(errs, fmt.Errorf("oidc.userClaim must be sub or email, got %q", c.OIDC.UserClaim))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func setFloat(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setBool(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setPrefixes(field func(*Config) *[]netip.Prefix) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		var prefixes []netip.Prefix
+		for _, part := range strings.Split(v, ",") {
+			p, err := netip.ParsePrefix(strings.TrimSpace(part))
+			if err != nil {
+				return err
+			}
+			prefixes = append(prefixes, p)
+		}
+		*field(c) = prefixes
+		return nil
+	}
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hound.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.ListenAddr != ":8080" {
+		t.Errorf("ListenAddr = %q, want :8080", cfg.ListenAddr)
+	}
+	if cfg.Database.DSN != "./hound.db" || cfg.Database.Driver() != "sqlite3" {
+		t.Errorf("Database = %+v, want sqlite ./hound.db", cfg.Database)
+	}
+	if cfg.Sessions.CleanupInterval != 5*time.Minute {
+		t.Errorf("CleanupInterval = %v, want 5m", cfg.Sessions.CleanupInterval)
+	}
+	if cfg.Targets.Great != 1.20 || cfg.Targets.OK != 1.15 || cfg.Targets.Fail != 0.90 {
+		t.Errorf("Targets = %+v", cfg.Targets)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+listenAddr: ":7000"
+staticDir: /srv/static
+sessions:
+  cleanupInterval: 1m
+targets:
+  great: 1.5
+`)
+
+	cfg, err := Load(
+		[]string{"-config", path, "-listen", ":9000"},
+		env(map[string]string{
+			"HOUND_LISTEN_ADDR":      ":8000",
+			"HOUND_CLEANUP_INTERVAL": "2m",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.ListenAddr != ":9000" {
+		t.Errorf("flag should win: ListenAddr = %q", cfg.ListenAddr)
+	}
+	if cfg.Sessions.CleanupInterval != 2*time.Minute {
+		t.Errorf("env should beat file: CleanupInterval = %v", cfg.Sessions.CleanupInterval)
+	}
+	if cfg.StaticDir != "/srv/static" || cfg.Targets.Great != 1.5 {
+		t.Errorf("file should beat defaults: StaticDir = %q, Great = %v", cfg.StaticDir, cfg.Targets.Great)
+	}
+	if cfg.Targets.OK != 1.15 {
+		t.Errorf("unset values keep defaults: OK = %v", cfg.Targets.OK)
+	}
+}
+
+func TestLoadWebhookAllowedNetworks(t *testing.T) {
+	path := writeConfig(t, `
+webhooks:
+  allowedNetworks: [192.168.1.0/24, "fd00::/8"]
+`)
+	cfg, err := Load([]string{"-config", path}, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24"), netip.MustParsePrefix("fd00::/8")}
+	if !slices.Equal(cfg.Webhooks.AllowedNetworks, want) {
+		t.Fatalf("AllowedNetworks = %v, want %v", cfg.Webhooks.AllowedNetworks, want)
+	}
+
+	cfg, err = Load(nil, env(map[string]string{"HOUND_WEBHOOK_ALLOWED_NETWORKS": "10.0.0.0/8, 127.0.0.1/32"}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("127.0.0.1/32")}
+	if !slices.Equal(cfg.Webhooks.AllowedNetworks, want) {
+		t.Fatalf("AllowedNetworks from env = %v, want %v", cfg.Webhooks.AllowedNetworks, want)
+	}
+}
+
+func TestLoadConfigPathFromEnv(t *testing.T) {
+	path := writeConfig(t, "listenAddr: \":7000\"\n")
+
+	cfg, err := Load(nil, env(map[string]string{"HOUND_CONFIG": path}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.ListenAddr != ":7000" {
+		t.Errorf("ListenAddr = %q, want :7000", cfg.ListenAddr)
+	}
+}
+
+func TestLoadDatabaseDSN(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		dsn    string
+		driver string
+	}{
+		{"docker sqlite dsn", map[string]string{"HOUND_DB_DSN": "file:/data/hound.db?cache=shared"}, "file:/data/hound.db?cache=shared", "sqlite3"},
+		{"legacy DATABASE_URL", map[string]string{"DATABASE_URL": "postgres://db/hound"}, "postgres://db/hound", "postgres"},
+		{"HOUND_DB_DSN wins", map[string]string{"DATABASE_URL": "postgres://old", "HOUND_DB_DSN": "postgresql://new"}, "postgresql://new", "postgres"},
+		{"keyword dsn", map[string]string{"HOUND_DB_DSN": "host=db user=hound"}, "host=db user=hound", "postgres"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(nil, env(tt.env))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Database.DSN != tt.dsn || cfg.Database.Driver() != tt.driver {
+				t.Fatalf("got %q (%s), want %q (%s)", cfg.Database.DSN, cfg.Database.Driver(), tt.dsn, tt.driver)
+			}
+		})
+	}
+}
+
+func TestLoadValidationErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"bad duration", nil, map[string]string{"HOUND_CLEANUP_INTERVAL": "soon"}, "HOUND_CLEANUP_INTERVAL"},
+		{"bad float flag", []string{"-target-great", "lots"}, nil, "-target-great"},
+		{"negative multiplier", []string{"-target-fail", "-1"}, nil, "multipliers must be positive"},
+		{"zero abandon after", nil, map[string]string{"HOUND_ABANDON_AFTER": "0s"}, "sessions.abandonAfter"},
+		{"negative max sessions per user", nil, map[string]string{"HOUND_MAX_SESSIONS_PER_USER": "-1"}, "sessions.maxPerUser"},
+		{"negative max pause", []string{"-max-pause", "-1m"}, nil, "sessions.maxPause"},
+		{"zero reminder check interval", nil, map[string]string{"HOUND_REMINDER_CHECK_INTERVAL": "0s"}, "reminders.checkInterval"},
+		{"vapid key without its pair", nil, map[string]string{"HOUND_VAPID_PRIVATE_KEY": "key", "HOUND_VAPID_SUBJECT": "mailto:a@example.com"}, "push.vapidPublicKey"},
+		{"vapid without subject", nil, map[string]string{"HOUND_VAPID_PUBLIC_KEY": "pub", "HOUND_VAPID_PRIVATE_KEY": "key"}, "push.subject"},
+		{"no webhook attempts", []string{"-webhook-max-attempts", "0"}, nil, "webhooks.maxAttempts"},
+		{"bad webhook network", nil, map[string]string{"HOUND_WEBHOOK_ALLOWED_NETWORKS": "192.168.1.0/24,lan"}, "HOUND_WEBHOOK_ALLOWED_NETWORKS"},
+		{"mqtt broker without scheme", nil, map[string]string{"HOUND_MQTT_BROKER": "mqtt.local:1883"}, "mqtt.broker"},
+		{"mqtt wildcard topic", []string{"-mqtt-broker", "tcp://mqtt.local:1883", "-mqtt-topic", "hound/#"}, nil, "mqtt.topic"},
+		{"mqtt qos out of range", []string{"-mqtt-broker", "tcp://mqtt.local:1883", "-mqtt-qos", "3"}, nil, "mqtt.qos"},
+		{"zero query timeout", []string{"-db-query-timeout", "0s"}, nil, "database.queryTimeout"},
+		{"bad log level", nil, map[string]string{"HOUND_LOG_LEVEL": "loud"}, "log.level"},
+		{"bad log format", []string{"-log-format", "xml"}, nil, "log.format"},
+		{"sample ratio out of range", nil, map[string]string{"HOUND_TRACE_SAMPLE_RATIO": "2"}, "tracing.sampleRatio"},
+		{"oidc without client", nil, map[string]string{"HOUND_OIDC_ISSUER": "https://id.example"}, "oidc.clientID"},
+		{"oidc short secret", nil, map[string]string{
+			"HOUND_OIDC_ISSUER":       "https://id.example",
+			"HOUND_OIDC_CLIENT_ID":    "hound",
+			"HOUND_OIDC_REDIRECT_URL": "https://hound.example/auth/callback",
+			"HOUND_SESSION_SECRET":    "short",
+		}, "sessionSecret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.args, env(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	path := writeConfig(t, "listen: \":7000\"\n")
+
+	if _, err := Load([]string{"-config", path}, env(nil)); err == nil {
+		t.Fatal("expected error for unknown key")
+	}
+}
+
+func TestShareSecretDefaultsToSessionSecret(t *testing.T) {
+	cfg, err := Load(nil, env(map[string]string{"HOUND_SESSION_SECRET": "s3cret"}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.ShareSecret != "s3cret" {
+		t.Fatalf("ShareSecret = %q, want session secret", cfg.ShareSecret)
+	}
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleTrainer Role = "trainer"
+	RoleViewer  Role = "viewer"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleOwner, RoleTrainer, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// CanView reports whether the role may see sessions and history.
+func (r Role) CanView() bool {
+	return r.Valid()
+}
+
+// CanTrain reports whether the role may start and control sessions.
+func (r Role) CanTrain() bool {
+	return r == RoleOwner || r == RoleTrainer
+}
+
+// CanManage reports whether the role may manage members and invitations.
+func (r Role) CanManage() bool {
+	return r == RoleOwner
+}
+
+// Household groups the people training the same dog. Every user also has a
+// personal household whose ID is their user ID, which is where sessions
+// recorded before households existed live.
+type Household struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Member struct {
+	HouseholdID string    `json:"householdId"`
+	UserID      string    `json:"userId"`
+	Role        Role      `json:"role"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+// Membership is a household as seen by one of its members.
+type Membership struct {
+	Household
+	Role Role `json:"role"`
+}
+
+type Invitation struct {
+	Token       string    `json:"token"`
+	HouseholdID string    `json:"householdId"`
+	Role        Role      `json:"role"`
+	CreatedBy   string    `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	AcceptedBy  string    `json:"acceptedBy,omitempty"`
+}
+
+func NewHousehold(name, createdBy string) *Household {
+	return &Household{
+		ID:        uuid.New().String(),
+		Name:      name,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+}
+
+func PersonalHousehold(userID string) *Household {
+	return &Household{
+		ID:        userID,
+		Name:      "Personal",
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (i *Invitation) Usable(now time.Time) bool {
+	return i.AcceptedBy == "" && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// PlanAction names a change made to a session's steps while it ran.
+type PlanAction string
+
+const (
+	PlanStepInserted PlanAction = "inserted"
+	PlanStepRemoved  PlanAction = "removed"
+	PlanStepResized  PlanAction = "resized"
+)
+
+// PlanChange records one edit to the plan of a live session. Index is the
+// position of the step when the change was made.
+type PlanChange struct {
+	Action           PlanAction
+	Index            int
+	Duration         int `json:",omitempty"`
+	PreviousDuration int `json:",omitempty"`
+	ChangedAt        time.Time
+}
+
+var (
+	ErrStepStarted     = errors.New("only steps that have not started can be changed")
+	ErrTargetStep      = errors.New("the last step is the target and cannot be removed")
+	ErrInvalidDuration = errors.New("step duration must be positive")
+)
+
+// InsertStep adds a step of duration seconds before pending step idx. The
+// target stays the last step, so steps can only be inserted before it. idx
+// must be a valid index.
+func (s *Session) InsertStep(idx, duration int, at time.Time) error {
+	if err := s.editable(idx); err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return ErrInvalidDuration
+	}
+
+	s.Steps = slices.Insert(s.Steps, idx, Step{Duration: duration, State: StatePending})
+	s.renumber(idx)
+	s.recordChange(PlanChange{Action: PlanStepInserted, Index: idx, Duration: duration, ChangedAt: at})
+	return nil
+}
+
+// RemoveStep drops pending step idx from the plan. The last step is the
+// target and cannot be removed.
+func (s *Session) RemoveStep(idx int, at time.Time) error {
+	if err := s.editable(idx); err != nil {
+		return err
+	}
+	if idx == len(s.Steps)-1 {
+		return ErrTargetStep
+	}
+
+	previous := s.Steps[idx].Duration
+	s.Steps = slices.Delete(s.Steps, idx, idx+1)
+	s.renumber(idx)
+	s.recordChange(PlanChange{Action: PlanStepRemoved, Index: idx, PreviousDuration: previous, ChangedAt: at})
+	return nil
+}
+
+// SetStepDuration changes how long pending step idx runs. Changing the last
+// step changes the session's target.
+func (s *Session) SetStepDuration(idx, duration int, at time.Time) error {
+	if err := s.editable(idx); err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return ErrInvalidDuration
+	}
+
+	step := &s.Steps[idx]
+	previous := step.Duration
+	step.Duration = duration
+	if idx == len(s.Steps)-1 {
+		s.TargetSec = duration
+	}
+	s.recordChange(PlanChange{Action: PlanStepResized, Index: idx, Duration: duration, PreviousDuration: previous, ChangedAt: at})
+	return nil
+}
+
+// editable reports whether the plan may change at step idx. Steps run in
+// order, so the steps from a pending one onwards are all pending.
+func (s *Session) editable(idx int) error {
+	if s.Steps[idx].State != StatePending {
+		return ErrStepStarted
+	}
+	return nil
+}
+
+func (s *Session) renumber(from int) {
+	for i := from; i < len(s.Steps); i++ {
+		s.Steps[i].Index = i
+	}
+}
+
+func (s *Session) recordChange(c PlanChange) {
+	s.PlanChanges = append(s.PlanChanges, c)
+}
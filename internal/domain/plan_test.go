@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionPlanEdits(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := &Session{
+		TargetSec: 60,
+		State:     StatePending,
+		Steps: []Step{
+			{Index: 0, Duration: 10, State: StatePending},
+			{Index: 1, Duration: 20, State: StatePending},
+			{Index: 2, Duration: 60, State: StatePending},
+		},
+	}
+	if err := s.StartStep(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.InsertStep(0, 5, at); !errors.Is(err, ErrStepStarted) {
+		t.Errorf("inserting before a started step err = %v, want ErrStepStarted", err)
+	}
+	if err := s.SetStepDuration(0, 5, at); !errors.Is(err, ErrStepStarted) {
+		t.Errorf("changing a started step err = %v, want ErrStepStarted", err)
+	}
+	if err := s.RemoveStep(2, at); !errors.Is(err, ErrTargetStep) {
+		t.Errorf("removing the target err = %v, want ErrTargetStep", err)
+	}
+	if err := s.SetStepDuration(1, 0, at); !errors.Is(err, ErrInvalidDuration) {
+		t.Errorf("zero duration err = %v, want ErrInvalidDuration", err)
+	}
+
+	if err := s.InsertStep(1, 15, at); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveStep(2, at); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetStepDuration(2, 45, at); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{10, 15, 45}
+	if len(s.Steps) != len(want) {
+		t.Fatalf("steps = %+v, want durations %v", s.Steps, want)
+	}
+	for i, step := range s.Steps {
+		if step.Index != i || step.Duration != want[i] {
+			t.Errorf("step %d = index %d, duration %d; want index %d, duration %d", i, step.Index, step.Duration, i, want[i])
+		}
+	}
+	if s.TargetSec != 45 {
+		t.Errorf("target = %d, want 45 after shortening the last step", s.TargetSec)
+	}
+
+	changes := []PlanChange{
+		{Action: PlanStepInserted, Index: 1, Duration: 15, ChangedAt: at},
+		{Action: PlanStepRemoved, Index: 2, PreviousDuration: 20, ChangedAt: at},
+		{Action: PlanStepResized, Index: 2, Duration: 45, PreviousDuration: 60, ChangedAt: at},
+	}
+	if len(s.PlanChanges) != len(changes) {
+		t.Fatalf("plan changes = %+v, want %+v", s.PlanChanges, changes)
+	}
+	for i := range changes {
+		if s.PlanChanges[i] != changes[i] {
+			t.Errorf("plan change %d = %+v, want %+v", i, s.PlanChanges[i], changes[i])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PushSubscription is a browser's registration for Web Push. Endpoint and
+// Keys are what the browser's PushSubscription.toJSON() returns.
+type PushSubscription struct {
+	Endpoint  string    `json:"endpoint"`
+	UserID    string    `json:"userId"`
+	Keys      PushKeys  `json:"keys"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PushKeys are the browser's keys for encrypting messages to it, base64url
+// encoded.
+type PushKeys struct {
+	// P256dh is the browser's P-256 public key, uncompressed.
+	P256dh string `json:"p256dh"`
+	// Auth is the 16-byte authentication secret.
+	Auth string `json:"auth"`
+}
+
+func (s PushSubscription) Validate() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("endpoint must be an https URL")
+	}
+	if !publicHost(u.Hostname()) {
+		return errors.New("endpoint must be on a public host")
+	}
+	if key, err := DecodePushKey(s.Keys.P256dh); err != nil || len(key) != 65 || key[0] != 4 {
+		return errors.New("p256dh must be an uncompressed P-256 public key")
+	}
+	if auth, err := DecodePushKey(s.Keys.Auth); err != nil || len(auth) != 16 {
+		return errors.New("auth must be 16 bytes")
+	}
+	return nil
+}
+
+// publicHost reports whether host may be a push service: the server posts
+// to the endpoint, so it must not reach the server's own network. Names are
+// checked again when connecting, see PublicAddr.
+func publicHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return PublicAddr(addr)
+	}
+	return true
+}
+
+// PublicAddr reports whether addr is outside of loopback, link-local and
+// private networks.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// DecodePushKey decodes base64url with or without padding, as browsers
+// differ in which they send.
+func DecodePushKey(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
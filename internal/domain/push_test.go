@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestPushSubscriptionValidate(t *testing.T) {
+	p256dh := base64.RawURLEncoding.EncodeToString(append([]byte{4}, make([]byte, 64)...))
+	auth := base64.RawURLEncoding.EncodeToString(make([]byte, 16))
+
+	tests := []struct {
+		name     string
+		endpoint string
+		valid    bool
+	}{
+		{"push service", "https://fcm.googleapis.com/fcm/send/abc", true},
+		{"public address", "https://203.0.113.7/push", true},
+		{"plain http", "http://fcm.googleapis.com/fcm/send/abc", false},
+		{"no host", "https:///push", false},
+		{"localhost", "https://localhost:8080/push", false},
+		{"localhost subdomain", "https://api.localhost/push", false},
+		{"loopback", "https://127.0.0.1/push", false},
+		{"ipv6 loopback", "https://[::1]/push", false},
+		{"link-local", "https://169.254.169.254/latest/meta-data", false},
+		{"ipv6 link-local", "https://[fe80::1]/push", false},
+		{"private", "https://10.0.0.5/push", false},
+		{"private 192.168", "https://192.168.1.1/push", false},
+		{"ipv6 unique local", "https://[fd00::1]/push", false},
+		{"mapped private", "https://[::ffff:10.0.0.5]/push", false},
+		{"unspecified", "https://0.0.0.0/push", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := PushSubscription{Endpoint: tt.endpoint, Keys: PushKeys{P256dh: p256dh, Auth: auth}}
+			if err := sub.Validate(); (err == nil) != tt.valid {
+				t.Fatalf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
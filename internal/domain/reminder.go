@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reminder nudges a user to train at a time of day on some days of the
+// week, in the user's time zone.
+type Reminder struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	// Days lists the weekdays the reminder fires on; empty means every day.
+	Days []time.Weekday `json:"days"`
+	// Time is the local time of day as HH:MM.
+	Time string `json:"time"`
+	// Timezone is an IANA zone name such as Europe/Stockholm.
+	Timezone  string    `json:"timezone"`
+	CreatedAt time.Time `json:"createdAt"`
+	// LastFiredAt is the occurrence the reminder last fired for.
+	LastFiredAt *time.Time `json:"lastFiredAt,omitempty"`
+	// StartSession starts a training session when the reminder fires, so
+	// it is ready when the user opens the app.
+	StartSession bool `json:"startSession"`
+}
+
+func NewReminder(userID string, days []time.Weekday, at, timezone string) (*Reminder, error) {
+	r := &Reminder{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Days:      days,
+		Time:      at,
+		Timezone:  timezone,
+		CreatedAt: time.Now(),
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reminder) Validate() error {
+	if _, _, err := r.clock(); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil || r.Timezone == "" {
+		return fmt.Errorf("unknown time zone %q", r.Timezone)
+	}
+	for _, d := range r.Days {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("invalid weekday %d", d)
+		}
+	}
+	return nil
+}
+
+func (r *Reminder) clock() (hour, minute int, err error) {
+	t, err := time.Parse("15:04", r.Time)
+	if err != nil {
+		return 0, 0, errors.New("time must be HH:MM")
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// Due returns the latest occurrence of the reminder at or before now. It
+// returns false if the reminder has not come up since it was created or
+// last fired.
+func (r *Reminder) Due(now time.Time) (time.Time, bool) {
+	since := r.CreatedAt
+	if r.LastFiredAt != nil {
+		since = *r.LastFiredAt
+	}
+
+	for offset := 0; offset >= -7; offset-- {
+		occ, ok := r.occurrence(now, offset)
+		if !ok || occ.After(now) {
+			continue
+		}
+		return occ, occ.After(since)
+	}
+	return time.Time{}, false
+}
+
+// Next returns the first occurrence of the reminder after now.
+func (r *Reminder) Next(now time.Time) (time.Time, bool) {
+	for offset := 0; offset <= 7; offset++ {
+		occ, ok := r.occurrence(now, offset)
+		if ok && occ.After(now) {
+			return occ, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// occurrence returns the reminder's time on the day offset days from now's
+// date in the reminder's zone, if it fires on that day.
+func (r *Reminder) occurrence(now time.Time, offset int) (time.Time, bool) {
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	hour, minute, err := r.clock()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	local := now.In(loc)
+	occ := time.Date(local.Year(), local.Month(), local.Day()+offset, hour, minute, 0, 0, loc)
+	if len(r.Days) == 0 {
+		return occ, true
+	}
+	for _, d := range r.Days {
+		if d == occ.Weekday() {
+			return occ, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReminderValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		reminder Reminder
+		valid    bool
+	}{
+		{"every day", Reminder{Time: "18:30", Timezone: "Europe/Stockholm"}, true},
+		{"weekdays", Reminder{Days: []time.Weekday{time.Monday, time.Friday}, Time: "07:00", Timezone: "UTC"}, true},
+		{"bad time", Reminder{Time: "25:00", Timezone: "UTC"}, false},
+		{"no time zone", Reminder{Time: "07:00"}, false},
+		{"unknown time zone", Reminder{Time: "07:00", Timezone: "Mars/Olympus"}, false},
+		{"bad weekday", Reminder{Days: []time.Weekday{7}, Time: "07:00", Timezone: "UTC"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.reminder.Validate(); (err == nil) != tt.valid {
+				t.Fatalf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestReminderOccurrences(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Saturday 2026-03-28, the day before summer time starts
+	saturday := time.Date(2026, 3, 28, 12, 0, 0, 0, stockholm)
+	r := Reminder{
+		Days:      []time.Weekday{time.Sunday, time.Wednesday},
+		Time:      "08:00",
+		Timezone:  "Europe/Stockholm",
+		CreatedAt: saturday.AddDate(0, 0, -14),
+	}
+
+	next, ok := r.Next(saturday)
+	if want := time.Date(2026, 3, 29, 8, 0, 0, 0, stockholm); !ok || !next.Equal(want) {
+		t.Fatalf("Next = %v, want %v", next, want)
+	}
+	if _, offset := next.Zone(); offset != 2*60*60 {
+		t.Fatalf("Sunday's reminder is at UTC%+d, want summer time", offset/3600)
+	}
+
+	due, ok := r.Due(saturday)
+	if want := time.Date(2026, 3, 25, 8, 0, 0, 0, stockholm); !ok || !due.Equal(want) {
+		t.Fatalf("Due = %v, %v; want Wednesday %v", due, ok, want)
+	}
+
+	r.LastFiredAt = &due
+	if _, ok := r.Due(saturday); ok {
+		t.Fatal("Due after firing Wednesday's occurrence = true, want false")
+	}
+}
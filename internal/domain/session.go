@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"errors"
 	"github.com/google/uuid"
 	"math/rand"
 	"time"
@@ -13,13 +14,54 @@ const (
 )
 
 type Session struct {
-	ID         string
-	UserID     string
-	TargetSec  int
-	Steps      []Step
-	CurrentIdx int
-	StartedAt  time.Time
-	Completed  bool
+	ID          string
+	UserID      string
+	HouseholdID string
+	TargetSec   int
+	Steps       []Step
+	CurrentIdx  int
+	StartedAt   time.Time
+	State       State
+	// Completed mirrors State == StateCompleted for existing clients.
+	Completed bool
+	// AutoAdvance, when set, starts each step after the first by itself.
+	AutoAdvance *AutoAdvance
+	// PlanChanges lists the edits made to the steps while the session ran.
+	PlanChanges []PlanChange
+}
+
+// AutoAdvance chains a session's steps: once a step completes the next one
+// starts after a rest of RestSec seconds, or a random number of seconds
+// between RestSec and MaxRestSec when MaxRestSec is larger.
+type AutoAdvance struct {
+	RestSec    int
+	MaxRestSec int
+}
+
+// maxRestSec caps the rest between steps. A resting session is not idle,
+// so a longer rest would keep it from ever being evicted.
+const maxRestSec = 3600
+
+func (a AutoAdvance) Validate() error {
+	if a.RestSec < 0 {
+		return errors.New("rest must not be negative")
+	}
+	if a.MaxRestSec != 0 && a.MaxRestSec < a.RestSec {
+		return errors.New("maximum rest must not be shorter than the rest")
+	}
+	if a.RestSec > maxRestSec || a.MaxRestSec > maxRestSec {
+		return errors.New("rest must not be longer than an hour")
+	}
+	return nil
+}
+
+// Rest picks the length of the rest before the next step.
+func (a AutoAdvance) Rest(r *rand.Rand) time.Duration {
+	sec := a.RestSec
+	if a.MaxRestSec > a.RestSec {
+		sec += r.Intn(a.MaxRestSec - a.RestSec + 1)
+	}
+	return time.Duration(sec) * time.Second
 }
 
 func warmupStepCount(targetSec int, r *rand.Rand) int {
@@ -57,12 +99,14 @@ func GenerateSteps(targetSec int, r *rand.Rand) []Step {
 		steps[i] = Step{
 			Index:    i,
 			Duration: r.Intn(maxWarmup) + 1,
+			State:    StatePending,
 		}
 	}
 
 	steps[warmupCount] = Step{
 		Index:    warmupCount,
 		Duration: targetSec,
+		State:    StatePending,
 	}
 
 	return steps
@@ -76,10 +120,12 @@ func NewSession(id string, userID string, targetSec int) *Session {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	return &Session{
-		ID:        id,
-		UserID:    userID,
-		TargetSec: targetSec,
-		Steps:     GenerateSteps(targetSec, r),
-		StartedAt: time.Now(),
+		ID:          id,
+		UserID:      userID,
+		HouseholdID: userID,
+		TargetSec:   targetSec,
+		Steps:       GenerateSteps(targetSec, r),
+		StartedAt:   time.Now(),
+		State:       StatePending,
 	}
 }
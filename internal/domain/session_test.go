@@ -1,8 +1,10 @@
 package domain
 
 import (
+	"math"
 	"math/rand"
 	"testing"
+	"time"
 )
 
 func TestMaxWarmupDuration(t *testing.T) {
@@ -108,3 +110,36 @@ func TestWarmupStepCountRanges(t *testing.T) {
 		})
 	}
 }
+
+func TestAutoAdvanceRest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	fixed := AutoAdvance{RestSec: 30}
+	if got := fixed.Rest(r); got != 30*time.Second {
+		t.Fatalf("fixed rest = %v, want 30s", got)
+	}
+
+	ranged := AutoAdvance{RestSec: 10, MaxRestSec: 20}
+	for range 100 {
+		if got := ranged.Rest(r); got < 10*time.Second || got > 20*time.Second {
+			t.Fatalf("ranged rest = %v, want between 10s and 20s", got)
+		}
+	}
+
+	for _, valid := range []AutoAdvance{{RestSec: 3600}, {RestSec: 0, MaxRestSec: 3600}} {
+		if err := valid.Validate(); err != nil {
+			t.Errorf("%+v: %v", valid, err)
+		}
+	}
+	for _, invalid := range []AutoAdvance{
+		{RestSec: -1},
+		{RestSec: 20, MaxRestSec: 10},
+		{RestSec: 3601},
+		{RestSec: 60, MaxRestSec: 3601},
+		{RestSec: math.MaxInt},
+	} {
+		if invalid.Validate() == nil {
+			t.Errorf("%+v should not validate", invalid)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShareLink grants read-only access to a single live session without an
+// account.
+type ShareLink struct {
+	ID          string     `json:"id"`
+	SessionID   string     `json:"sessionId"`
+	HouseholdID string     `json:"householdId"`
+	CreatedBy   string     `json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+}
+
+func NewShareLink(s *Session, createdBy string, ttl time.Duration) *ShareLink {
+	now := time.Now()
+	return &ShareLink{
+		ID:          uuid.New().String(),
+		SessionID:   s.ID,
+		HouseholdID: s.HouseholdID,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+}
+
+func (l *ShareLink) Active(now time.Time) bool {
+	return l.RevokedAt == nil && now.Before(l.ExpiresAt)
+}
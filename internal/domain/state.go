@@ -0,0 +1,231 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// State is where a session or one of its steps is in its lifecycle.
+type State string
+
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StatePaused    State = "paused"
+	StateCompleted State = "completed"
+	StateAborted   State = "aborted"
+)
+
+// Final reports whether nothing can happen to the session or step anymore.
+func (s State) Final() bool {
+	return s == StateCompleted || s == StateAborted
+}
+
+// sessionTransitions lists the states a session may move to from each
+// state. A session is running while one of its steps is and paused between
+// steps. Completed and aborted are final.
+var sessionTransitions = map[State][]State{
+	StatePending: {StateRunning, StateCompleted, StateAborted},
+	StateRunning: {StatePaused, StateCompleted, StateAborted},
+	StatePaused:  {StateRunning, StateCompleted, StateAborted},
+}
+
+// stepTransitions lists the states a step may move to from each state. A
+// step completes by running for its full duration or by being stopped
+// early, and is aborted when the session ends first or it stays paused too
+// long.
+var stepTransitions = map[State][]State{
+	StatePending: {StateRunning, StateAborted},
+	StateRunning: {StatePaused, StateCompleted, StateAborted},
+	StatePaused:  {StateRunning, StateCompleted, StateAborted},
+}
+
+var (
+	// ErrInvalidTransition is matched by every TransitionError.
+	ErrInvalidTransition = errors.New("invalid state transition")
+	ErrStepOutOfOrder    = errors.New("earlier steps are not finished")
+)
+
+// TransitionError reports a change of state the transition tables do not
+// allow.
+type TransitionError struct {
+	// Subject is "session" or "step N".
+	Subject string
+	From    State
+	To      State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s cannot go from %s to %s", e.Subject, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+func checkTransition(table map[State][]State, subject string, from, to State) error {
+	if !slices.Contains(table[from], to) {
+		return &TransitionError{Subject: subject, From: from, To: to}
+	}
+	return nil
+}
+
+func (s *Session) setState(to State) {
+	s.State = to
+	s.Completed = to == StateCompleted
+}
+
+func (st *Step) setState(to State) {
+	st.State = to
+	st.Completed = to == StateCompleted
+}
+
+// StartStep moves pending step idx and the session to running. Steps run
+// one at a time and in order, so every earlier step must be completed or
+// aborted. idx must be a valid index.
+func (s *Session) StartStep(idx int) error {
+	return s.run(idx, StatePending)
+}
+
+// ResumeStep moves paused step idx and the session back to running.
+func (s *Session) ResumeStep(idx int) error {
+	return s.run(idx, StatePaused)
+}
+
+func (s *Session) run(idx int, from State) error {
+	step := &s.Steps[idx]
+	// pending and paused steps may both run, but starting a paused step or
+	// resuming a pending one is a mistake
+	if step.State != from {
+		return &TransitionError{Subject: stepSubject(idx), From: step.State, To: StateRunning}
+	}
+	if err := checkTransition(sessionTransitions, "session", s.State, StateRunning); err != nil {
+		return err
+	}
+	for _, earlier := range s.Steps[:idx] {
+		if !earlier.State.Final() {
+			return ErrStepOutOfOrder
+		}
+	}
+
+	step.setState(StateRunning)
+	s.setState(StateRunning)
+	s.CurrentIdx = idx
+	return nil
+}
+
+// PauseStep moves running step idx and the session to paused.
+func (s *Session) PauseStep(idx int) error {
+	step := &s.Steps[idx]
+	if err := checkTransition(stepTransitions, stepSubject(idx), step.State, StatePaused); err != nil {
+		return err
+	}
+
+	step.setState(StatePaused)
+	s.setState(StatePaused)
+	return nil
+}
+
+// CompleteStep records that step idx finished, by running for its full
+// duration or by being stopped early. The session completes with its last
+// step and is paused otherwise.
+func (s *Session) CompleteStep(idx int) error {
+	step := &s.Steps[idx]
+	if err := checkTransition(stepTransitions, stepSubject(idx), step.State, StateCompleted); err != nil {
+		return err
+	}
+
+	step.setState(StateCompleted)
+	if idx == len(s.Steps)-1 {
+		s.setState(StateCompleted)
+	} else {
+		s.setState(StatePaused)
+	}
+	return nil
+}
+
+// AbortStep gives up on step idx without ending the session, which is left
+// paused so the next step can be started.
+func (s *Session) AbortStep(idx int) error {
+	step := &s.Steps[idx]
+	if err := checkTransition(stepTransitions, stepSubject(idx), step.State, StateAborted); err != nil {
+		return err
+	}
+	if s.State != StatePaused {
+		if err := checkTransition(sessionTransitions, "session", s.State, StatePaused); err != nil {
+			return err
+		}
+	}
+
+	step.setState(StateAborted)
+	s.setState(StatePaused)
+	return nil
+}
+
+// Complete records that the user finished the session. Steps that had not
+// completed are aborted. Completing a completed session does nothing.
+func (s *Session) Complete() error {
+	return s.end(StateCompleted)
+}
+
+// Abort ends the session without the user completing it, aborting its
+// unfinished steps.
+func (s *Session) Abort() error {
+	return s.end(StateAborted)
+}
+
+func (s *Session) end(to State) error {
+	if s.State == to {
+		return nil
+	}
+	if err := checkTransition(sessionTransitions, "session", s.State, to); err != nil {
+		return err
+	}
+
+	for i := range s.Steps {
+		if !s.Steps[i].State.Final() {
+			s.Steps[i].setState(StateAborted)
+		}
+	}
+	s.setState(to)
+	return nil
+}
+
+// Restore prepares a session loaded from a checkpoint: steps that were
+// running come back paused, and sessions checkpointed before states were
+// tracked get states derived from their steps' progress.
+func (s *Session) Restore() {
+	started := false
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		switch {
+		case step.State == StateRunning:
+			step.setState(StatePaused)
+		case step.State != "":
+		case step.Completed:
+			step.setState(StateCompleted)
+		case step.Elapsed > 0:
+			step.setState(StatePaused)
+		default:
+			step.setState(StatePending)
+		}
+		started = started || step.State != StatePending
+	}
+
+	switch {
+	case s.State == StateRunning:
+		s.setState(StatePaused)
+	case s.State != "":
+	case s.Completed:
+		s.setState(StateCompleted)
+	case started:
+		s.setState(StatePaused)
+	default:
+		s.setState(StatePending)
+	}
+}
+
+func stepSubject(idx int) string {
+	return fmt.Sprintf("step %d", idx)
+}
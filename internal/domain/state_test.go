@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestSession() *Session {
+	return &Session{
+		State: StatePending,
+		Steps: []Step{
+			{Index: 0, Duration: 10, State: StatePending},
+			{Index: 1, Duration: 10, State: StatePending},
+		},
+	}
+}
+
+func TestSessionTransitions(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  func(s *Session) error
+		want error
+	}{
+		{"start first step", func(s *Session) error {
+			return s.StartStep(0)
+		}, nil},
+		{"start out of order", func(s *Session) error {
+			return s.StartStep(1)
+		}, ErrStepOutOfOrder},
+		{"start a running step", func(s *Session) error {
+			s.StartStep(0)
+			return s.StartStep(0)
+		}, ErrInvalidTransition},
+		{"start next while earlier is paused", func(s *Session) error {
+			s.StartStep(0)
+			s.PauseStep(0)
+			return s.StartStep(1)
+		}, ErrStepOutOfOrder},
+		{"resume a paused step", func(s *Session) error {
+			s.StartStep(0)
+			s.PauseStep(0)
+			return s.ResumeStep(0)
+		}, nil},
+		{"start a paused step", func(s *Session) error {
+			s.StartStep(0)
+			s.PauseStep(0)
+			return s.StartStep(0)
+		}, ErrInvalidTransition},
+		{"resume a pending step", func(s *Session) error {
+			return s.ResumeStep(0)
+		}, ErrInvalidTransition},
+		{"start next after an aborted step", func(s *Session) error {
+			s.StartStep(0)
+			s.PauseStep(0)
+			s.AbortStep(0)
+			return s.StartStep(1)
+		}, nil},
+		{"resume an aborted step", func(s *Session) error {
+			s.StartStep(0)
+			s.PauseStep(0)
+			s.AbortStep(0)
+			return s.ResumeStep(0)
+		}, ErrInvalidTransition},
+		{"pause a pending step", func(s *Session) error {
+			return s.PauseStep(0)
+		}, ErrInvalidTransition},
+		{"restart a completed step", func(s *Session) error {
+			s.StartStep(0)
+			s.CompleteStep(0)
+			return s.StartStep(0)
+		}, ErrInvalidTransition},
+		{"stop a paused step early", func(s *Session) error {
+			s.StartStep(0)
+			s.PauseStep(0)
+			return s.CompleteStep(0)
+		}, nil},
+		{"complete a pending step", func(s *Session) error {
+			return s.CompleteStep(0)
+		}, ErrInvalidTransition},
+		{"start a step in a completed session", func(s *Session) error {
+			s.Complete()
+			return s.StartStep(0)
+		}, ErrInvalidTransition},
+		{"complete twice", func(s *Session) error {
+			s.Complete()
+			return s.Complete()
+		}, nil},
+		{"complete an aborted session", func(s *Session) error {
+			s.Abort()
+			return s.Complete()
+		}, ErrInvalidTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ops(newTestSession())
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	s := newTestSession()
+
+	if err := s.StartStep(0); err != nil {
+		t.Fatal(err)
+	}
+	if s.State != StateRunning || s.Steps[0].State != StateRunning {
+		t.Fatalf("after start: session %s, step %s", s.State, s.Steps[0].State)
+	}
+	if err := s.CompleteStep(0); err != nil {
+		t.Fatal(err)
+	}
+	if s.State != StatePaused || !s.Steps[0].Completed {
+		t.Fatalf("after first step: session %s, step %+v", s.State, s.Steps[0])
+	}
+	if err := s.StartStep(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CompleteStep(1); err != nil {
+		t.Fatal(err)
+	}
+	if s.State != StateCompleted || !s.Completed {
+		t.Fatalf("after last step: session %s, completed %v", s.State, s.Completed)
+	}
+
+	var terr *TransitionError
+	if err := s.Abort(); !errors.As(err, &terr) || terr.From != StateCompleted || terr.To != StateAborted {
+		t.Fatalf("abort after completion err = %v", err)
+	}
+}
+
+func TestSessionAbortAbortsUnfinishedSteps(t *testing.T) {
+	s := newTestSession()
+	s.StartStep(0)
+	s.CompleteStep(0)
+	s.StartStep(1)
+
+	if err := s.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if s.State != StateAborted || s.Steps[0].State != StateCompleted || s.Steps[1].State != StateAborted {
+		t.Fatalf("session %s, steps %s and %s", s.State, s.Steps[0].State, s.Steps[1].State)
+	}
+}
+
+func TestSessionRestore(t *testing.T) {
+	// checkpointed before states were tracked, with the second step
+	// running
+	s := &Session{
+		Steps: []Step{
+			{Index: 0, Completed: true},
+			{Index: 1, Elapsed: 4},
+			{Index: 2},
+		},
+	}
+	s.Restore()
+
+	if s.State != StatePaused {
+		t.Errorf("session state = %s, want paused", s.State)
+	}
+	want := []State{StateCompleted, StatePaused, StatePending}
+	for i, step := range s.Steps {
+		if step.State != want[i] {
+			t.Errorf("step %d state = %s, want %s", i, step.State, want[i])
+		}
+	}
+
+	running := newTestSession()
+	running.StartStep(0)
+	running.Restore()
+	if running.State != StatePaused || running.Steps[0].State != StatePaused {
+		t.Errorf("resumed running session %s, step %s, want both paused", running.State, running.Steps[0].State)
+	}
+}
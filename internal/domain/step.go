@@ -6,5 +6,13 @@ type Step struct {
 	Index     int
 	Duration  int
 	StartedAt time.Time
+	State     State
+	// Completed mirrors State == StateCompleted for existing clients.
 	Completed bool
+	// Elapsed is the seconds run so far, not counting pauses. It is kept
+	// up to date whenever the step is not running, and filled in for a
+	// running step when a session is snapshotted.
+	Elapsed int
+	// PausedSec is the seconds the step has spent paused.
+	PausedSec int
 }
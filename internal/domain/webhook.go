@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEvent string
+
+const (
+	WebhookSessionStarted   WebhookEvent = "session.started"
+	WebhookStepStarted      WebhookEvent = "step.started"
+	WebhookStepCompleted    WebhookEvent = "step.completed"
+	WebhookSessionCompleted WebhookEvent = "session.completed"
+	WebhookSessionSaved     WebhookEvent = "session.saved"
+	// WebhookPing is only sent by test-firing a webhook.
+	WebhookPing WebhookEvent = "ping"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to.
+var WebhookEvents = []WebhookEvent{
+	WebhookSessionStarted,
+	WebhookStepStarted,
+	WebhookStepCompleted,
+	WebhookSessionCompleted,
+	WebhookSessionSaved,
+}
+
+// Webhook posts a user's session lifecycle events to a URL, signed with
+// Secret so the receiver can tell they came from Hound.
+type Webhook struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	URL    string `json:"url"`
+	// Events lists the events sent; empty means all of them.
+	Events    []WebhookEvent `json:"events"`
+	Secret    string         `json:"-"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// NewWebhook returns a webhook with a new random signing secret.
+func NewWebhook(userID, target string, events []WebhookEvent) (*Webhook, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	w := &Webhook{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		URL:       target,
+		Events:    events,
+		Secret:    "whsec_" + hex.EncodeToString(secret),
+		CreatedAt: time.Now(),
+	}
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	for _, ev := range w.Events {
+		if !slices.Contains(WebhookEvents, ev) {
+			return fmt.Errorf("unknown event %q", ev)
+		}
+	}
+	return nil
+}
+
+// Subscribes reports whether the webhook is sent ev. Every webhook can be
+// pinged.
+func (w *Webhook) Subscribes(ev WebhookEvent) bool {
+	return ev == WebhookPing || len(w.Events) == 0 || slices.Contains(w.Events, ev)
+}
+
+// WebhookDelivery records one attempt at delivering an event to a webhook.
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhookId"`
+	// EventID identifies the event; retries of it share it, so receivers
+	// can drop duplicates.
+	EventID string       `json:"eventId"`
+	Event   WebhookEvent `json:"event"`
+	Attempt int          `json:"attempt"`
+	// StatusCode is the receiver's answer, zero if none was received.
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Success     bool      `json:"success"`
+	DurationMs  int64     `json:"durationMs"`
+	DeliveredAt time.Time `json:"deliveredAt"`
+}
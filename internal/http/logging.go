@@ -0,0 +1,48 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/hperssn/hound/internal/logging"
+)
+
+// RequestLogger logs one record per request once it has been served. It
+// expects middleware.RequestID to run first and echoes the ID back in the
+// X-Request-Id header so clients can quote it in bug reports.
+func RequestLogger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			reqID := middleware.GetReqID(r.Context())
+			if reqID != "" {
+				w.Header().Set(middleware.RequestIDHeader, reqID)
+			}
+			ctx := logging.With(r.Context(), slog.String(logging.RequestIDKey, reqID))
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(ctx, level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+			)
+		})
+	}
+}
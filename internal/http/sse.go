@@ -4,14 +4,46 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
-	"github.com/go-chi/chi/v5"
+	"github.com/hperssn/hound/internal/metrics"
 	"github.com/hperssn/hound/internal/runner"
 )
 
-func StreamSessionEvents(manager *runner.SessionManager) http.HandlerFunc {
+// SessionResolver returns the ID of the session a request may stream, or
+// false after writing an error response if access is denied.
+type SessionResolver func(w http.ResponseWriter, r *http.Request) (string, bool)
+
+type streamConfig struct {
+	checkEvery time.Duration
+	allowed    func(r *http.Request) bool
+}
+
+type StreamOption func(*streamConfig)
+
+// WithAccessCheck repeats allowed every interval while a stream is open and
+// ends the stream once it fails, so access granted when the stream opened
+// can be taken back.
+func WithAccessCheck(interval time.Duration, allowed func(r *http.Request) bool) StreamOption {
+	return func(c *streamConfig) {
+		c.checkEvery = interval
+		c.allowed = allowed
+	}
+}
+
+// StreamSessionEvents streams a session's events until the client goes
+// away, the session stops or the server shuts down.
+func StreamSessionEvents(manager *runner.SessionManager, resolve SessionResolver, mt metrics.Metrics, opts ...StreamOption) http.HandlerFunc {
+	var cfg streamConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
+		id, ok := resolve(w, r)
+		if !ok {
+			return
+		}
 
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
@@ -24,26 +56,71 @@ func StreamSessionEvents(manager *runner.SessionManager) http.HandlerFunc {
 			return
 		}
 
-		events, ok := manager.Events(id)
+		events, unsubscribe, ok := manager.Subscribe(id)
 		if !ok {
 			http.Error(w, "session not found", http.StatusNotFound)
 			return
 		}
+		defer unsubscribe()
+
+		mt.SSEClientConnected()
+		defer mt.SSEClientDisconnected()
+
+		// send the headers now so the client sees the stream open before
+		// the first event
+		flusher.Flush()
+
+		var recheck <-chan time.Time
+		if cfg.allowed != nil {
+			ticker := time.NewTicker(cfg.checkEvery)
+			defer ticker.Stop()
+			recheck = ticker.C
+		}
 
 		for {
 			select {
+			case <-recheck:
+				if !cfg.allowed(r) {
+					return
+				}
+
 			case step, ok := <-events:
 				if !ok {
 					return
 				}
 
-				data, _ := json.Marshal(step)
-				fmt.Fprintf(w, "data: %s\n\n", data)
+				writeEvent(w, step)
 				flusher.Flush()
 
+				if step.Type == runner.EventServerRestarting {
+					return
+				}
+
+			case <-manager.Done():
+				// deliver anything published during shutdown, then let
+				// the server close the connection
+				for {
+					select {
+					case step, ok := <-events:
+						if !ok {
+							flusher.Flush()
+							return
+						}
+						writeEvent(w, step)
+					default:
+						flusher.Flush()
+						return
+					}
+				}
+
 			case <-r.Context().Done():
 				return
 			}
 		}
 	}
 }
+
+func writeEvent(w http.ResponseWriter, event runner.StepEvent) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
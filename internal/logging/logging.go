@@ -0,0 +1,98 @@
+// Package logging builds the server's slog logger and carries per-request
+// attributes through contexts, so that every record logged while handling a
+// request is tagged with its request, user and session IDs.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+const (
+	RequestIDKey = "request_id"
+	UserIDKey    = "user_id"
+	SessionIDKey = "session_id"
+)
+
+// New returns a logger writing to w. format is "text" or "json"; level is
+// one of debug, info, warn or error.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "text", "":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+
+	return slog.New(NewHandler(h)), nil
+}
+
+type attrsKey struct{}
+
+// attrs is shared by every context derived from the one it was stored in,
+// so attributes added deep inside a handler also show up on the request
+// log written by the outermost middleware.
+type attrs struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// With records attributes on ctx for every later log call made with it.
+// If ctx already carries attributes they are extended in place.
+func With(ctx context.Context, as ...slog.Attr) context.Context {
+	if a, ok := ctx.Value(attrsKey{}).(*attrs); ok {
+		a.mu.Lock()
+		a.attrs = append(a.attrs, as...)
+		a.mu.Unlock()
+		return ctx
+	}
+	return context.WithValue(ctx, attrsKey{}, &attrs{attrs: as})
+}
+
+func fromContext(ctx context.Context) []slog.Attr {
+	a, ok := ctx.Value(attrsKey{}).(*attrs)
+	if !ok {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]slog.Attr(nil), a.attrs...)
+}
+
+// Handler adds the attributes stored with With to each record.
+type Handler struct {
+	slog.Handler
+}
+
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		r.AddAttrs(fromContext(ctx)...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(as []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(as)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
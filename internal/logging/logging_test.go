@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewRejectsInvalidSettings(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "loud", "text"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if _, err := New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestNewFiltersByLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("quiet")
+	logger.Warn("loud")
+
+	if strings.Contains(buf.String(), "quiet") || !strings.Contains(buf.String(), "loud") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestContextAttrsReachOuterRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "debug", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := With(context.Background(), slog.String(RequestIDKey, "req-1"))
+
+	// attributes added further down the call chain, on a derived context,
+	// must also appear on records logged with the original one
+	inner := context.WithValue(ctx, struct{}{}, nil)
+	With(inner, slog.String(UserIDKey, "alice"), slog.String(SessionIDKey, "s-1"))
+
+	logger.InfoContext(ctx, "request")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	for key, want := range map[string]string{
+		RequestIDKey: "req-1",
+		UserIDKey:    "alice",
+		SessionIDKey: "s-1",
+	} {
+		if rec[key] != want {
+			t.Errorf("%s = %v, want %s", key, rec[key], want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import "time"
+
+// Metrics records operational measurements. Implementations must be safe
+// for concurrent use.
+type Metrics interface {
+	SetActiveSessions(n int)
+
+	// StepStarted and StepStopped bracket each running step goroutine.
+	StepStarted()
+	StepStopped()
+
+	SSEClientConnected()
+	SSEClientDisconnected()
+
+	// EventDropped counts events a subscriber was too slow to receive.
+	EventDropped()
+
+	ObserveHTTPRequest(method, route string, status int, d time.Duration)
+
+	ObserveQuery(method string, d time.Duration, err error)
+}
+
+// Nop discards all measurements.
+type Nop struct{}
+
+func (Nop) SetActiveSessions(int)                                 {}
+func (Nop) StepStarted()                                          {}
+func (Nop) StepStopped()                                          {}
+func (Nop) SSEClientConnected()                                   {}
+func (Nop) SSEClientDisconnected()                                {}
+func (Nop) EventDropped()                                         {}
+func (Nop) ObserveHTTPRequest(string, string, int, time.Duration) {}
+func (Nop) ObserveQuery(string, time.Duration, error)             {}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus exports measurements through its own registry, so tests can
+// create as many as they need without colliding on the default one.
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	activeSessions prometheus.Gauge
+	runningSteps   prometheus.Gauge
+	sseClients     prometheus.Gauge
+	droppedEvents  prometheus.Counter
+	httpDuration   *prometheus.HistogramVec
+	queryDuration  *prometheus.HistogramVec
+}
+
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		activeSessions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "hound_active_sessions",
+			Help: "Sessions held in memory by the session manager.",
+		}),
+		runningSteps: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "hound_running_steps",
+			Help: "Step timer goroutines currently running.",
+		}),
+		sseClients: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "hound_sse_clients",
+			Help: "Connected event stream clients.",
+		}),
+		droppedEvents: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "hound_dropped_events_total",
+			Help: "Events not delivered because a subscriber's buffer was full.",
+		}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "hound_http_request_duration_seconds",
+			Help:    "HTTP request latency by route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "hound_repository_query_duration_seconds",
+			Help:    "Repository call latency by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "result"}),
+	}
+
+	p.registry.MustRegister(
+		p.activeSessions,
+		p.runningSteps,
+		p.sseClients,
+		p.droppedEvents,
+		p.httpDuration,
+		p.queryDuration,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+
+	return p
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{})
+}
+
+func (p *Prometheus) Registry() *prometheus.Registry {
+	return p.registry
+}
+
+func (p *Prometheus) SetActiveSessions(n int) {
+	p.activeSessions.Set(float64(n))
+}
+
+func (p *Prometheus) StepStarted() {
+	p.runningSteps.Inc()
+}
+
+func (p *Prometheus) StepStopped() {
+	p.runningSteps.Dec()
+}
+
+func (p *Prometheus) SSEClientConnected() {
+	p.sseClients.Inc()
+}
+
+func (p *Prometheus) SSEClientDisconnected() {
+	p.sseClients.Dec()
+}
+
+func (p *Prometheus) EventDropped() {
+	p.droppedEvents.Inc()
+}
+
+func (p *Prometheus) ObserveHTTPRequest(method, route string, status int, d time.Duration) {
+	p.httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(d.Seconds())
+}
+
+func (p *Prometheus) ObserveQuery(method string, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	p.queryDuration.WithLabelValues(method, result).Observe(d.Seconds())
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPrometheusRecords(t *testing.T) {
+	p := NewPrometheus()
+
+	p.SetActiveSessions(3)
+	p.StepStarted()
+	p.StepStarted()
+	p.StepStopped()
+	p.SSEClientConnected()
+	p.EventDropped()
+	p.EventDropped()
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"active sessions", testutil.ToFloat64(p.activeSessions), 3},
+		{"running steps", testutil.ToFloat64(p.runningSteps), 1},
+		{"sse clients", testutil.ToFloat64(p.sseClients), 1},
+		{"dropped events", testutil.ToFloat64(p.droppedEvents), 2},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	p.ObserveHTTPRequest("GET", "/sessions/{id}", 200, 10*time.Millisecond)
+	p.ObserveQuery("SaveSession", time.Millisecond, nil)
+	p.ObserveQuery("SaveSession", time.Millisecond, errors.New("boom"))
+
+	if n := testutil.CollectAndCount(p.httpDuration); n != 1 {
+		t.Errorf("http series = %d, want 1", n)
+	}
+	if n := testutil.CollectAndCount(p.queryDuration); n != 2 {
+		t.Errorf("query series = %d, want ok and error", n)
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	p := NewPrometheus()
+	p.SetActiveSessions(1)
+
+	rec := httptest.NewRecorder()
+	p.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "hound_active_sessions 1") {
+		t.Fatalf("active sessions missing from scrape:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,156 @@
+// Package mqtt publishes the runner's session events to an MQTT broker, so
+// devices such as smart lights can follow a training session.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/hperssn/hound/internal/config"
+	"github.com/hperssn/hound/internal/runner"
+)
+
+const (
+	// publishTimeout is how long a QoS 1 or 2 message may wait for the
+	// broker's acknowledgement before it is no longer watched for errors.
+	// The client keeps trying to deliver it.
+	publishTimeout = 30 * time.Second
+
+	disconnectQuiesce = 250 // milliseconds
+)
+
+// Event names for the events that carry no type on the runner's stream.
+const (
+	EventTick             = "tick"
+	EventSessionCompleted = "session_completed"
+)
+
+// Message is the JSON payload published for each event: the event as sent
+// to the browser, named, and with the session it belongs to.
+type Message struct {
+	Event     string `json:"event"`
+	SessionID string `json:"sessionId"`
+	runner.StepEvent
+}
+
+// Publisher forwards session events to the broker. The client reconnects
+// on its own after losing the broker; QoS 0 events published meanwhile are
+// dropped, QoS 1 and 2 events are sent once it is back, not necessarily in
+// order. Ticks are always published at QoS 0: one a second per session
+// would otherwise pile up in the client while the broker is away.
+type Publisher struct {
+	client paho.Client
+	topic  string
+	qos    byte
+	log    *slog.Logger
+}
+
+func New(cfg config.MQTTConfig, log *slog.Logger) *Publisher {
+	p := &Publisher{
+		topic: cfg.Topic,
+		qos:   byte(cfg.QoS),
+		log:   log.With("broker", cfg.Broker),
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		// keep the messages published before the first connection, and
+		// resend unacknowledged ones after reconnecting
+		SetCleanSession(false).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetMaxReconnectInterval(cfg.MaxReconnectInterval).
+		SetOnConnectHandler(func(paho.Client) {
+			p.log.Info("connected to mqtt broker")
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			p.log.Warn("lost connection to mqtt broker", "err", err)
+		}).
+		SetReconnectingHandler(func(paho.Client, *paho.ClientOptions) {
+			p.log.Debug("reconnecting to mqtt broker")
+		})
+	p.client = paho.NewClient(opts)
+	return p
+}
+
+// Run publishes events until ctx is done, and then the events already
+// queued. It does not wait for the broker to be reachable: the client keeps
+// connecting in the background.
+func (p *Publisher) Run(ctx context.Context, events <-chan runner.SessionEvent) {
+	p.client.Connect()
+	defer p.client.Disconnect(disconnectQuiesce)
+
+	for {
+		select {
+		case ev := <-events:
+			p.publish(ev)
+		case <-ctx.Done():
+			for {
+				select {
+				case ev := <-events:
+					p.publish(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *Publisher) publish(ev runner.SessionEvent) {
+	name := eventName(ev.StepEvent)
+	payload, err := json.Marshal(Message{Event: name, SessionID: ev.SessionID, StepEvent: ev.StepEvent})
+	if err != nil {
+		p.log.Error("failed to encode mqtt message", "err", err)
+		return
+	}
+
+	qos := p.qos
+	if name == EventTick {
+		qos = 0
+	}
+	token := p.client.Publish(p.topicFor(ev, name), qos, false, payload)
+	// waiting here would hold up every later event while the broker is
+	// away
+	go func() {
+		if token.WaitTimeout(publishTimeout) && token.Error() != nil {
+			p.log.Debug("failed to publish mqtt message", "session_id", ev.SessionID, "event", name, "err", token.Error())
+		}
+	}()
+}
+
+// topicFor fills in the topic template. Values are kept to one topic level
+// and free of wildcards.
+func (p *Publisher) topicFor(ev runner.SessionEvent, name string) string {
+	return strings.NewReplacer(
+		"{user}", topicLevel(ev.UserID),
+		"{session}", topicLevel(ev.SessionID),
+		"{event}", name,
+	).Replace(p.topic)
+}
+
+func topicLevel(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(s)
+}
+
+// eventName names an event. The runner sends timer ticks untyped, and ends
+// a session with an empty event; ticks always have run for a second.
+func eventName(ev runner.StepEvent) string {
+	switch {
+	case ev.Type != "":
+		return ev.Type
+	case ev.Elapsed == 0:
+		return EventSessionCompleted
+	default:
+		return EventTick
+	}
+}
@@ -0,0 +1,226 @@
+package mqtt_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/hperssn/hound/internal/config"
+	"github.com/hperssn/hound/internal/mqtt"
+	"github.com/hperssn/hound/internal/runner"
+)
+
+type message struct {
+	topic   string
+	qos     byte
+	payload []byte
+}
+
+// broker is an embedded MQTT broker that records what clients publish.
+type broker struct {
+	server *mochi.Server
+	addr   string
+
+	mu       sync.Mutex
+	messages []message
+}
+
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+
+	b := &broker{server: mochi.New(&mochi.Options{Logger: slog.New(slog.DiscardHandler)})}
+	if err := b.server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.server.AddHook(&recorder{b: b}, nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := b.server.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.server.Close() })
+	b.addr = tcp.Address()
+	return b
+}
+
+// recorder is the broker hook that keeps every message published to it.
+type recorder struct {
+	mochi.HookBase
+	b *broker
+}
+
+func (r *recorder) ID() string { return "recorder" }
+
+func (r *recorder) Provides(hook byte) bool { return hook == mochi.OnPublished }
+
+func (r *recorder) OnPublished(_ *mochi.Client, pk packets.Packet) {
+	r.b.mu.Lock()
+	defer r.b.mu.Unlock()
+	r.b.messages = append(r.b.messages, message{
+		topic:   pk.TopicName,
+		qos:     pk.FixedHeader.Qos,
+		payload: slices.Clone(pk.Payload),
+	})
+}
+
+func (b *broker) url() string {
+	return "tcp://" + b.addr
+}
+
+func (b *broker) dropConnections() {
+	for _, cl := range b.server.Clients.GetAll() {
+		cl.Stop(errors.New("dropped by test"))
+	}
+}
+
+func (b *broker) messagesSoFar() []message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]message(nil), b.messages...)
+}
+
+// waitMessages waits for n messages and returns them.
+func (b *broker) waitMessages(t *testing.T, n int) []message {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		messages := b.messagesSoFar()
+		if len(messages) >= n || time.Now().After(deadline) {
+			if len(messages) != n {
+				t.Fatalf("broker received %d messages, want %d: %+v", len(messages), n, messages)
+			}
+			return messages
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func runPublisher(t *testing.T, cfg config.MQTTConfig) chan<- runner.SessionEvent {
+	t.Helper()
+
+	if cfg.ClientID == "" {
+		cfg.ClientID = "hound-test"
+	}
+	if cfg.MaxReconnectInterval == 0 {
+		cfg.MaxReconnectInterval = time.Second
+	}
+	events := make(chan runner.SessionEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		mqtt.New(cfg, slog.New(slog.DiscardHandler)).Run(ctx, events)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return events
+}
+
+func TestPublisher(t *testing.T) {
+	b := newBroker(t)
+	events := runPublisher(t, config.MQTTConfig{Broker: b.url(), Topic: "hound/{user}/{session}/{event}", QoS: 1})
+
+	events <- runner.SessionEvent{SessionID: "s1", UserID: "alice", StepEvent: runner.StepEvent{Type: runner.EventRestStarted, Index: 1, Rest: 30}}
+	events <- runner.SessionEvent{SessionID: "s/2", UserID: "bob+", StepEvent: runner.StepEvent{}}
+	// ticks are dropped until the client is connected
+	b.waitMessages(t, 2)
+	events <- runner.SessionEvent{SessionID: "s1", UserID: "alice", StepEvent: runner.StepEvent{Index: 0, Elapsed: 1}}
+
+	// messages held back until the client connected may be sent in any order
+	received := make(map[string]mqtt.Message)
+	for _, m := range b.waitMessages(t, 3) {
+		var got mqtt.Message
+		if err := json.Unmarshal(m.payload, &got); err != nil {
+			t.Fatalf("payload %s: %v", m.payload, err)
+		}
+		// ticks are not worth queueing while the broker is away
+		wantQoS := byte(1)
+		if got.Event == mqtt.EventTick {
+			wantQoS = 0
+		}
+		if m.qos != wantQoS {
+			t.Errorf("%s published with qos %d, want %d", m.topic, m.qos, wantQoS)
+		}
+		received[m.topic] = got
+	}
+
+	want := map[string]mqtt.Message{
+		"hound/alice/s1/tick":         {Event: mqtt.EventTick, SessionID: "s1", StepEvent: runner.StepEvent{Index: 0, Elapsed: 1}},
+		"hound/alice/s1/rest_started": {Event: runner.EventRestStarted, SessionID: "s1", StepEvent: runner.StepEvent{Type: runner.EventRestStarted, Index: 1, Rest: 30}},
+		// IDs may not add topic levels or wildcards
+		"hound/bob_/s_2/session_completed": {Event: mqtt.EventSessionCompleted, SessionID: "s/2"},
+	}
+	if !maps.Equal(received, want) {
+		t.Fatalf("received %+v, want %+v", received, want)
+	}
+}
+
+func TestPublisherQoS(t *testing.T) {
+	for _, qos := range []int{0, 2} {
+		b := newBroker(t)
+		events := runPublisher(t, config.MQTTConfig{Broker: b.url(), Topic: "hound/{user}/{session}/step", QoS: qos})
+
+		// QoS 0 messages are dropped until the client is connected
+		var messages []message
+		for idx := 0; len(messages) == 0; idx++ {
+			if idx >= 100 {
+				t.Fatalf("qos %d: broker received no messages", qos)
+			}
+			events <- runner.SessionEvent{SessionID: "s1", UserID: "alice", StepEvent: runner.StepEvent{Type: runner.EventRestStarted, Index: idx}}
+			time.Sleep(20 * time.Millisecond)
+			messages = b.messagesSoFar()
+		}
+		if m := messages[0]; m.topic != "hound/alice/s1/step" || m.qos != byte(qos) {
+			t.Errorf("message = %s (qos %d), want hound/alice/s1/step with qos %d", m.topic, m.qos, qos)
+		}
+	}
+}
+
+func TestPublisherReconnects(t *testing.T) {
+	b := newBroker(t)
+	events := runPublisher(t, config.MQTTConfig{Broker: b.url(), Topic: "hound/{user}/{session}/step", QoS: 1})
+
+	restStarted := func(idx int) runner.SessionEvent {
+		return runner.SessionEvent{SessionID: "s1", UserID: "alice", StepEvent: runner.StepEvent{Type: runner.EventRestStarted, Index: idx}}
+	}
+
+	events <- restStarted(1)
+	b.waitMessages(t, 1)
+
+	b.dropConnections()
+	events <- restStarted(2)
+	events <- restStarted(3)
+
+	// QoS 1 messages published while the broker was away are sent once the
+	// client is back
+	var indexes []int
+	for _, m := range b.waitMessages(t, 3) {
+		var got mqtt.Message
+		if err := json.Unmarshal(m.payload, &got); err != nil {
+			t.Fatalf("payload %s: %v", m.payload, err)
+		}
+		indexes = append(indexes, got.Index)
+	}
+	slices.Sort(indexes)
+	if !slices.Equal(indexes, []int{1, 2, 3}) {
+		t.Fatalf("received events for steps %v, want 1, 2 and 3", indexes)
+	}
+}
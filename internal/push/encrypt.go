@@ -0,0 +1,114 @@
+package push
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/hperssn/hound/internal/domain"
+)
+
+// recordSize is the record size announced in the message header. Messages
+// are sent as a single record, so it only has to be larger than they are.
+const recordSize = 4096
+
+// maxPayload is the largest payload that fits a single record with its
+// padding delimiter and authentication tag.
+const maxPayload = recordSize - 1 - 16
+
+var ErrPayloadTooLarge = errors.New("push payload too large")
+
+// encrypt encrypts payload for the subscription's browser using the
+// aes128gcm content encoding of RFC 8291.
+func encrypt(sub domain.PushSubscription, payload []byte) ([]byte, error) {
+	// a fresh key pair and salt per message, so only the browser can derive
+	// the content key
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encryptWith(sub, payload, asPrivate, salt)
+}
+
+func encryptWith(sub domain.PushSubscription, payload []byte, asPrivate *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	if len(payload) > maxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+
+	uaKey, err := domain.DecodePushKey(sub.Keys.P256dh)
+	if err != nil {
+		return nil, err
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := domain.DecodePushKey(sub.Keys.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	asPublic := asPrivate.PublicKey().Bytes()
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, nonce, err := deriveKeys(sharedSecret, authSecret, salt, uaKey, asPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0x02 marks the last and only record
+	plaintext := append(append([]byte(nil), payload...), 0x02)
+
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// deriveKeys derives the content encryption key and nonce from the ECDH
+// secret, mixing in the browser's auth secret and both public keys.
+func deriveKeys(sharedSecret, authSecret, salt, uaPublic, asPublic []byte) (cek, nonce []byte, err error) {
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	cek, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
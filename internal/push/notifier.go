@@ -0,0 +1,122 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/scheduler"
+)
+
+// Message types, for the service worker to tell notifications apart.
+const (
+	MessageStepCompleted    = "step_completed"
+	MessageSessionCompleted = "session_completed"
+	MessageReminder         = "reminder"
+)
+
+// Message is the JSON payload the service worker shows as a notification.
+type Message struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// Store is the part of storage.Repository the notifier needs.
+type Store interface {
+	GetPushSubscriptions(ctx context.Context, userID string) ([]domain.PushSubscription, error)
+	DeletePushSubscription(ctx context.Context, userID, endpoint string) error
+}
+
+// Notifier pushes messages to every device a user has subscribed. It tells
+// users about completed steps and sessions as a runner.Notifier, and sends
+// reminders as a scheduler.Notifier.
+type Notifier struct {
+	store  Store
+	sender *Sender
+	log    *slog.Logger
+}
+
+func NewNotifier(store Store, sender *Sender, log *slog.Logger) *Notifier {
+	return &Notifier{store: store, sender: sender, log: log}
+}
+
+// SessionStarted and StepStarted do nothing: the user is looking at the
+// page when starting them.
+func (n *Notifier) SessionStarted(ctx context.Context, s domain.Session) {}
+
+func (n *Notifier) StepStarted(ctx context.Context, s domain.Session, idx int) {}
+
+func (n *Notifier) StepCompleted(ctx context.Context, s domain.Session, idx int) {
+	// the last step is announced as the session completing
+	if s.State == domain.StateCompleted {
+		return
+	}
+
+	body := "Step complete."
+	if next := idx + 1; next < len(s.Steps) {
+		body = fmt.Sprintf("Next up: step %d of %d.", next+1, len(s.Steps))
+	}
+	n.report(ctx, s, Message{
+		Type:      MessageStepCompleted,
+		Title:     fmt.Sprintf("Step %d finished", idx+1),
+		Body:      body,
+		SessionID: s.ID,
+	})
+}
+
+func (n *Notifier) SessionCompleted(ctx context.Context, s domain.Session) {
+	n.report(ctx, s, Message{
+		Type:      MessageSessionCompleted,
+		Title:     "Session complete",
+		Body:      fmt.Sprintf("All %d steps done. Rate the session to save it.", len(s.Steps)),
+		SessionID: s.ID,
+	})
+}
+
+// report sends msg about session s, logging failures since the runner has
+// no one to return them to.
+func (n *Notifier) report(ctx context.Context, s domain.Session, msg Message) {
+	if err := n.Send(ctx, s.UserID, msg); err != nil {
+		n.log.Error("failed to push notification", "session_id", s.ID, "type", msg.Type, "err", err)
+	}
+}
+
+func (n *Notifier) Notify(ctx context.Context, r scheduler.Notification) error {
+	return n.Send(ctx, r.UserID, Message{
+		Type:      MessageReminder,
+		Title:     r.Title,
+		Body:      r.Body,
+		SessionID: r.SessionID,
+	})
+}
+
+// Send pushes msg to each of the user's subscriptions. Subscriptions the
+// push service reports gone are deleted.
+func (n *Notifier) Send(ctx context.Context, userID string, msg Message) error {
+	subs, err := n.store.GetPushSubscriptions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		err := n.sender.Send(ctx, sub, payload)
+		if errors.Is(err, ErrGone) {
+			n.log.Info("removing expired push subscription", "user_id", userID)
+			err = n.store.DeletePushSubscription(ctx, userID, sub.Endpoint)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
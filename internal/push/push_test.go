@@ -0,0 +1,328 @@
+package push
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/scheduler"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+const testSubject = "mailto:hound@example.com"
+
+// pushService stands in for a browser vendor's push service. It checks
+// each request's VAPID token and decrypts the message with the keys of the
+// device it is addressed to.
+type pushService struct {
+	*httptest.Server
+	t     *testing.T
+	vapid Keys
+
+	mu       sync.Mutex
+	devices  map[string]*device // by URL path
+	received map[string][]Message
+}
+
+type device struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+	gone bool
+}
+
+func newPushService(t *testing.T, vapid Keys) *pushService {
+	p := &pushService{
+		t:        t,
+		vapid:    vapid,
+		devices:  make(map[string]*device),
+		received: make(map[string][]Message),
+	}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.handle))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// subscribe registers a new device and returns its subscription as the
+// browser would report it.
+func (p *pushService) subscribe(userID, name string) *domain.PushSubscription {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+
+	p.mu.Lock()
+	p.devices["/"+name] = &device{key: key, auth: auth}
+	p.mu.Unlock()
+
+	return &domain.PushSubscription{
+		Endpoint: p.URL + "/" + name,
+		UserID:   userID,
+		Keys: domain.PushKeys{
+			P256dh: base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(auth),
+		},
+		CreatedAt: time.Now(),
+	}
+}
+
+func (p *pushService) expire(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.devices["/"+name].gone = true
+}
+
+func (p *pushService) messages(name string) []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.received["/"+name]...)
+}
+
+func (p *pushService) handle(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	dev, ok := p.devices[r.URL.Path]
+	p.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if dev.gone {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	if err := p.checkHeaders(r); err != "" {
+		p.t.Errorf("push request: %s", err)
+		http.Error(w, err, http.StatusBadRequest)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	msg, err := decrypt(dev, body)
+	if err != "" {
+		p.t.Errorf("push request: %s", err)
+		http.Error(w, err, http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	p.received[r.URL.Path] = append(p.received[r.URL.Path], msg)
+	p.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (p *pushService) checkHeaders(r *http.Request) string {
+	if r.Header.Get("Content-Encoding") != "aes128gcm" {
+		return "missing aes128gcm content encoding"
+	}
+	if r.Header.Get("TTL") == "" {
+		return "missing TTL"
+	}
+
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "vapid ")
+	if !ok {
+		return "authorization is not vapid"
+	}
+	var jwt, k string
+	for _, part := range strings.Split(auth, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "t":
+			jwt = value
+		case "k":
+			k = value
+		}
+	}
+	if k != p.vapid.PublicKey {
+		return "k is not the server's public key"
+	}
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return "malformed token"
+	}
+	rawPub, _ := base64.RawURLEncoding.DecodeString(k)
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), rawPub)
+	if err != nil {
+		return err.Error()
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(sig) != 64 || !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return "bad token signature"
+	}
+
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	rawClaims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(rawClaims, &claims)
+	if claims.Aud != p.URL || claims.Sub != testSubject {
+		return "token claims = " + string(rawClaims)
+	}
+	if exp := time.Unix(claims.Exp, 0); exp.Before(time.Now()) || exp.After(time.Now().Add(24*time.Hour)) {
+		return "token expiry out of range"
+	}
+	return ""
+}
+
+func decrypt(dev *device, body []byte) (Message, string) {
+	if len(body) < 21 || len(body) < 21+int(body[20]) {
+		return Message{}, "message too short"
+	}
+	salt, idLen := body[:16], int(body[20])
+	asPublic, ciphertext := body[21:21+idLen], body[21+idLen:]
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		return Message{}, err.Error()
+	}
+	shared, err := dev.key.ECDH(asKey)
+	if err != nil {
+		return Message{}, err.Error()
+	}
+	cek, nonce, err := deriveKeys(shared, dev.auth, salt, dev.key.PublicKey().Bytes(), asPublic)
+	if err != nil {
+		return Message{}, err.Error()
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return Message{}, "cannot decrypt: " + err.Error()
+	}
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return Message{}, "missing last record delimiter"
+	}
+
+	var msg Message
+	if err := json.Unmarshal(plaintext[:len(plaintext)-1], &msg); err != nil {
+		return Message{}, err.Error()
+	}
+	return msg, ""
+}
+
+func newTestNotifier(t *testing.T) (*Notifier, *pushService, storage.Repository) {
+	t.Helper()
+
+	keys, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := newPushService(t, keys)
+	sender, err := NewSender(keys, testSubject, service.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := storage.NewMemoryRepository()
+	return NewNotifier(repo, sender, slog.Default()), service, repo
+}
+
+func TestNotifierPushesToEveryDevice(t *testing.T) {
+	notifier, service, repo := newTestNotifier(t)
+	ctx := context.Background()
+
+	for _, sub := range []*domain.PushSubscription{
+		service.subscribe("alice", "phone"),
+		service.subscribe("alice", "laptop"),
+		service.subscribe("bob", "tablet"),
+	} {
+		repo.SavePushSubscription(ctx, sub)
+	}
+
+	session := domain.Session{ID: "s1", UserID: "alice", Steps: []domain.Step{{Index: 0}, {Index: 1}}}
+	notifier.StepCompleted(ctx, session, 0)
+	notifier.SessionCompleted(ctx, session)
+
+	for _, name := range []string{"phone", "laptop"} {
+		got := service.messages(name)
+		if len(got) != 2 {
+			t.Fatalf("%s received %+v, want 2 messages", name, got)
+		}
+		if got[0].Type != MessageStepCompleted || got[0].Title != "Step 1 finished" || got[0].SessionID != "s1" {
+			t.Errorf("%s first message = %+v, want step 1 finished", name, got[0])
+		}
+		if got[1].Type != MessageSessionCompleted {
+			t.Errorf("%s second message = %+v, want session completed", name, got[1])
+		}
+	}
+	if got := service.messages("tablet"); len(got) != 0 {
+		t.Fatalf("bob's tablet received %+v, want nothing", got)
+	}
+
+	if err := notifier.Notify(ctx, scheduler.Notification{UserID: "bob", Title: "Time to train"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := service.messages("tablet"); len(got) != 1 || got[0].Type != MessageReminder || got[0].Title != "Time to train" {
+		t.Fatalf("bob's tablet received %+v, want the reminder", got)
+	}
+}
+
+func TestNotifierDeletesExpiredSubscriptions(t *testing.T) {
+	notifier, service, repo := newTestNotifier(t)
+	ctx := context.Background()
+
+	repo.SavePushSubscription(ctx, service.subscribe("alice", "old-phone"))
+	repo.SavePushSubscription(ctx, service.subscribe("alice", "phone"))
+	service.expire("old-phone")
+
+	if err := notifier.Send(ctx, "alice", Message{Type: MessageReminder, Title: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	subs, _ := repo.GetPushSubscriptions(ctx, "alice")
+	if len(subs) != 1 || !strings.HasSuffix(subs[0].Endpoint, "/phone") {
+		t.Fatalf("subscriptions = %+v, want only the phone left", subs)
+	}
+	if got := service.messages("phone"); len(got) != 1 {
+		t.Fatalf("phone received %+v, want the message", got)
+	}
+}
+
+func TestNewSenderRejectsMismatchedKeys(t *testing.T) {
+	a, _ := GenerateKeys()
+	b, _ := GenerateKeys()
+	if _, err := NewSender(Keys{PublicKey: b.PublicKey, PrivateKey: a.PrivateKey}, testSubject, nil); err == nil {
+		t.Fatal("NewSender with another key pair's public key succeeded")
+	}
+	if _, err := NewSender(a, testSubject, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSenderRefusesPrivateAddresses(t *testing.T) {
+	keys, _ := GenerateKeys()
+	service := newPushService(t, keys)
+	sender, err := NewSender(keys, testSubject, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test service listens on loopback, as a name resolving to the
+	// server's own network would
+	if err := sender.Send(context.Background(), *service.subscribe("alice", "phone"), []byte("hi")); err == nil {
+		t.Fatal("Send to a loopback address succeeded")
+	}
+	if got := service.messages("phone"); len(got) != 0 {
+		t.Fatalf("service received %+v, want nothing", got)
+	}
+}
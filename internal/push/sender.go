@@ -0,0 +1,115 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+)
+
+// messageTTL is how long the push service keeps a message for a browser
+// that is offline. A step that finished an hour ago is old news.
+const messageTTL = time.Hour
+
+// ErrGone is returned for subscriptions the push service no longer knows,
+// such as when the user revoked permission. They should be deleted.
+var ErrGone = errors.New("push subscription has expired")
+
+// Sender delivers messages to push services.
+type Sender struct {
+	key       *ecdsa.PrivateKey
+	publicKey string
+	subject   string
+	client    *http.Client
+}
+
+// NewSender returns a Sender signing with keys. subject is a mailto: or
+// https: contact for the push service operators.
+func NewSender(keys Keys, subject string, client *http.Client) (*Sender, error) {
+	key, err := keys.parse()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: publicTransport(),
+		}
+	}
+	return &Sender{key: key, publicKey: keys.PublicKey, subject: subject, client: client}, nil
+}
+
+// publicTransport refuses connections to addresses a push service cannot
+// have, such as one a subscribed host name resolves to on the server's own
+// network.
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !domain.PublicAddr(addr.Addr()) {
+				return fmt.Errorf("push endpoint resolves to non-public address %s", addr.Addr())
+			}
+			return nil
+		},
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be the only address checked
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// PublicKey returns the applicationServerKey browsers subscribe with.
+func (s *Sender) PublicKey() string {
+	return s.publicKey
+}
+
+// Send encrypts payload for the subscription and posts it to its push
+// service.
+func (s *Sender) Send(ctx context.Context, sub domain.PushSubscription, payload []byte) error {
+	body, err := encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+	jwt, err := token(s.key, sub.Endpoint, s.subject, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(messageTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", jwt, s.publicKey))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("push service answered %s", resp.Status)
+	}
+	return nil
+}
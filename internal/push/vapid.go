@@ -0,0 +1,111 @@
+// Package push sends Web Push messages (RFC 8030), encrypted for the
+// receiving browser (RFC 8291) and signed with the server's VAPID key (RFC
+// 8292), so users hear from Hound while its page is closed.
+package push
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// tokenTTL is how long a VAPID token is valid. Push services reject
+// tokens valid for more than 24 hours.
+const tokenTTL = 12 * time.Hour
+
+// Keys is a VAPID key pair, base64url encoded without padding. PublicKey is
+// the uncompressed P-256 point browsers subscribe with as their
+// applicationServerKey; PrivateKey is the raw private scalar.
+type Keys struct {
+	PublicKey  string
+	PrivateKey string
+}
+
+// GenerateKeys returns a new VAPID key pair.
+func GenerateKeys() (Keys, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return Keys{}, err
+	}
+	return encodeKeys(key)
+}
+
+func encodeKeys(key *ecdsa.PrivateKey) (Keys, error) {
+	priv, err := key.Bytes()
+	if err != nil {
+		return Keys{}, err
+	}
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return Keys{}, err
+	}
+	return Keys{
+		PublicKey:  base64.RawURLEncoding.EncodeToString(pub),
+		PrivateKey: base64.RawURLEncoding.EncodeToString(priv),
+	}, nil
+}
+
+// parse decodes the private key and checks that the public key belongs to
+// it, since browsers subscribe with the public one.
+func (k Keys) parse() (*ecdsa.PrivateKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(k.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("vapid private key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("vapid private key: %w", err)
+	}
+
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if want, err := base64.RawURLEncoding.DecodeString(k.PublicKey); err != nil || !bytes.Equal(pub, want) {
+		return nil, errors.New("vapid public key does not match the private key")
+	}
+	return key, nil
+}
+
+// token returns the signed JWT that identifies the server to the push
+// service behind endpoint.
+func token(key *ecdsa.PrivateKey, endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(tokenTTL).Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS wants r and s as fixed-size big-endian integers, not ASN.1
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
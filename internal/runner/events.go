@@ -1,6 +1,81 @@
 package runner
 
+import "sync"
+
+// Event types. Timer ticks carry no type for compatibility with existing
+// clients.
+const (
+	EventServerRestarting = "server_restarting"
+
+	// EventRestStarted and EventRestEnded bracket the rest before an
+	// auto-advanced step. Index is the step that follows the rest.
+	EventRestStarted = "rest_started"
+	EventRestEnded   = "rest_ended"
+
+	// EventStepAborted is sent when a step stayed paused longer than the
+	// pause limit.
+	EventStepAborted = "step_aborted"
+
+	// EventPlanChanged is sent when a step is inserted, removed or given a
+	// new duration. Index is the step that was edited.
+	EventPlanChanged = "plan_changed"
+)
+
 type StepEvent struct {
-	Index   int `json:"index"`
-	Elapsed int `json:"elapsed"`
+	Type    string `json:"type,omitempty"`
+	Index   int    `json:"index"`
+	Elapsed int    `json:"elapsed"`
+	// Rest is the length of the rest in seconds, set on rest_started.
+	Rest int `json:"rest,omitempty"`
+}
+
+// SessionEvent is a StepEvent with the session it belongs to, as received
+// by SessionManager.SubscribeAll.
+type SessionEvent struct {
+	SessionID string
+	UserID    string
+	StepEvent
+}
+
+// allEventsBuffer is how many events a SubscribeAll subscriber may fall
+// behind by before it misses some. It serves every session at once.
+const allEventsBuffer = 256
+
+// broadcast fans every runner's events out to the manager's SubscribeAll
+// subscribers.
+type broadcast struct {
+	mu   sync.Mutex
+	subs map[chan SessionEvent]struct{}
+}
+
+func (b *broadcast) subscribe() (<-chan SessionEvent, func()) {
+	ch := make(chan SessionEvent, allEventsBuffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// publish reports whether every subscriber received ev.
+func (b *broadcast) publish(ev SessionEvent) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delivered := true
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			delivered = false
+		}
+	}
+	return delivered
 }
@@ -1,27 +1,204 @@
 package runner
 
 import (
+	"context"
 	"errors"
+	"log/slog"
+	"slices"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/metrics"
+)
+
+const (
+	tracerName = "github.com/hperssn/hound/internal/runner"
+
+	archiveTimeout = 10 * time.Second
+	notifyTimeout  = 10 * time.Second
 )
 
 var (
 	ErrSessionExists   = errors.New("session already exists")
 	ErrSessionNotFound = errors.New("session not found")
 	ErrInvalidStep     = errors.New("invalid step index")
+	ErrShuttingDown    = errors.New("server is shutting down")
+	ErrTooManySessions = errors.New("too many active sessions")
 )
 
+// Authorizer resolves the role a user holds in a household. It returns an
+// empty role and no error when the user is not a member.
+type Authorizer interface {
+	MemberRole(ctx context.Context, householdID, userID string) (domain.Role, error)
+}
+
+// personalAuthorizer is the default Authorizer: users only have access to
+// their own personal household.
+type personalAuthorizer struct{}
+
+func (personalAuthorizer) MemberRole(_ context.Context, householdID, userID string) (domain.Role, error) {
+	if userID != "" && householdID == userID {
+		return domain.RoleOwner, nil
+	}
+	return "", nil
+}
+
+// Checkpointer persists in-flight sessions so they survive a restart.
+type Checkpointer interface {
+	SaveCheckpoints(ctx context.Context, sessions []domain.Session) error
+}
+
+// Archiver saves sessions that ended without the user completing them, so
+// they still show up in history.
+type Archiver interface {
+	ArchiveSession(ctx context.Context, s domain.Session) error
+}
+
+// Notifier hears about a session's lifecycle, so users and their other
+// systems can be told when the page is not open. StepStarted is called
+// when a step first starts, not when it resumes. StepCompleted is called
+// for steps that ran for their full duration or were stopped, followed by
+// SessionCompleted when it was the last one; SessionCompleted is also
+// called when the user completes a session before its last step. Each call
+// runs in its own goroutine with a snapshot of the session.
+type Notifier interface {
+	SessionStarted(ctx context.Context, s domain.Session)
+	StepStarted(ctx context.Context, s domain.Session, idx int)
+	StepCompleted(ctx context.Context, s domain.Session, idx int)
+	SessionCompleted(ctx context.Context, s domain.Session)
+}
+
+type Option func(*SessionManager)
+
+func WithCheckpointer(c Checkpointer) Option {
+	return func(m *SessionManager) {
+		m.checkpoints = c
+	}
+}
+
+func WithAuthorizer(a Authorizer) Option {
+	return func(m *SessionManager) {
+		m.authz = a
+	}
+}
+
+// WithArchiver archives stopped and idle sessions instead of discarding
+// them. Sessions in which no step was ever started are discarded.
+func WithArchiver(a Archiver) Option {
+	return func(m *SessionManager) {
+		m.archiver = a
+	}
+}
+
+// WithNotifier tells n about started and completed sessions and steps. It
+// may be given more than once.
+func WithNotifier(n Notifier) Option {
+	return func(m *SessionManager) {
+		m.notifiers = append(m.notifiers, n)
+	}
+}
+
+// WithIdleTimeout sets how long a session the user has not completed may
+// go without step activity before the cleanup loop evicts it. It defaults
+// to two hours.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(m *SessionManager) {
+		m.idleTimeout = d
+	}
+}
+
+// WithMaxSessionsPerUser limits how many sessions a user may have active at
+// once. Zero, the default, means no limit.
+func WithMaxSessionsPerUser(n int) Option {
+	return func(m *SessionManager) {
+		m.maxPerUser = n
+	}
+}
+
+// WithMaxPause aborts steps that stay paused for longer than d. Zero, the
+// default, lets steps stay paused until the session is evicted.
+func WithMaxPause(d time.Duration) Option {
+	return func(m *SessionManager) {
+		m.maxPause = d
+	}
+}
+
+// WithMetrics reports session and step activity to mt.
+func WithMetrics(mt metrics.Metrics) Option {
+	return func(m *SessionManager) {
+		m.metrics = mt
+	}
+}
+
+// WithLogger sets the logger for session lifecycle events. It defaults to
+// slog.Default().
+func WithLogger(l *slog.Logger) Option {
+	return func(m *SessionManager) {
+		m.log = l
+	}
+}
+
+// WithTracerProvider sets where SessionManager spans are sent. It defaults
+// to the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(m *SessionManager) {
+		m.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithCleanup sets how often finished sessions are evicted and how long a
+// completed session is kept in memory.
+func WithCleanup(interval, completedTTL time.Duration) Option {
+	return func(m *SessionManager) {
+		m.cleanupInterval = interval
+		m.completedTTL = completedTTL
+	}
+}
+
 type SessionManager struct {
 	mu       sync.Mutex
 	sessions map[string]*sessionRunner
+	authz    Authorizer
+
+	cleanupInterval time.Duration
+	completedTTL    time.Duration
+	idleTimeout     time.Duration
+	maxPerUser      int
+	maxPause        time.Duration
+
+	checkpoints Checkpointer
+	archiver    Archiver
+	notifiers   []Notifier
+	all         *broadcast
+	metrics     metrics.Metrics
+	log         *slog.Logger
+	tracer      trace.Tracer
+	closing     bool
+	done        chan struct{}
 }
 
-func NewSessionManager() *SessionManager {
+func NewSessionManager(opts ...Option) *SessionManager {
 	m := &SessionManager{
 		sessions: make(map[string]*sessionRunner),
+		authz:    personalAuthorizer{},
+		all:      &broadcast{subs: make(map[chan SessionEvent]struct{})},
+
+		cleanupInterval: 5 * time.Minute,
+		completedTTL:    time.Hour,
+		idleTimeout:     2 * time.Hour,
+		done:            make(chan struct{}),
+		metrics:         metrics.Nop{},
+		log:             slog.Default(),
+		tracer:          otel.GetTracerProvider().Tracer(tracerName),
+	}
+
+	for _, opt := range opts {
+		opt(m)
 	}
 
 	go m.cleanupLoop()
@@ -29,81 +206,251 @@ func NewSessionManager() *SessionManager {
 	return m
 }
 
+// lock starts a span for op and acquires m.mu within it, so time spent
+// waiting for the lock is visible in traces. Callers unlock m.mu and end the
+// span.
+func (m *SessionManager) lock(ctx context.Context, op, sessionID string) (context.Context, trace.Span) {
+	ctx, span := m.tracer.Start(ctx, "SessionManager."+op,
+		trace.WithAttributes(attribute.String("session.id", sessionID)),
+	)
+	m.mu.Lock()
+	span.AddEvent("lock acquired")
+	return ctx, span
+}
+
 func (m *SessionManager) cleanupLoop() {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(m.cleanupInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		m.cleanupOldSessions()
+	for {
+		select {
+		case <-ticker.C:
+			m.cleanupOldSessions()
+		case <-m.done:
+			return
+		}
 	}
 }
 
 func (m *SessionManager) cleanupOldSessions() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	cutoff := time.Now().Add(-1 * time.Hour)
+	now := time.Now()
+	cutoff := now.Add(-m.completedTTL)
 
+	m.mu.Lock()
+	var abandoned []*sessionRunner
 	for id, runner := range m.sessions {
-		sess := runner.Session()
-		if sess.Completed && sess.StartedAt.Before(cutoff) {
+		if !runner.isSaved() {
+			if runner.idleFor(now) >= m.idleTimeout {
+				abandoned = append(abandoned, runner)
+			}
+			continue
+		}
+
+		if runner.Session().StartedAt.Before(cutoff) {
 			runner.Stop()
 			delete(m.sessions, id)
+			m.log.Debug("evicted completed session", "session_id", id)
 		}
 	}
+	m.metrics.SetActiveSessions(len(m.sessions))
+	m.mu.Unlock()
+
+	for _, runner := range abandoned {
+		// like StopSession, abort before archiving so the record shows
+		// the session ended; the user may have come back since the check
+		// above
+		m.mu.Lock()
+		evict := !m.closing && m.sessions[runner.session.ID] == runner && runner.abortIfIdle(time.Now(), m.idleTimeout)
+		m.mu.Unlock()
+		if !evict {
+			continue
+		}
+
+		if err := m.archive(context.Background(), runner); err != nil {
+			// keep the session so the next cleanup retries
+			m.log.Error("failed to archive abandoned session", "session_id", runner.session.ID, "err", err)
+			continue
+		}
+
+		m.mu.Lock()
+		if m.sessions[runner.session.ID] == runner {
+			delete(m.sessions, runner.session.ID)
+		}
+		m.metrics.SetActiveSessions(len(m.sessions))
+		m.mu.Unlock()
+		runner.Stop()
+		m.log.Info("evicted idle session", "session_id", runner.session.ID)
+	}
+}
+
+// archive hands a session the user did not complete to the archiver. It
+// must be called without m.mu held.
+func (m *SessionManager) archive(ctx context.Context, r *sessionRunner) error {
+	if m.archiver == nil || r.isSaved() {
+		return nil
+	}
+
+	snapshot := r.Snapshot()
+	started := false
+	for _, step := range snapshot.Steps {
+		started = started || !step.StartedAt.IsZero()
+	}
+	if !started {
+		m.log.Debug("discarded session without progress", "session_id", snapshot.ID)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+	defer cancel()
+
+	if err := m.archiver.ArchiveSession(ctx, *snapshot); err != nil {
+		return err
+	}
+	m.log.Info("archived abandoned session", "session_id", snapshot.ID)
+	return nil
+}
+
+// SubscribeAll streams the events of every session, including ones
+// started later, until the returned function is called. Like Subscribe, a
+// subscriber that falls behind misses events.
+func (m *SessionManager) SubscribeAll() (<-chan SessionEvent, func()) {
+	return m.all.subscribe()
 }
 
-func (m *SessionManager) Events(id string) (<-chan StepEvent, bool) {
+// Subscribe streams the session's events until the returned function is
+// called.
+func (m *SessionManager) Subscribe(id string) (<-chan StepEvent, func(), bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	r, ok := m.sessions[id]
 	if !ok {
-		return nil, false
+		return nil, nil, false
 	}
 
-	return r.Events(), true
+	events, unsubscribe := r.Subscribe()
+	return events, unsubscribe, true
 }
 
-func (m *SessionManager) StartSession(s *domain.Session) error {
-	m.mu.Lock()
+func (m *SessionManager) StartSession(ctx context.Context, s *domain.Session) error {
+	_, span := m.lock(ctx, "StartSession", s.ID)
+	defer span.End()
 	defer m.mu.Unlock()
 
+	if m.closing {
+		return ErrShuttingDown
+	}
 	if _, exists := m.sessions[s.ID]; exists {
 		return ErrSessionExists
 	}
+	if m.maxPerUser > 0 && m.activeCount(s.UserID) >= m.maxPerUser {
+		return ErrTooManySessions
+	}
 
-	r := NewSessionRunner(s)
-	m.sessions[s.ID] = r
-
+	m.addRunner(s)
+	m.sessions[s.ID].notifyStarted()
+	m.log.Info("session started",
+		"session_id", s.ID,
+		"user_id", s.UserID,
+		"household_id", s.HouseholdID,
+		"steps", len(s.Steps),
+	)
 	return nil
 }
 
-func (m *SessionManager) StopSession(id string) error {
+// activeCount returns how many sessions the user has not completed yet. It
+// must be called with m.mu held.
+func (m *SessionManager) activeCount(userID string) int {
+	n := 0
+	for _, r := range m.sessions {
+		if r.session.UserID == userID && !r.isSaved() {
+			n++
+		}
+	}
+	return n
+}
+
+// ActiveSessions returns snapshots of the sessions the user started and has
+// not completed yet, oldest first.
+func (m *SessionManager) ActiveSessions(userID string) []domain.Session {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	sessions := []domain.Session{}
+	for _, r := range m.sessions {
+		if r.session.UserID == userID && !r.isSaved() {
+			sessions = append(sessions, *r.Snapshot())
+		}
+	}
+	slices.SortFunc(sessions, func(a, b domain.Session) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return sessions
+}
+
+// addRunner must be called with m.mu held.
+func (m *SessionManager) addRunner(s *domain.Session) {
+	r := NewSessionRunner(s)
+	r.metrics = m.metrics
+	r.notifiers = m.notifiers
+	r.all = m.all
+	r.log = m.log.With("session_id", s.ID)
+	r.setMaxPause(m.maxPause)
+	m.sessions[s.ID] = r
+	m.metrics.SetActiveSessions(len(m.sessions))
+}
+
+// StopSession ends a session. Unless the user completed it first, it is
+// archived as abandoned when an Archiver is configured. Once the manager is
+// shutting down the session is kept for the checkpoint instead.
+func (m *SessionManager) StopSession(ctx context.Context, id string) error {
+	ctx, span := m.lock(ctx, "StopSession", id)
+	defer span.End()
+
+	if m.closing {
+		m.mu.Unlock()
+		return ErrShuttingDown
+	}
 	r, exists := m.sessions[id]
 	if !exists {
+		m.mu.Unlock()
 		return ErrSessionNotFound
 	}
 
+	r.Abort()
 	r.Stop()
 	delete(m.sessions, id)
+	m.metrics.SetActiveSessions(len(m.sessions))
+	m.mu.Unlock()
+	m.log.Info("session stopped", "session_id", id)
+
+	// the session is gone either way; a client disconnecting should not
+	// cancel the save
+	if err := m.archive(context.WithoutCancel(ctx), r); err != nil {
+		m.log.Error("failed to archive stopped session", "session_id", id, "err", err)
+	}
 	return nil
 }
 
-func (m *SessionManager) CompleteSession(id string) error {
-	m.mu.Lock()
+// CompleteSession marks a session completed. It is refused once the manager
+// is shutting down, as the session has been checkpointed as live.
+func (m *SessionManager) CompleteSession(ctx context.Context, id string) error {
+	_, span := m.lock(ctx, "CompleteSession", id)
+	defer span.End()
 	defer m.mu.Unlock()
 
+	if m.closing {
+		return ErrShuttingDown
+	}
 	r, exists := m.sessions[id]
 	if !exists {
 		return ErrSessionNotFound
 	}
 
-	r.MarkCompleted()
-	r.StopAllSteps()
+	if err := r.MarkCompleted(); err != nil {
+		return err
+	}
+	m.log.Info("session completed", "session_id", id)
 
 	return nil
 }
@@ -119,36 +466,179 @@ func (m *SessionManager) GetSession(id string) (*domain.Session, bool) {
 	return r.Session(), true
 }
 
-func (m *SessionManager) StartStep(sessionID string, idx int) error {
-	m.mu.Lock()
-	r, exists := m.sessions[sessionID]
+// GetSessionForUser returns the session and the role userID holds in the
+// session's household. Sessions in households the user is not a member of
+// are reported as ErrSessionNotFound so their existence is not leaked.
+func (m *SessionManager) GetSessionForUser(ctx context.Context, id, userID string) (*domain.Session, domain.Role, error) {
+	ctx, span := m.lock(ctx, "GetSessionForUser", id)
+	defer span.End()
+	r, exists := m.sessions[id]
 	m.mu.Unlock()
 
-	if !exists {
-		return ErrSessionNotFound
+	if !exists || userID == "" {
+		return nil, "", ErrSessionNotFound
 	}
 
 	sess := r.Session()
-	if idx < 0 || idx >= len(sess.Steps) {
-		return ErrInvalidStep
+	role, err := m.authz.MemberRole(ctx, sess.HouseholdID, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !role.Valid() {
+		return nil, "", ErrSessionNotFound
 	}
+	return sess, role, nil
+}
 
-	return r.StartStep(idx)
+// StartStep runs a pending step.
+func (m *SessionManager) StartStep(ctx context.Context, sessionID string, idx int) error {
+	return m.stepOp(ctx, "StartStep", sessionID, idx, (*sessionRunner).StartStep)
 }
 
-func (m *SessionManager) StopStep(sessionID string, idx int) error {
-	m.mu.Lock()
+// PauseStep pauses a running step.
+func (m *SessionManager) PauseStep(ctx context.Context, sessionID string, idx int) error {
+	return m.stepOp(ctx, "PauseStep", sessionID, idx, (*sessionRunner).PauseStep)
+}
+
+// ResumeStep continues a paused step.
+func (m *SessionManager) ResumeStep(ctx context.Context, sessionID string, idx int) error {
+	return m.stepOp(ctx, "ResumeStep", sessionID, idx, (*sessionRunner).ResumeStep)
+}
+
+// StopStep ends a running or paused step, recording the time it ran.
+func (m *SessionManager) StopStep(ctx context.Context, sessionID string, idx int) error {
+	return m.stepOp(ctx, "StopStep", sessionID, idx, (*sessionRunner).StopStep)
+}
+
+// InsertStep adds a step of duration seconds before a pending step.
+func (m *SessionManager) InsertStep(ctx context.Context, sessionID string, idx, duration int) error {
+	return m.stepOp(ctx, "InsertStep", sessionID, idx, func(r *sessionRunner, idx int) error {
+		return r.InsertStep(idx, duration)
+	})
+}
+
+// RemoveStep drops a pending step from the session's plan.
+func (m *SessionManager) RemoveStep(ctx context.Context, sessionID string, idx int) error {
+	return m.stepOp(ctx, "RemoveStep", sessionID, idx, (*sessionRunner).RemoveStep)
+}
+
+// SetStepDuration changes how long a pending step runs.
+func (m *SessionManager) SetStepDuration(ctx context.Context, sessionID string, idx, duration int) error {
+	return m.stepOp(ctx, "SetStepDuration", sessionID, idx, func(r *sessionRunner, idx int) error {
+		return r.SetStepDuration(idx, duration)
+	})
+}
+
+// stepOp applies op to a step of the session. Every operation is refused
+// once the manager is shutting down: the session has been checkpointed, and
+// a change made after that would be lost on restart.
+func (m *SessionManager) stepOp(ctx context.Context, name, sessionID string, idx int, op func(*sessionRunner, int) error) error {
+	_, span := m.lock(ctx, name, sessionID)
+	defer span.End()
 	r, exists := m.sessions[sessionID]
+	closing := m.closing
 	m.mu.Unlock()
 
+	if closing {
+		return ErrShuttingDown
+	}
 	if !exists {
 		return ErrSessionNotFound
 	}
+	return op(r, idx)
+}
 
-	sess := r.Session()
-	if idx < 0 || idx >= len(sess.Steps) {
-		return ErrInvalidStep
+// Load describes how busy the manager is.
+type Load struct {
+	ActiveSessions int  `json:"activeSessions"`
+	RunningSteps   int  `json:"runningSteps"`
+	ShuttingDown   bool `json:"shuttingDown"`
+}
+
+func (m *SessionManager) Load() Load {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	load := Load{
+		ActiveSessions: len(m.sessions),
+		ShuttingDown:   m.closing,
+	}
+	for _, r := range m.sessions {
+		load.RunningSteps += r.runningSteps()
+	}
+	return load
+}
+
+// Done is closed once Shutdown has finished; long-lived streams should end
+// when it is.
+func (m *SessionManager) Done() <-chan struct{} {
+	return m.done
+}
+
+// Restore resumes sessions checkpointed by a previous Shutdown. Steps that
+// were running come back paused.
+func (m *SessionManager) Restore(sessions []domain.Session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range sessions {
+		s := sessions[i]
+		if _, exists := m.sessions[s.ID]; exists {
+			continue
+		}
+		m.addRunner(&s)
+		m.log.Info("session restored from checkpoint", "session_id", s.ID)
+	}
+}
+
+// Shutdown stops accepting new sessions and steps, tells connected clients
+// the server is restarting, checkpoints every session and stops all
+// runners and the cleanup loop.
+func (m *SessionManager) Shutdown(ctx context.Context) error {
+	ctx, span := m.tracer.Start(ctx, "SessionManager.Shutdown")
+	defer span.End()
+
+	m.mu.Lock()
+	if m.closing {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closing = true
+
+	runners := make([]*sessionRunner, 0, len(m.sessions))
+	for _, r := range m.sessions {
+		runners = append(runners, r)
+	}
+	m.mu.Unlock()
+
+	snapshots := make([]domain.Session, 0, len(runners))
+	for _, r := range runners {
+		snapshots = append(snapshots, *r.Snapshot())
+		r.publish(StepEvent{Type: EventServerRestarting})
+	}
+
+	var err error
+	if m.checkpoints != nil && len(snapshots) > 0 {
+		saved := make(chan error, 1)
+		go func() {
+			saved <- m.checkpoints.SaveCheckpoints(ctx, snapshots)
+		}()
+
+		select {
+		case err = <-saved:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	if err == nil {
+		m.log.Info("checkpointed sessions", "count", len(snapshots))
+	}
+
+	for _, r := range runners {
+		r.Stop()
 	}
+	close(m.done)
 
-	return r.StopStep(idx)
+	return err
 }
@@ -2,18 +2,65 @@ package runner
 
 import (
 	"context"
-	"errors"
+	"log/slog"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/metrics"
 )
 
+// stepControl tracks the timing of a step that has been started. Whether
+// it is running or paused is the step's State.
 type stepControl struct {
-	step         *domain.Step
-	cancel       chan struct{}
-	paused       bool
-	elapsedSoFar int
+	step   *domain.Step
+	cancel chan struct{}
+
+	// ran and paused are the time spent running and paused before the
+	// current run or pause.
+	ran        time.Duration
+	paused     time.Duration
+	runStarted time.Time
+	pausedAt   time.Time
+	// pauseLimit aborts the step once it has been paused too long.
+	pauseLimit *time.Timer
+}
+
+// elapsed returns how long the step has run by now.
+func (sc *stepControl) elapsed(now time.Time) time.Duration {
+	if sc.step.State == domain.StateRunning {
+		return sc.ran + now.Sub(sc.runStarted)
+	}
+	return sc.ran
+}
+
+// pausedFor returns how long the step has been paused by now.
+func (sc *stepControl) pausedFor(now time.Time) time.Duration {
+	if sc.step.State == domain.StatePaused {
+		return sc.paused + now.Sub(sc.pausedAt)
+	}
+	return sc.paused
+}
+
+// endPause adds the pause in progress, if any, to the step's paused time.
+func (sc *stepControl) endPause(now time.Time) {
+	if sc.pauseLimit != nil {
+		sc.pauseLimit.Stop()
+		sc.pauseLimit = nil
+	}
+	if sc.pausedAt.IsZero() {
+		return
+	}
+	sc.paused += now.Sub(sc.pausedAt)
+	sc.pausedAt = time.Time{}
+	sc.step.PausedSec = int(sc.paused / time.Second)
+}
+
+// rest is the pause before an auto-advanced step starts.
+type rest struct {
+	next  int
+	timer *time.Timer
 }
 
 type sessionRunner struct {
@@ -23,156 +70,610 @@ type sessionRunner struct {
 	ctx     context.Context
 	cancel  context.CancelFunc
 
-	events chan StepEvent
-	steps  map[int]*stepControl
+	subsMu sync.Mutex
+	// subs is nil once Stop has closed the subscriptions.
+	subs  map[chan StepEvent]struct{}
+	steps map[int]*stepControl
+	// all, if set, also receives the events for the manager's
+	// SubscribeAll subscribers.
+	all *broadcast
+
+	// lastActive is when a step last started, stopped or finished.
+	lastActive time.Time
+	// saved is set once the user has completed the session and it is in
+	// history.
+	saved bool
+
+	// rest is set while an auto-advancing session waits to start its next
+	// step.
+	rest *rest
+	rng  *rand.Rand
+
+	// maxPause is how long a step may stay paused before it is aborted.
+	// Zero means no limit.
+	maxPause time.Duration
+
+	notifiers []Notifier
+
+	metrics metrics.Metrics
+	log     *slog.Logger
 }
 
 func NewSessionRunner(s *domain.Session) *sessionRunner {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &sessionRunner{
+	r := &sessionRunner{
 		session: s,
 		ctx:     ctx,
 		cancel:  cancel,
-		events:  make(chan StepEvent, len(s.Steps)+1),
+		subs:    make(map[chan StepEvent]struct{}),
 		steps:   make(map[int]*stepControl),
+		metrics: metrics.Nop{},
+		log:     slog.Default().With("session_id", s.ID),
+
+		lastActive: time.Now(),
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
+
+	// steps checkpointed mid-run come back paused at their elapsed time
+	s.Restore()
+	now := time.Now()
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.State == domain.StatePaused {
+			r.steps[i] = &stepControl{
+				step:     step,
+				ran:      time.Duration(step.Elapsed) * time.Second,
+				paused:   time.Duration(step.PausedSec) * time.Second,
+				pausedAt: now,
+			}
+		}
+	}
+
+	return r
 }
 
+// setMaxPause sets the pause policy and applies it to steps that are
+// already paused, such as restored ones.
+func (r *sessionRunner) setMaxPause(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.maxPause = d
+	for _, sc := range r.steps {
+		if sc.step.State == domain.StatePaused {
+			r.limitPause(sc)
+		}
+	}
+}
+
+// StartStep runs pending step idx. It returns a domain.TransitionError or
+// domain.ErrStepOutOfOrder if the session's state machine does not allow
+// it.
 func (r *sessionRunner) StartStep(idx int) error {
+	return r.run(idx, r.session.StartStep)
+}
+
+// ResumeStep continues paused step idx from where it was paused.
+func (r *sessionRunner) ResumeStep(idx int) error {
+	return r.run(idx, r.session.ResumeStep)
+}
+
+func (r *sessionRunner) run(idx int, transition func(int) error) error {
 	r.mu.Lock()
 	if idx < 0 || idx >= len(r.session.Steps) {
 		r.mu.Unlock()
-		return errors.New("invalid step index")
+		return ErrInvalidStep
+	}
+	if err := transition(idx); err != nil {
+		r.mu.Unlock()
+		return err
 	}
+	// starting the next step by hand cuts its rest short
+	r.endRest()
 
+	now := time.Now()
 	step := &r.session.Steps[idx]
-
 	sc, exists := r.steps[idx]
 	if !exists {
-		sc = &stepControl{
-			step:         step,
-			cancel:       make(chan struct{}),
-			elapsedSoFar: 0,
-		}
+		sc = &stepControl{step: step}
 		r.steps[idx] = sc
-	} else if !sc.paused && !step.Completed && !step.StartedAt.IsZero() {
-		r.mu.Unlock()
-		return errors.New("step already running")
-	} else if sc.paused {
-		sc.cancel = make(chan struct{})
-		sc.paused = false
 	}
-
-	startTime := time.Now()
+	sc.endPause(now)
+	sc.cancel = make(chan struct{})
+	sc.runStarted = now
 	if step.StartedAt.IsZero() {
-		step.StartedAt = startTime
+		step.StartedAt = now
+		r.notify(func(ctx context.Context, n Notifier, s domain.Session) {
+			n.StepStarted(ctx, s, idx)
+		})
 	}
+	r.lastActive = now
 
-	r.session.CurrentIdx = idx
+	ran, cancel, duration := sc.ran, sc.cancel, step.Duration
 	r.mu.Unlock()
 
-	go func(s *domain.Step, sc *stepControl) {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				elapsed := sc.elapsedSoFar + int(time.Since(startTime).Seconds())
-				select {
-				case r.events <- StepEvent{Index: s.Index, Elapsed: elapsed}:
-				default:
-				}
-				if elapsed >= s.Duration {
-					r.mu.Lock()
-					s.Completed = true
-					r.mu.Unlock()
-					r.sendStepDone()
-					return
-				}
-
-			case <-sc.cancel:
-				r.mu.Lock()
-				sc.elapsedSoFar += int(time.Since(startTime).Seconds())
-				r.mu.Unlock()
-				return
+	r.log.Debug("step started", "step", idx, "resumed_at", int(ran/time.Second))
+	r.metrics.StepStarted()
+	go r.tick(sc, idx, duration, ran, now, cancel)
 
-			case <-r.ctx.Done():
+	return nil
+}
+
+// tick publishes a running step's elapsed time on every whole second it
+// has run and completes the step once it has run for its duration. A
+// started step's index and duration do not change, so they are passed in
+// rather than read from the step without r.mu.
+func (r *sessionRunner) tick(sc *stepControl, idx, duration int, ran time.Duration, started time.Time, cancel <-chan struct{}) {
+	defer r.metrics.StepStopped()
+
+	timer := time.NewTimer(time.Second - ran%time.Second)
+	defer timer.Stop()
+
+	for {
+		select {
+		case now := <-timer.C:
+			total := ran + now.Sub(started)
+			elapsed := int(total / time.Second)
+			r.publish(StepEvent{Index: idx, Elapsed: elapsed})
+			if elapsed >= duration {
+				r.completeStep(sc, now)
 				return
 			}
+			timer.Reset(time.Second - total%time.Second)
+
+		case <-cancel:
+			return
+
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// completeStep is called by a step's goroutine once it has run for its
+// full duration.
+func (r *sessionRunner) completeStep(sc *stepControl, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the step was paused or stopped while the tick was sent
+	if sc.step.State != domain.StateRunning {
+		return
+	}
+	if err := r.session.CompleteStep(sc.step.Index); err != nil {
+		return
+	}
+	r.halt(sc, now)
+	r.log.Debug("step completed", "step", sc.step.Index, "elapsed", sc.step.Elapsed)
+	r.finishStep(sc.step.Index)
+	r.notifyCompleted(sc.step.Index)
+}
+
+// notifyStarted tells the notifiers that the session was started.
+func (r *sessionRunner) notifyStarted() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notify(func(ctx context.Context, n Notifier, s domain.Session) {
+		n.SessionStarted(ctx, s)
+	})
+}
+
+// notifyCompleted tells the notifiers that step idx completed, and that
+// the session completed if it was the last step. It must be called with
+// r.mu held.
+func (r *sessionRunner) notifyCompleted(idx int) {
+	r.notify(func(ctx context.Context, n Notifier, s domain.Session) {
+		n.StepCompleted(ctx, s, idx)
+		if s.State == domain.StateCompleted {
+			n.SessionCompleted(ctx, s)
 		}
-	}(step, sc)
+	})
+}
+
+// notify calls fn for each notifier in the background with a snapshot of
+// the session, so a slow notifier holds up neither the runner nor the
+// others. It must be called with r.mu held.
+func (r *sessionRunner) notify(fn func(ctx context.Context, n Notifier, s domain.Session)) {
+	if len(r.notifiers) == 0 {
+		return
+	}
+
+	s := *r.snapshot()
+	for _, n := range r.notifiers {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+			fn(ctx, n, s)
+		}()
+	}
+}
+
+// finishStep follows a step completing: the session ends with its last
+// step, and an auto-advancing session rests before the next one. It must
+// be called with r.mu held.
+func (r *sessionRunner) finishStep(idx int) {
+	if r.session.State == domain.StateCompleted {
+		r.publish(StepEvent{}) // empty event indicates session done
+		return
+	}
+	if r.session.AutoAdvance != nil {
+		r.startRest(idx + 1)
+	}
+}
+
+// startRest schedules step next to start once its rest is over. It must
+// be called with r.mu held.
+func (r *sessionRunner) startRest(next int) {
+	d := r.session.AutoAdvance.Rest(r.rng)
+	rs := &rest{next: next}
+	rs.timer = time.AfterFunc(d, func() { r.advance(rs) })
+	r.rest = rs
+
+	r.log.Debug("rest started", "next_step", next, "rest", d)
+	r.publish(StepEvent{Type: EventRestStarted, Index: next, Rest: int(d.Seconds())})
+}
+
+// advance starts the step that follows rs, unless the rest was cut short or
+// the session ended in the meantime.
+func (r *sessionRunner) advance(rs *rest) {
+	r.mu.Lock()
+	if r.rest != rs || r.ctx.Err() != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.endRest()
+	r.mu.Unlock()
+
+	if err := r.StartStep(rs.next); err != nil {
+		r.log.Debug("failed to auto-advance", "step", rs.next, "err", err)
+	}
+}
+
+// endRest ends the rest in progress, if any. It must be called with r.mu
+// held.
+func (r *sessionRunner) endRest() {
+	if r.rest == nil {
+		return
+	}
+	r.rest.timer.Stop()
+	r.publish(StepEvent{Type: EventRestEnded, Index: r.rest.next})
+	r.rest = nil
+}
+
+// PauseStep pauses running step idx. With a pause limit, the step is
+// aborted if it is not resumed in time.
+func (r *sessionRunner) PauseStep(idx int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if idx < 0 || idx >= len(r.session.Steps) {
+		return ErrInvalidStep
+	}
+	if err := r.session.PauseStep(idx); err != nil {
+		return err
+	}
 
+	now := time.Now()
+	sc := r.steps[idx]
+	r.halt(sc, now)
+	sc.pausedAt = now
+	r.limitPause(sc)
+	r.log.Debug("step paused", "step", idx, "elapsed", sc.step.Elapsed)
 	return nil
 }
 
+// StopStep ends running or paused step idx now, keeping the time it
+// actually ran, and moves on as if it had run its full duration.
 func (r *sessionRunner) StopStep(idx int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	sc, ok := r.steps[idx]
-	if !ok || sc.paused {
-		return errors.New("step not running")
+	if idx < 0 || idx >= len(r.session.Steps) {
+		return ErrInvalidStep
+	}
+	running := r.session.Steps[idx].State == domain.StateRunning
+	if err := r.session.CompleteStep(idx); err != nil {
+		return err
 	}
 
-	sc.paused = true
-	close(sc.cancel)
+	now := time.Now()
+	sc := r.steps[idx]
+	if running {
+		r.halt(sc, now)
+	} else {
+		sc.endPause(now)
+		r.lastActive = now
+	}
+	r.log.Debug("step stopped", "step", idx, "elapsed", sc.step.Elapsed)
+	r.finishStep(idx)
+	r.notifyCompleted(idx)
 	return nil
 }
 
+// InsertStep adds a step of duration seconds before pending step idx.
+func (r *sessionRunner) InsertStep(idx, duration int) error {
+	return r.editPlan(idx, func(at time.Time) error {
+		return r.session.InsertStep(idx, duration, at)
+	})
+}
+
+// RemoveStep drops pending step idx from the plan.
+func (r *sessionRunner) RemoveStep(idx int) error {
+	return r.editPlan(idx, func(at time.Time) error {
+		return r.session.RemoveStep(idx, at)
+	})
+}
+
+// SetStepDuration changes the duration of pending step idx.
+func (r *sessionRunner) SetStepDuration(idx, duration int) error {
+	return r.editPlan(idx, func(at time.Time) error {
+		return r.session.SetStepDuration(idx, duration, at)
+	})
+}
+
+// editPlan applies an edit to the session's pending steps and tells
+// subscribers the plan changed.
+func (r *sessionRunner) editPlan(idx int, edit func(at time.Time) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if idx < 0 || idx >= len(r.session.Steps) {
+		return ErrInvalidStep
+	}
+	now := time.Now()
+	if err := edit(now); err != nil {
+		return err
+	}
+
+	// started steps come before every pending one and keep their index,
+	// but inserting may have moved the steps to a new array
+	for i, sc := range r.steps {
+		sc.step = &r.session.Steps[i]
+	}
+	r.lastActive = now
+	r.log.Debug("plan changed", "step", idx, "steps", len(r.session.Steps))
+	r.publish(StepEvent{Type: EventPlanChanged, Index: idx})
+	return nil
+}
+
+// limitPause arms the pause limit for a step that has just been paused. It
+// must be called with r.mu held.
+func (r *sessionRunner) limitPause(sc *stepControl) {
+	if r.maxPause <= 0 {
+		return
+	}
+	pausedAt := sc.pausedAt
+	sc.pauseLimit = time.AfterFunc(r.maxPause, func() { r.abortPausedStep(sc, pausedAt) })
+}
+
+// abortPausedStep aborts a step whose pause starting at pausedAt went on
+// too long, unless it has been resumed or ended since.
+func (r *sessionRunner) abortPausedStep(sc *stepControl, pausedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if sc.step.State != domain.StatePaused || !sc.pausedAt.Equal(pausedAt) || r.ctx.Err() != nil {
+		return
+	}
+	if err := r.session.AbortStep(sc.step.Index); err != nil {
+		return
+	}
+
+	now := time.Now()
+	sc.endPause(now)
+	r.lastActive = now
+	r.log.Info("aborted step paused too long", "step", sc.step.Index, "paused_sec", sc.step.PausedSec)
+	r.publish(StepEvent{Type: EventStepAborted, Index: sc.step.Index, Elapsed: sc.step.Elapsed})
+}
+
+// halt stops the timer of a step that has just left the running state,
+// keeping the time it ran. It must be called with r.mu held.
+func (r *sessionRunner) halt(sc *stepControl, now time.Time) {
+	sc.ran += now.Sub(sc.runStarted)
+	sc.step.Elapsed = int(sc.ran / time.Second)
+	close(sc.cancel)
+	r.lastActive = now
+}
+
+func (r *sessionRunner) runningSteps() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, sc := range r.steps {
+		if sc.step.State == domain.StateRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// Stop ends the runner and closes its subscriptions, ending the streams
+// following it.
 func (r *sessionRunner) Stop() {
 	r.cancel()
+
+	r.subsMu.Lock()
+	defer r.subsMu.Unlock()
+	for ch := range r.subs {
+		close(ch)
+	}
+	r.subs = nil
 }
 
-func (r *sessionRunner) Events() <-chan StepEvent {
-	return r.events
+// Subscribe returns a channel receiving the runner's events and a function
+// that ends the subscription. Every subscriber sees every event; slow
+// subscribers miss ticks rather than blocking the runner. The channel is
+// closed when the runner stops.
+func (r *sessionRunner) Subscribe() (<-chan StepEvent, func()) {
+	r.mu.Lock()
+	ch := make(chan StepEvent, len(r.session.Steps)+1)
+	r.mu.Unlock()
+
+	r.subsMu.Lock()
+	if r.subs == nil {
+		close(ch)
+	} else {
+		r.subs[ch] = struct{}{}
+	}
+	r.subsMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			r.subsMu.Lock()
+			delete(r.subs, ch)
+			r.subsMu.Unlock()
+		})
+	}
+}
+
+func (r *sessionRunner) publish(ev StepEvent) {
+	r.subsMu.Lock()
+	defer r.subsMu.Unlock()
+
+	for ch := range r.subs {
+		select {
+		case ch <- ev:
+		default:
+			r.metrics.EventDropped()
+			r.log.Debug("dropped event for slow subscriber", "step", ev.Index)
+		}
+	}
+
+	// the IDs never change, so they are safe to read without r.mu
+	if r.all != nil && !r.all.publish(SessionEvent{SessionID: r.session.ID, UserID: r.session.UserID, StepEvent: ev}) {
+		r.metrics.EventDropped()
+		r.log.Debug("dropped event for slow subscriber to all sessions", "step", ev.Index)
+	}
 }
 
+// Session returns a copy of the session as Snapshot does.
 func (r *sessionRunner) Session() *domain.Session {
+	return r.Snapshot()
+}
+
+// Snapshot returns a copy of the session with each step's Elapsed and
+// PausedSec including a run or pause in progress.
+func (r *sessionRunner) Snapshot() *domain.Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshot()
+}
+
+// snapshot is Snapshot for callers holding r.mu.
+func (r *sessionRunner) snapshot() *domain.Session {
+	snapshot := *r.session
+	snapshot.Steps = append([]domain.Step(nil), r.session.Steps...)
+	snapshot.PlanChanges = append([]domain.PlanChange(nil), r.session.PlanChanges...)
+
+	now := time.Now()
+	for idx, sc := range r.steps {
+		snapshot.Steps[idx].Elapsed = int(sc.elapsed(now) / time.Second)
+		snapshot.Steps[idx].PausedSec = int(sc.pausedFor(now) / time.Second)
+	}
+
+	return &snapshot
+}
+
+// MarkCompleted records that the user completed the session and it has
+// been saved to history. A step still running is stopped and aborted.
+func (r *sessionRunner) MarkCompleted() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	copy := *r.session
-	return &copy
+
+	completed := r.session.State == domain.StateCompleted
+	if err := r.end(r.session.Complete); err != nil {
+		return err
+	}
+	r.saved = true
+	// a session ending with its last step was notified then
+	if !completed {
+		r.notify(func(ctx context.Context, n Notifier, s domain.Session) {
+			n.SessionCompleted(ctx, s)
+		})
+	}
+	return nil
 }
 
-func (r *sessionRunner) StopAllSteps() {
+// Abort ends a session the user did not complete, stopping a step still
+// running. Sessions that already ended are left as they are.
+func (r *sessionRunner) Abort() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if !r.session.State.Final() {
+		r.end(r.session.Abort)
+	}
+}
+
+// end applies a transition that ends the session and stops the clock of
+// the step that was running or paused. It must be called with r.mu held.
+func (r *sessionRunner) end(transition func() error) error {
+	var running, paused []*stepControl
 	for _, sc := range r.steps {
-		if !sc.paused {
-			sc.paused = true
-			select {
-			case <-sc.cancel:
-			default:
-				close(sc.cancel)
-			}
+		switch sc.step.State {
+		case domain.StateRunning:
+			running = append(running, sc)
+		case domain.StatePaused:
+			paused = append(paused, sc)
 		}
 	}
+	if err := transition(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, sc := range running {
+		r.halt(sc, now)
+	}
+	for _, sc := range paused {
+		sc.endPause(now)
+	}
+	r.endRest()
+	return nil
 }
 
-func (r *sessionRunner) MarkCompleted() {
+func (r *sessionRunner) isSaved() bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.session.Completed = true
+	return r.saved
 }
 
-func (r *sessionRunner) sendStepDone() {
+// idleFor returns how long the session has gone without a running step, a
+// rest, or any step being started or stopped.
+func (r *sessionRunner) idleFor(now time.Time) time.Duration {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.idleForLocked(now)
+}
 
-	allDone := true
-	for _, st := range r.session.Steps {
-		if !st.Completed {
-			allDone = false
-			break
-		}
+// abortIfIdle aborts the session if it is unsaved and has been idle for at
+// least timeout, and reports whether it was. Checking and aborting at once
+// keeps a step started meanwhile from being aborted.
+func (r *sessionRunner) abortIfIdle(now time.Time, timeout time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.saved || r.idleForLocked(now) < timeout {
+		return false
 	}
-	if allDone {
-		r.session.Completed = true
-		select {
-		case r.events <- StepEvent{}: // empty event indicates session done
-		default:
+	if !r.session.State.Final() {
+		r.end(r.session.Abort)
+	}
+	return true
+}
+
+// idleForLocked is idleFor with r.mu held.
+func (r *sessionRunner) idleForLocked(now time.Time) time.Duration {
+	if r.rest != nil {
+		return 0
+	}
+	for _, sc := range r.steps {
+		if sc.step.State == domain.StateRunning {
+			return 0
 		}
 	}
+	return now.Sub(r.lastActive)
 }
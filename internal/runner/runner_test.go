@@ -1,10 +1,15 @@
 package runner_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/metrics"
 	"github.com/hperssn/hound/internal/runner"
 )
 
@@ -50,7 +55,7 @@ func TestSessionRunner_StartStep(t *testing.T) {
 	}
 }
 
-func TestSessionRunner_StopStep(t *testing.T) {
+func TestSessionRunner_PauseStep(t *testing.T) {
 	s := &domain.Session{
 		ID: "test-session",
 		Steps: []domain.Step{
@@ -66,16 +71,72 @@ func TestSessionRunner_StopStep(t *testing.T) {
 	}
 
 	// Let it run for a bit
-	time.Sleep(2 * time.Second)
+	time.Sleep(1200 * time.Millisecond)
+
+	// Pause the step
+	if err := r.PauseStep(0); err != nil {
+		t.Fatalf("failed to pause step: %v", err)
+	}
+	time.Sleep(1200 * time.Millisecond)
+
+	sess := r.Session()
+	if step := sess.Steps[0]; step.Completed || step.State != domain.StatePaused || step.Elapsed != 1 || step.PausedSec != 1 {
+		t.Fatalf("paused step = %+v, want 1s run and 1s paused", step)
+	}
+
+	if err := r.StartStep(0); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("starting a paused step err = %v, want ErrInvalidTransition", err)
+	}
+	if err := r.ResumeStep(0); err != nil {
+		t.Fatalf("failed to resume step: %v", err)
+	}
+	time.Sleep(1200 * time.Millisecond)
+
+	if step := r.Session().Steps[0]; step.State != domain.StateRunning || step.Elapsed != 2 || step.PausedSec != 1 {
+		t.Fatalf("resumed step = %+v, want 2s run and 1s paused", step)
+	}
+}
+
+func TestSessionRunner_StopStep(t *testing.T) {
+	s := &domain.Session{
+		ID: "test-session",
+		Steps: []domain.Step{
+			{Index: 0, Duration: 10},
+			{Index: 1, Duration: 10},
+		},
+	}
+
+	r := runner.NewSessionRunner(s)
+	defer r.Stop()
 
-	// Stop the step
+	if err := r.StartStep(0); err != nil {
+		t.Fatalf("failed to start step: %v", err)
+	}
+	time.Sleep(1200 * time.Millisecond)
+
+	// stopping ends the step early with the time it actually ran
 	if err := r.StopStep(0); err != nil {
 		t.Fatalf("failed to stop step: %v", err)
 	}
+	if step := r.Session().Steps[0]; step.State != domain.StateCompleted || step.Elapsed != 1 {
+		t.Fatalf("stopped step = %+v, want completed after 1s", step)
+	}
+	if err := r.StopStep(0); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("stopping a stopped step err = %v, want ErrInvalidTransition", err)
+	}
 
-	sess := r.Session()
-	if sess.Steps[0].Completed {
-		t.Error("step should not be completed after stopping")
+	// a paused step can be stopped too
+	if err := r.StartStep(1); err != nil {
+		t.Fatalf("failed to start the next step: %v", err)
+	}
+	if err := r.PauseStep(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.StopStep(1); err != nil {
+		t.Fatalf("failed to stop a paused step: %v", err)
+	}
+	if sess := r.Session(); sess.State != domain.StateCompleted {
+		t.Fatalf("session is %s after stopping its last step, want completed", sess.State)
 	}
 }
 
@@ -88,7 +149,8 @@ func TestSessionRunner_Events(t *testing.T) {
 	}
 
 	r := runner.NewSessionRunner(s)
-	events := r.Events()
+	events, unsubscribe := r.Subscribe()
+	defer unsubscribe()
 
 	if err := r.StartStep(0); err != nil {
 		t.Fatalf("failed to start step: %v", err)
@@ -118,7 +180,7 @@ func TestSessionManager_StartSession(t *testing.T) {
 	manager := runner.NewSessionManager()
 	s := domain.NewSession("", "", 10)
 
-	if err := manager.StartSession(s); err != nil {
+	if err := manager.StartSession(context.Background(), s); err != nil {
 		t.Fatalf("failed to start session: %v", err)
 	}
 
@@ -136,11 +198,11 @@ func TestSessionManager_StopSession(t *testing.T) {
 	manager := runner.NewSessionManager()
 	s := domain.NewSession("", "", 10)
 
-	if err := manager.StartSession(s); err != nil {
+	if err := manager.StartSession(context.Background(), s); err != nil {
 		t.Fatal(err)
 	}
 
-	if err := manager.StopSession(s.ID); err != nil {
+	if err := manager.StopSession(context.Background(), s.ID); err != nil {
 		t.Fatalf("failed to stop session: %v", err)
 	}
 
@@ -150,3 +212,908 @@ func TestSessionManager_StopSession(t *testing.T) {
 		t.Error("session should be removed after stopping")
 	}
 }
+
+func TestSessionManager_GetSessionForUser(t *testing.T) {
+	manager := runner.NewSessionManager()
+	s := domain.NewSession("", "alice", 10)
+
+	if err := manager.StartSession(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	got, role, err := manager.GetSessionForUser(context.Background(), s.ID, "alice")
+	if err != nil {
+		t.Fatalf("owner lookup failed: %v", err)
+	}
+	if got.ID != s.ID {
+		t.Errorf("expected session ID %s, got %s", s.ID, got.ID)
+	}
+	if role != domain.RoleOwner {
+		t.Errorf("expected role %s, got %s", domain.RoleOwner, role)
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		userID string
+	}{
+		{"foreign user", s.ID, "bob"},
+		{"empty user", s.ID, ""},
+		{"unknown session", "missing", "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := manager.GetSessionForUser(context.Background(), tt.id, tt.userID); err != runner.ErrSessionNotFound {
+				t.Fatalf("GetSessionForUser(%q, %q) err = %v, want ErrSessionNotFound", tt.id, tt.userID, err)
+			}
+		})
+	}
+}
+
+type householdRoles map[string]domain.Role
+
+func (h householdRoles) MemberRole(_ context.Context, householdID, userID string) (domain.Role, error) {
+	return h[householdID+"/"+userID], nil
+}
+
+func TestSessionManager_GetSessionForUserHousehold(t *testing.T) {
+	manager := runner.NewSessionManager(runner.WithAuthorizer(householdRoles{
+		"home/alice": domain.RoleOwner,
+		"home/sam":   domain.RoleViewer,
+	}))
+
+	s := domain.NewSession("", "alice", 10)
+	s.HouseholdID = "home"
+	if err := manager.StartSession(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	_, role, err := manager.GetSessionForUser(context.Background(), s.ID, "sam")
+	if err != nil {
+		t.Fatalf("member lookup failed: %v", err)
+	}
+	if role != domain.RoleViewer {
+		t.Errorf("expected role %s, got %s", domain.RoleViewer, role)
+	}
+
+	if _, _, err := manager.GetSessionForUser(context.Background(), s.ID, "bob"); err != runner.ErrSessionNotFound {
+		t.Fatalf("non-member err = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestSessionRunner_SubscribersReceiveAllEvents(t *testing.T) {
+	s := &domain.Session{
+		ID: "test-session",
+		Steps: []domain.Step{
+			{Index: 0, Duration: 1},
+		},
+	}
+
+	r := runner.NewSessionRunner(s)
+	first, unsubscribeFirst := r.Subscribe()
+	second, unsubscribeSecond := r.Subscribe()
+	defer unsubscribeFirst()
+	defer unsubscribeSecond()
+
+	if err := r.StartStep(0); err != nil {
+		t.Fatalf("failed to start step: %v", err)
+	}
+
+	for i, events := range []<-chan runner.StepEvent{first, second} {
+		select {
+		case event := <-events:
+			if event.Index != 0 {
+				t.Errorf("subscriber %d: expected event for step 0, got step %d", i, event.Index)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("subscriber %d received no events", i)
+		}
+	}
+}
+
+type fakeCheckpointer struct {
+	saved []domain.Session
+}
+
+func (f *fakeCheckpointer) SaveCheckpoints(_ context.Context, sessions []domain.Session) error {
+	f.saved = append(f.saved, sessions...)
+	return nil
+}
+
+func TestSessionManager_ShutdownCheckpointsSessions(t *testing.T) {
+	checkpoints := &fakeCheckpointer{}
+	manager := runner.NewSessionManager(runner.WithCheckpointer(checkpoints))
+
+	s := &domain.Session{
+		ID:          "test-session",
+		UserID:      "alice",
+		HouseholdID: "alice",
+		Steps: []domain.Step{
+			{Index: 0, Duration: 30},
+			{Index: 1, Duration: 30},
+		},
+	}
+	if err := manager.StartSession(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe, _ := manager.Subscribe(s.ID)
+	defer unsubscribe()
+
+	if err := manager.StartStep(context.Background(), s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2100 * time.Millisecond)
+
+	if err := manager.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	select {
+	case <-manager.Done():
+	default:
+		t.Fatal("Done should be closed after shutdown")
+	}
+
+	restarting := false
+	for len(events) > 0 {
+		if ev := <-events; ev.Type == runner.EventServerRestarting {
+			restarting = true
+		}
+	}
+	if !restarting {
+		t.Error("subscribers should receive a server_restarting event")
+	}
+
+	if err := manager.StartSession(context.Background(), domain.NewSession("", "alice", 10)); err != runner.ErrShuttingDown {
+		t.Errorf("StartSession after shutdown err = %v, want ErrShuttingDown", err)
+	}
+	if err := manager.StartStep(context.Background(), s.ID, 1); err != runner.ErrShuttingDown {
+		t.Errorf("StartStep after shutdown err = %v, want ErrShuttingDown", err)
+	}
+	// the session was checkpointed; changes to it would be lost
+	for name, edit := range map[string]func() error{
+		"InsertStep":      func() error { return manager.InsertStep(context.Background(), s.ID, 1, 30) },
+		"RemoveStep":      func() error { return manager.RemoveStep(context.Background(), s.ID, 1) },
+		"SetStepDuration": func() error { return manager.SetStepDuration(context.Background(), s.ID, 1, 30) },
+		"PauseStep":       func() error { return manager.PauseStep(context.Background(), s.ID, 0) },
+	} {
+		if err := edit(); err != runner.ErrShuttingDown {
+			t.Errorf("%s after shutdown err = %v, want ErrShuttingDown", name, err)
+		}
+	}
+	if err := manager.CompleteSession(context.Background(), s.ID); err != runner.ErrShuttingDown {
+		t.Errorf("CompleteSession after shutdown err = %v, want ErrShuttingDown", err)
+	}
+	if err := manager.StopSession(context.Background(), s.ID); err != runner.ErrShuttingDown {
+		t.Errorf("StopSession after shutdown err = %v, want ErrShuttingDown", err)
+	}
+
+	if len(checkpoints.saved) != 1 {
+		t.Fatalf("expected 1 checkpoint, got %d", len(checkpoints.saved))
+	}
+	if elapsed := checkpoints.saved[0].Steps[0].Elapsed; elapsed < 2 {
+		t.Errorf("checkpointed elapsed = %d, want at least 2", elapsed)
+	}
+}
+
+func TestSessionManager_RestoreResumesPausedStep(t *testing.T) {
+	manager := runner.NewSessionManager()
+
+	manager.Restore([]domain.Session{{
+		ID:          "restored",
+		UserID:      "alice",
+		HouseholdID: "alice",
+		Steps: []domain.Step{
+			{Index: 0, Duration: 3, StartedAt: time.Now().Add(-time.Minute), Elapsed: 2},
+		},
+	}})
+
+	if err := manager.PauseStep(context.Background(), "restored", 0); err == nil {
+		t.Error("restored step should be paused, not running")
+	}
+
+	if err := manager.ResumeStep(context.Background(), "restored", 0); err != nil {
+		t.Fatalf("failed to resume restored step: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+
+	sess, ok := manager.GetSession("restored")
+	if !ok {
+		t.Fatal("restored session not found")
+	}
+	if !sess.Steps[0].Completed {
+		t.Error("resumed step should complete after its remaining second")
+	}
+}
+
+type fakeMetrics struct {
+	metrics.Nop
+
+	mu             sync.Mutex
+	activeSessions int
+	runningSteps   int
+	dropped        int
+}
+
+func (f *fakeMetrics) SetActiveSessions(n int) { f.mu.Lock(); f.activeSessions = n; f.mu.Unlock() }
+func (f *fakeMetrics) StepStarted()            { f.mu.Lock(); f.runningSteps++; f.mu.Unlock() }
+func (f *fakeMetrics) StepStopped()            { f.mu.Lock(); f.runningSteps--; f.mu.Unlock() }
+func (f *fakeMetrics) EventDropped()           { f.mu.Lock(); f.dropped++; f.mu.Unlock() }
+
+func (f *fakeMetrics) snapshot() (active, running, dropped int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.activeSessions, f.runningSteps, f.dropped
+}
+
+func TestSessionManager_Metrics(t *testing.T) {
+	fm := &fakeMetrics{}
+	m := runner.NewSessionManager(runner.WithMetrics(fm))
+
+	s := &domain.Session{
+		ID:          "metrics-session",
+		UserID:      "user-1",
+		HouseholdID: "user-1",
+		Steps:       []domain.Step{{Index: 0, Duration: 3}},
+	}
+	if err := m.StartSession(context.Background(), s); err != nil {
+		t.Fatalf("failed to start session: %v", err)
+	}
+
+	// a subscriber that never reads overflows its buffer once the step
+	// has ticked a few times
+	_, unsubscribe, _ := m.Subscribe(s.ID)
+	defer unsubscribe()
+
+	if err := m.StartStep(context.Background(), s.ID, 0); err != nil {
+		t.Fatalf("failed to start step: %v", err)
+	}
+	if _, running, _ := fm.snapshot(); running != 1 {
+		t.Errorf("running steps = %d, want 1", running)
+	}
+
+	time.Sleep(4 * time.Second)
+
+	active, running, dropped := fm.snapshot()
+	if active != 1 {
+		t.Errorf("active sessions = %d, want 1", active)
+	}
+	if running != 0 {
+		t.Errorf("running steps = %d after completion, want 0", running)
+	}
+	if dropped == 0 {
+		t.Error("expected dropped events for a stalled subscriber")
+	}
+
+	if err := m.StopSession(context.Background(), s.ID); err != nil {
+		t.Fatalf("failed to stop session: %v", err)
+	}
+	if active, _, _ := fm.snapshot(); active != 0 {
+		t.Errorf("active sessions = %d after stop, want 0", active)
+	}
+}
+
+func TestSessionManager_Load(t *testing.T) {
+	m := runner.NewSessionManager()
+	s := &domain.Session{
+		ID:          "load-session",
+		UserID:      "user-1",
+		HouseholdID: "user-1",
+		Steps:       []domain.Step{{Index: 0, Duration: 60}, {Index: 1, Duration: 60}},
+	}
+	if err := m.StartSession(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StartStep(context.Background(), s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if load := m.Load(); load.ActiveSessions != 1 || load.RunningSteps != 1 || load.ShuttingDown {
+		t.Fatalf("load = %+v, want one session with one running step", load)
+	}
+
+	if err := m.PauseStep(context.Background(), s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if load := m.Load(); load.RunningSteps != 0 {
+		t.Fatalf("running steps = %d after pause, want 0", load.RunningSteps)
+	}
+
+	m.Shutdown(context.Background())
+	if load := m.Load(); !load.ShuttingDown {
+		t.Fatal("load should report shutdown")
+	}
+}
+
+type fakeArchiver struct {
+	mu       sync.Mutex
+	failures int
+	archived []domain.Session
+}
+
+func (f *fakeArchiver) ArchiveSession(_ context.Context, s domain.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return context.DeadlineExceeded
+	}
+	f.archived = append(f.archived, s)
+	return nil
+}
+
+func (f *fakeArchiver) sessions() []domain.Session {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]domain.Session(nil), f.archived...)
+}
+
+func TestSessionManager_StopSessionArchives(t *testing.T) {
+	archiver := &fakeArchiver{}
+	manager := runner.NewSessionManager(runner.WithArchiver(archiver))
+
+	untouched := domain.NewSession("", "alice", 10)
+	if err := manager.StartSession(context.Background(), untouched); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StopSession(context.Background(), untouched.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := archiver.sessions(); len(got) != 0 {
+		t.Fatalf("session without progress was archived: %+v", got)
+	}
+
+	completed := domain.NewSession("", "alice", 10)
+	if err := manager.StartSession(context.Background(), completed); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StartStep(context.Background(), completed.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.CompleteSession(context.Background(), completed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StopSession(context.Background(), completed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := archiver.sessions(); len(got) != 0 {
+		t.Fatalf("completed session was archived: %+v", got)
+	}
+
+	s := domain.NewSession("", "alice", 10)
+	if err := manager.StartSession(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StartStep(context.Background(), s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if err := manager.StopSession(context.Background(), s.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	got := archiver.sessions()
+	if len(got) != 1 || got[0].ID != s.ID {
+		t.Fatalf("archived = %+v, want the stopped session", got)
+	}
+	if step := got[0].Steps[0]; step.StartedAt.IsZero() || step.Elapsed < 1 {
+		t.Errorf("archived step = %+v, want its partial progress", step)
+	}
+}
+
+func TestSessionManager_ArchivesIdleSessions(t *testing.T) {
+	archiver := &fakeArchiver{failures: 1}
+	manager := runner.NewSessionManager(
+		runner.WithArchiver(archiver),
+		runner.WithIdleTimeout(50*time.Millisecond),
+		runner.WithCleanup(10*time.Millisecond, time.Hour),
+	)
+	defer manager.Shutdown(context.Background())
+
+	idle := domain.NewSession("", "alice", 10)
+	saved := domain.NewSession("", "alice", 10)
+	for _, s := range []*domain.Session{idle, saved} {
+		if err := manager.StartSession(context.Background(), s); err != nil {
+			t.Fatal(err)
+		}
+		if err := manager.StartStep(context.Background(), s.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := manager.PauseStep(context.Background(), s.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.CompleteSession(context.Background(), saved.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := manager.GetSession(idle.ID); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle session was not archived")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	got := archiver.sessions()
+	if len(got) != 1 || got[0].ID != idle.ID {
+		t.Fatalf("archived = %+v, want only the idle session", got)
+	}
+	if got[0].State != domain.StateAborted || got[0].Steps[0].State == domain.StatePaused {
+		t.Errorf("archived session = %+v, want it aborted", got[0])
+	}
+	if _, ok := manager.GetSession(saved.ID); !ok {
+		t.Error("completed session should be kept until its TTL")
+	}
+}
+
+func TestSessionManager_EvictsIdleSessions(t *testing.T) {
+	manager := runner.NewSessionManager(
+		runner.WithIdleTimeout(50*time.Millisecond),
+		runner.WithCleanup(10*time.Millisecond, time.Hour),
+	)
+	defer manager.Shutdown(context.Background())
+
+	s := domain.NewSession("", "alice", 10)
+	if err := manager.StartSession(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := manager.GetSession(s.ID); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle session was not evicted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if load := manager.Load(); load.ActiveSessions != 0 {
+		t.Errorf("active sessions after eviction = %d, want 0", load.ActiveSessions)
+	}
+}
+
+func TestSessionManager_MaxSessionsPerUser(t *testing.T) {
+	manager := runner.NewSessionManager(runner.WithMaxSessionsPerUser(2))
+	ctx := context.Background()
+
+	first := domain.NewSession("", "alice", 10)
+	second := domain.NewSession("", "alice", 10)
+	for _, s := range []*domain.Session{first, second} {
+		if err := manager.StartSession(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := manager.StartSession(ctx, domain.NewSession("", "alice", 10)); err != runner.ErrTooManySessions {
+		t.Fatalf("third session err = %v, want ErrTooManySessions", err)
+	}
+	if err := manager.StartSession(ctx, domain.NewSession("", "bob", 10)); err != nil {
+		t.Fatalf("other user's session err = %v", err)
+	}
+
+	active := manager.ActiveSessions("alice")
+	if len(active) != 2 || active[0].ID != first.ID || active[1].ID != second.ID {
+		t.Fatalf("active sessions = %+v, want both of alice's, oldest first", active)
+	}
+
+	// completed sessions no longer count towards the limit
+	if err := manager.CompleteSession(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := manager.ActiveSessions("alice"); len(got) != 1 || got[0].ID != second.ID {
+		t.Fatalf("active sessions after completing one = %+v", got)
+	}
+	if err := manager.StartSession(ctx, domain.NewSession("", "alice", 10)); err != nil {
+		t.Fatalf("session after completing one err = %v", err)
+	}
+}
+
+func TestSessionManager_StepStateMachine(t *testing.T) {
+	manager := runner.NewSessionManager()
+	ctx := context.Background()
+	s := &domain.Session{
+		ID:          "state-machine",
+		UserID:      "alice",
+		HouseholdID: "alice",
+		Steps:       []domain.Step{{Index: 0, Duration: 1}, {Index: 1, Duration: 60}},
+	}
+	if err := manager.StartSession(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manager.StartStep(ctx, s.ID, 1); !errors.Is(err, domain.ErrStepOutOfOrder) {
+		t.Fatalf("starting step 1 first err = %v, want ErrStepOutOfOrder", err)
+	}
+	if err := manager.PauseStep(ctx, s.ID, 0); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("pausing a pending step err = %v, want ErrInvalidTransition", err)
+	}
+	if err := manager.StopStep(ctx, s.ID, 0); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("stopping a pending step err = %v, want ErrInvalidTransition", err)
+	}
+
+	if err := manager.StartStep(ctx, s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+
+	if err := manager.StartStep(ctx, s.ID, 0); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("restarting a completed step err = %v, want ErrInvalidTransition", err)
+	}
+	if err := manager.StartStep(ctx, s.ID, 1); err != nil {
+		t.Fatalf("starting step 1 after step 0 completed: %v", err)
+	}
+
+	if err := manager.CompleteSession(ctx, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := manager.GetSession(s.ID)
+	if sess.State != domain.StateCompleted || sess.Steps[1].State != domain.StateAborted {
+		t.Fatalf("after completing: session %s, step 1 %s", sess.State, sess.Steps[1].State)
+	}
+	if err := manager.StartStep(ctx, s.ID, 1); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("starting a step in a completed session err = %v, want ErrInvalidTransition", err)
+	}
+}
+
+// nextEvent returns the next event of one of the given types, skipping
+// ticks and other events.
+func nextEvent(t *testing.T, events <-chan runner.StepEvent, types ...string) runner.StepEvent {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			for _, typ := range types {
+				if ev.Type == typ {
+					return ev
+				}
+			}
+		case <-timeout:
+			t.Fatalf("no %v event", types)
+			return runner.StepEvent{}
+		}
+	}
+}
+
+func TestSessionRunner_AutoAdvance(t *testing.T) {
+	s := &domain.Session{
+		ID:          "auto-advance",
+		Steps:       []domain.Step{{Index: 0, Duration: 1}, {Index: 1, Duration: 1}},
+		AutoAdvance: &domain.AutoAdvance{RestSec: 1},
+	}
+	r := runner.NewSessionRunner(s)
+	defer r.Stop()
+	events, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+
+	if err := r.StartStep(0); err != nil {
+		t.Fatal(err)
+	}
+
+	started := nextEvent(t, events, runner.EventRestStarted)
+	if started.Index != 1 || started.Rest != 1 {
+		t.Fatalf("rest_started = %+v, want a 1s rest before step 1", started)
+	}
+	if sess := r.Session(); sess.Steps[1].State != domain.StatePending {
+		t.Fatalf("step 1 is %s during the rest, want pending", sess.Steps[1].State)
+	}
+
+	if ended := nextEvent(t, events, runner.EventRestEnded); ended.Index != 1 {
+		t.Fatalf("rest_ended = %+v, want step 1", ended)
+	}
+	nextEvent(t, events, "") // first tick of step 1
+	time.Sleep(1500 * time.Millisecond)
+
+	if sess := r.Session(); sess.State != domain.StateCompleted {
+		t.Fatalf("session is %s, want completed after both steps", sess.State)
+	}
+}
+
+func TestSessionRunner_StartingTheNextStepEndsTheRest(t *testing.T) {
+	s := &domain.Session{
+		ID:          "auto-advance",
+		Steps:       []domain.Step{{Index: 0, Duration: 1}, {Index: 1, Duration: 60}},
+		AutoAdvance: &domain.AutoAdvance{RestSec: 60},
+	}
+	r := runner.NewSessionRunner(s)
+	defer r.Stop()
+	events, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+
+	if err := r.StartStep(0); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events, runner.EventRestStarted)
+
+	if err := r.StartStep(1); err != nil {
+		t.Fatalf("starting step 1 during its rest: %v", err)
+	}
+	if ended := nextEvent(t, events, runner.EventRestEnded); ended.Index != 1 {
+		t.Fatalf("rest_ended = %+v, want step 1", ended)
+	}
+	if sess := r.Session(); sess.Steps[1].State != domain.StateRunning {
+		t.Fatalf("step 1 is %s, want running", sess.Steps[1].State)
+	}
+}
+
+func TestSessionManager_MaxPauseAbortsStep(t *testing.T) {
+	manager := runner.NewSessionManager(runner.WithMaxPause(50 * time.Millisecond))
+	ctx := context.Background()
+	s := &domain.Session{
+		ID:          "max-pause",
+		UserID:      "alice",
+		HouseholdID: "alice",
+		Steps:       []domain.Step{{Index: 0, Duration: 60}, {Index: 1, Duration: 60}},
+	}
+	if err := manager.StartSession(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe, _ := manager.Subscribe(s.ID)
+	defer unsubscribe()
+
+	if err := manager.StartStep(ctx, s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// resuming in time keeps the step
+	if err := manager.PauseStep(ctx, s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.ResumeStep(ctx, s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if sess, _ := manager.GetSession(s.ID); sess.Steps[0].State != domain.StateRunning {
+		t.Fatalf("step is %s after a short pause, want running", sess.Steps[0].State)
+	}
+
+	if err := manager.PauseStep(ctx, s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, events, runner.EventStepAborted); ev.Index != 0 {
+		t.Fatalf("step_aborted = %+v, want step 0", ev)
+	}
+	if err := manager.ResumeStep(ctx, s.ID, 0); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Fatalf("resuming an aborted step err = %v, want ErrInvalidTransition", err)
+	}
+	if err := manager.StartStep(ctx, s.ID, 1); err != nil {
+		t.Fatalf("starting the step after an aborted one: %v", err)
+	}
+}
+
+func TestSessionRunner_EditPlan(t *testing.T) {
+	s := &domain.Session{
+		ID:        "edit-plan",
+		TargetSec: 120,
+		Steps: []domain.Step{
+			{Index: 0, Duration: 60},
+			{Index: 1, Duration: 60},
+			{Index: 2, Duration: 120},
+		},
+	}
+	r := runner.NewSessionRunner(s)
+	defer r.Stop()
+	events, unsubscribe := r.Subscribe()
+	defer unsubscribe()
+
+	if err := r.StartStep(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.InsertStep(0, 30); !errors.Is(err, domain.ErrStepStarted) {
+		t.Fatalf("inserting before the running step err = %v, want ErrStepStarted", err)
+	}
+	if err := r.RemoveStep(3); !errors.Is(err, runner.ErrInvalidStep) {
+		t.Fatalf("removing a missing step err = %v, want ErrInvalidStep", err)
+	}
+
+	if err := r.InsertStep(1, 30); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, events, runner.EventPlanChanged); ev.Index != 1 {
+		t.Fatalf("plan_changed = %+v, want step 1", ev)
+	}
+	if err := r.SetStepDuration(3, 90); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RemoveStep(2); err != nil {
+		t.Fatal(err)
+	}
+
+	sess := r.Session()
+	if len(sess.Steps) != 3 || sess.Steps[1].Duration != 30 || sess.Steps[2].Duration != 90 || sess.TargetSec != 90 {
+		t.Fatalf("steps = %+v, target %d; want 60, 30 and 90 with target 90", sess.Steps, sess.TargetSec)
+	}
+	if len(sess.PlanChanges) != 3 {
+		t.Fatalf("plan changes = %+v, want 3", sess.PlanChanges)
+	}
+
+	// the running step keeps its clock through the edits
+	if ev := nextEvent(t, events, ""); ev.Index != 0 || ev.Elapsed != 1 {
+		t.Fatalf("tick = %+v, want step 0 at 1s", ev)
+	}
+	if err := r.StopStep(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.StartStep(1); err != nil {
+		t.Fatal(err)
+	}
+	if sess := r.Session(); sess.Steps[0].State != domain.StateCompleted || sess.Steps[0].Elapsed != 1 ||
+		sess.Steps[1].State != domain.StateRunning {
+		t.Fatalf("steps = %+v, want step 0 stopped after 1s and the inserted step running", sess.Steps)
+	}
+}
+
+type fakeNotifier struct {
+	events chan string
+}
+
+func (f *fakeNotifier) SessionStarted(ctx context.Context, s domain.Session) {
+	f.events <- "session started"
+}
+
+func (f *fakeNotifier) StepStarted(ctx context.Context, s domain.Session, idx int) {
+	f.events <- fmt.Sprintf("step %d started", idx)
+}
+
+func (f *fakeNotifier) StepCompleted(ctx context.Context, s domain.Session, idx int) {
+	f.events <- fmt.Sprintf("step %d completed", idx)
+}
+
+func (f *fakeNotifier) SessionCompleted(ctx context.Context, s domain.Session) {
+	f.events <- "session completed"
+}
+
+// next waits for the notifier to hear about want.
+func (f *fakeNotifier) next(t *testing.T, want string) {
+	t.Helper()
+
+	select {
+	case got := <-f.events:
+		if got != want {
+			t.Fatalf("notified %q, want %q", got, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("not notified %q", want)
+	}
+}
+
+func TestSessionManager_Notifiers(t *testing.T) {
+	notifiers := []*fakeNotifier{{events: make(chan string, 10)}, {events: make(chan string, 10)}}
+	manager := runner.NewSessionManager(runner.WithNotifier(notifiers[0]), runner.WithNotifier(notifiers[1]))
+	ctx := context.Background()
+	s := &domain.Session{
+		ID:          "notify",
+		UserID:      "alice",
+		HouseholdID: "alice",
+		Steps:       []domain.Step{{Index: 0, Duration: 60}, {Index: 1, Duration: 1}, {Index: 2, Duration: 60}},
+	}
+	expect := func(want ...string) {
+		t.Helper()
+		for _, n := range notifiers {
+			for _, w := range want {
+				n.next(t, w)
+			}
+		}
+	}
+
+	if err := manager.StartSession(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	expect("session started")
+
+	// resuming is not starting
+	if err := manager.StartStep(ctx, s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	expect("step 0 started")
+	manager.PauseStep(ctx, s.ID, 0)
+	manager.ResumeStep(ctx, s.ID, 0)
+	if err := manager.StopStep(ctx, s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	expect("step 0 completed")
+
+	if err := manager.StartStep(ctx, s.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	expect("step 1 started", "step 1 completed")
+
+	// a session ending with a stopped step completes too
+	if err := manager.StartStep(ctx, s.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	expect("step 2 started")
+	if err := manager.StopStep(ctx, s.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	expect("step 2 completed", "session completed")
+
+	// saving it afterwards is not completing it again
+	if err := manager.CompleteSession(ctx, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-notifiers[0].events:
+		t.Fatalf("unexpected notification %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSessionManager_NotifiesEarlyCompletion(t *testing.T) {
+	n := &fakeNotifier{events: make(chan string, 10)}
+	manager := runner.NewSessionManager(runner.WithNotifier(n))
+	ctx := context.Background()
+	s := &domain.Session{
+		ID:          "early",
+		UserID:      "alice",
+		HouseholdID: "alice",
+		Steps:       []domain.Step{{Index: 0, Duration: 60}, {Index: 1, Duration: 60}},
+	}
+	if err := manager.StartSession(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	n.next(t, "session started")
+	if err := manager.StartStep(ctx, s.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	n.next(t, "step 0 started")
+
+	if err := manager.CompleteSession(ctx, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	n.next(t, "session completed")
+}
+
+func TestSessionManager_SubscribeAll(t *testing.T) {
+	manager := runner.NewSessionManager()
+	ctx := context.Background()
+	events, unsubscribe := manager.SubscribeAll()
+	defer unsubscribe()
+
+	for _, user := range []string{"alice", "bob"} {
+		s := &domain.Session{
+			ID:          user + "-session",
+			UserID:      user,
+			HouseholdID: user,
+			Steps:       []domain.Step{{Index: 0, Duration: 1}},
+		}
+		if err := manager.StartSession(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+		if err := manager.StartStep(ctx, s.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// each session ticks once and then completes
+	seen := make(map[string]int)
+	timeout := time.After(3 * time.Second)
+	for len(seen) < 2 || seen["alice-session"] < 2 || seen["bob-session"] < 2 {
+		select {
+		case ev := <-events:
+			if ev.SessionID != ev.UserID+"-session" {
+				t.Fatalf("event %+v has another session's user", ev)
+			}
+			seen[ev.SessionID]++
+		case <-timeout:
+			t.Fatalf("events per session = %v, want a tick and completion for both", seen)
+		}
+	}
+
+	unsubscribe()
+	s := &domain.Session{ID: "later", UserID: "alice", HouseholdID: "alice", Steps: []domain.Step{{Index: 0, Duration: 60}}}
+	manager.StartSession(ctx, s)
+	manager.StartStep(ctx, s.ID, 0)
+	time.Sleep(1100 * time.Millisecond)
+	select {
+	case ev := <-events:
+		t.Fatalf("received %+v after unsubscribing", ev)
+	default:
+	}
+}
@@ -0,0 +1,200 @@
+// Package scheduler sends users the training reminders they have set up.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+// Notification is a reminder that has come due.
+type Notification struct {
+	UserID     string
+	ReminderID string
+	Title      string
+	Body       string
+	// DueAt is the occurrence of the reminder being sent.
+	DueAt time.Time
+	// SessionID is the session the reminder started, if any.
+	SessionID string
+}
+
+// Notifier delivers notifications to users. It must be safe for concurrent
+// use.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LogNotifier writes notifications to the log. It is used when no other
+// way of reaching users is configured.
+type LogNotifier struct {
+	Log *slog.Logger
+}
+
+func (l LogNotifier) Notify(ctx context.Context, n Notification) error {
+	l.Log.InfoContext(ctx, "reminder due", "user_id", n.UserID, "reminder_id", n.ReminderID, "due_at", n.DueAt)
+	return nil
+}
+
+// Starter starts a training session for a user, for reminders that start
+// one. It must be safe for concurrent use.
+type Starter interface {
+	StartSession(ctx context.Context, userID string) (*domain.Session, error)
+}
+
+// Store is the part of storage.Repository the scheduler needs.
+type Store interface {
+	GetAllReminders(ctx context.Context) ([]domain.Reminder, error)
+	MarkReminderFired(ctx context.Context, id string, at time.Time) error
+}
+
+// Scheduler checks the stored reminders periodically and notifies users of
+// the ones that have come due, starting a session first for those set to.
+// Each occurrence is marked fired in the store before it is sent, so
+// restarts and other instances do not send it again; one that fails to send
+// is retried by this scheduler until it is more than the max delay late.
+type Scheduler struct {
+	store    Store
+	notifier Notifier
+	starter  Starter
+	interval time.Duration
+	maxDelay time.Duration
+	log      *slog.Logger
+
+	mu    sync.Mutex
+	retry map[string]Notification // by reminder ID
+}
+
+type Option func(*Scheduler)
+
+// WithInterval sets how often reminders are checked.
+func WithInterval(d time.Duration) Option {
+	return func(s *Scheduler) { s.interval = d }
+}
+
+// WithMaxDelay sets how late a reminder may still be sent, such as after
+// the server was down when it came due. Later ones are skipped.
+func WithMaxDelay(d time.Duration) Option {
+	return func(s *Scheduler) { s.maxDelay = d }
+}
+
+// WithStarter lets reminders start sessions. Without it reminders set to
+// start one only notify.
+func WithStarter(st Starter) Option {
+	return func(s *Scheduler) { s.starter = st }
+}
+
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Scheduler) { s.log = l }
+}
+
+func New(store Store, notifier Notifier, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		store:    store,
+		notifier: notifier,
+		interval: time.Minute,
+		maxDelay: 15 * time.Minute,
+		log:      slog.Default(),
+		retry:    make(map[string]Notification),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run checks reminders every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := s.Check(ctx, now); err != nil {
+				s.log.Error("failed to check reminders", "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check sends the reminders due at now, and retries those that failed to
+// send earlier. Failing to deliver one reminder is logged and does not hold
+// up the others.
+func (s *Scheduler) Check(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, n := range s.retry {
+		if now.Sub(n.DueAt) > s.maxDelay {
+			s.log.Warn("giving up on reminder", "reminder_id", id, "user_id", n.UserID, "due_at", n.DueAt)
+			delete(s.retry, id)
+			continue
+		}
+		s.send(ctx, n)
+	}
+
+	reminders, err := s.store.GetAllReminders(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reminders {
+		due, ok := r.Due(now)
+		if !ok || now.Sub(due) > s.maxDelay {
+			continue
+		}
+
+		err := s.store.MarkReminderFired(ctx, r.ID, due)
+		if errors.Is(err, storage.ErrAlreadyFired) || errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			s.log.Error("failed to mark reminder fired", "reminder_id", r.ID, "err", err)
+			continue
+		}
+
+		n := Notification{
+			UserID:     r.UserID,
+			ReminderID: r.ID,
+			Title:      "Time to train",
+			Body:       "Your training session is due.",
+			DueAt:      due,
+		}
+		if r.StartSession && s.starter != nil {
+			s.start(ctx, r, &n)
+		}
+		s.send(ctx, n)
+	}
+	return nil
+}
+
+// start starts the session for a reminder that fired, and tells the user
+// about it in n. The user is still reminded if it cannot be started.
+func (s *Scheduler) start(ctx context.Context, r domain.Reminder, n *Notification) {
+	session, err := s.starter.StartSession(ctx, r.UserID)
+	if err != nil {
+		s.log.Error("failed to start session for reminder", "reminder_id", r.ID, "user_id", r.UserID, "err", err)
+		return
+	}
+	n.SessionID = session.ID
+	n.Body = "Your training session has started."
+}
+
+// send delivers n, keeping it for the next check if that fails. s.mu must be
+// held.
+func (s *Scheduler) send(ctx context.Context, n Notification) {
+	if err := s.notifier.Notify(ctx, n); err != nil {
+		s.log.Error("failed to send reminder", "reminder_id", n.ReminderID, "user_id", n.UserID, "err", err)
+		s.retry[n.ReminderID] = n
+		return
+	}
+	delete(s.retry, n.ReminderID)
+}
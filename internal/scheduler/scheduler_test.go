@@ -0,0 +1,214 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/scheduler"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent []scheduler.Notification
+	err  error
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, notification scheduler.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, notification)
+	return n.err
+}
+
+func (n *recordingNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.sent)
+}
+
+// monday is 2026-03-02 00:00 UTC.
+var monday = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+func newStore(t *testing.T, reminders ...*domain.Reminder) storage.Repository {
+	t.Helper()
+	repo := storage.NewMemoryRepository()
+	for _, r := range reminders {
+		if err := repo.CreateReminder(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestSchedulerSendsEachOccurrenceOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t, &domain.Reminder{
+		ID: "daily", UserID: "alice", Time: "18:00", Timezone: "Europe/Stockholm", CreatedAt: monday.Add(-24 * time.Hour),
+	})
+	notifier := &recordingNotifier{}
+	s := scheduler.New(store, notifier)
+
+	// 18:00 in Stockholm is 17:00 UTC in March
+	if err := s.Check(ctx, monday.Add(16*time.Hour+59*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if n := notifier.count(); n != 0 {
+		t.Fatalf("sent %d before the reminder was due", n)
+	}
+
+	due := monday.Add(17 * time.Hour)
+	s.Check(ctx, due.Add(30*time.Second))
+	s.Check(ctx, due.Add(90*time.Second))
+	if n := notifier.count(); n != 1 {
+		t.Fatalf("sent %d notifications, want 1", n)
+	}
+	if got := notifier.sent[0]; got.UserID != "alice" || got.ReminderID != "daily" || !got.DueAt.Equal(due) {
+		t.Fatalf("notification = %+v, want alice's reminder due at %v", got, due)
+	}
+
+	// a restarted scheduler finds the occurrence already fired
+	scheduler.New(store, notifier).Check(ctx, due.Add(2*time.Minute))
+	if n := notifier.count(); n != 1 {
+		t.Fatalf("sent %d notifications after a restart, want 1", n)
+	}
+
+	s.Check(ctx, due.Add(24*time.Hour))
+	if n := notifier.count(); n != 2 {
+		t.Fatalf("sent %d notifications by the next day, want 2", n)
+	}
+}
+
+func TestSchedulerSkipsRemindersMissedTooLong(t *testing.T) {
+	store := newStore(t, &domain.Reminder{
+		ID: "weekly", UserID: "alice", Days: []time.Weekday{time.Monday}, Time: "07:00", Timezone: "UTC", CreatedAt: monday.Add(-time.Hour),
+	})
+	notifier := &recordingNotifier{}
+	s := scheduler.New(store, notifier, scheduler.WithMaxDelay(10*time.Minute))
+
+	s.Check(context.Background(), monday.Add(8*time.Hour))
+	if n := notifier.count(); n != 0 {
+		t.Fatalf("sent %d notifications an hour late, want none", n)
+	}
+	s.Check(context.Background(), monday.Add(24*time.Hour+7*time.Hour))
+	if n := notifier.count(); n != 0 {
+		t.Fatalf("sent %d notifications on Tuesday, want none", n)
+	}
+	s.Check(context.Background(), monday.Add(7*24*time.Hour+7*time.Hour+time.Minute))
+	if n := notifier.count(); n != 1 {
+		t.Fatalf("sent %d notifications the next Monday, want 1", n)
+	}
+}
+
+func TestSchedulerKeepsGoingAfterFailedDelivery(t *testing.T) {
+	store := newStore(t,
+		&domain.Reminder{ID: "a", UserID: "alice", Time: "07:00", Timezone: "UTC", CreatedAt: monday},
+		&domain.Reminder{ID: "b", UserID: "bob", Time: "07:00", Timezone: "UTC", CreatedAt: monday.Add(time.Second)},
+	)
+	notifier := &recordingNotifier{err: errors.New("unreachable")}
+	s := scheduler.New(store, notifier)
+
+	if err := s.Check(context.Background(), monday.Add(7*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if n := notifier.count(); n != 2 {
+		t.Fatalf("attempted %d notifications, want both", n)
+	}
+}
+
+func TestSchedulerRetriesFailedDelivery(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t,
+		&domain.Reminder{ID: "a", UserID: "alice", Time: "07:00", Timezone: "UTC", CreatedAt: monday},
+		&domain.Reminder{ID: "b", UserID: "bob", Time: "09:00", Timezone: "UTC", CreatedAt: monday},
+	)
+	notifier := &recordingNotifier{err: errors.New("unreachable")}
+	s := scheduler.New(store, notifier, scheduler.WithMaxDelay(10*time.Minute))
+
+	s.Check(ctx, monday.Add(7*time.Hour))
+	s.Check(ctx, monday.Add(7*time.Hour+time.Minute))
+	if n := notifier.count(); n != 2 {
+		t.Fatalf("attempted %d notifications, want the first and a retry", n)
+	}
+
+	notifier.err = nil
+	s.Check(ctx, monday.Add(7*time.Hour+2*time.Minute))
+	s.Check(ctx, monday.Add(7*time.Hour+3*time.Minute))
+	if n := notifier.count(); n != 3 {
+		t.Fatalf("attempted %d notifications, want one more until it was sent", n)
+	}
+
+	// bob's reminder fails until it is too late to send
+	notifier.err = errors.New("unreachable")
+	s.Check(ctx, monday.Add(9*time.Hour))
+	notifier.err = nil
+	s.Check(ctx, monday.Add(9*time.Hour+11*time.Minute))
+	if n := notifier.count(); n != 4 {
+		t.Fatalf("attempted %d notifications, want no retry past the max delay", n)
+	}
+}
+
+type recordingStarter struct {
+	mu      sync.Mutex
+	started []string
+	err     error
+}
+
+func (st *recordingStarter) StartSession(_ context.Context, userID string) (*domain.Session, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.err != nil {
+		return nil, st.err
+	}
+	st.started = append(st.started, userID)
+	return &domain.Session{ID: "session-" + userID, HouseholdID: userID}, nil
+}
+
+func TestSchedulerStartsSessions(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t,
+		&domain.Reminder{ID: "start", UserID: "alice", Time: "07:00", Timezone: "UTC", StartSession: true, CreatedAt: monday},
+		&domain.Reminder{ID: "remind", UserID: "bob", Time: "07:00", Timezone: "UTC", CreatedAt: monday.Add(time.Second)},
+	)
+	notifier := &recordingNotifier{}
+	starter := &recordingStarter{}
+	s := scheduler.New(store, notifier, scheduler.WithStarter(starter))
+
+	s.Check(ctx, monday.Add(7*time.Hour))
+	s.Check(ctx, monday.Add(7*time.Hour+time.Minute))
+	if len(starter.started) != 1 || starter.started[0] != "alice" {
+		t.Fatalf("started sessions for %v, want alice once", starter.started)
+	}
+	if n := notifier.count(); n != 2 {
+		t.Fatalf("sent %d notifications, want 2", n)
+	}
+	for _, n := range notifier.sent {
+		want := ""
+		if n.UserID == "alice" {
+			want = "session-alice"
+		}
+		if n.SessionID != want {
+			t.Fatalf("%s's notification has session %q, want %q", n.UserID, n.SessionID, want)
+		}
+	}
+}
+
+func TestSchedulerNotifiesWhenStartFails(t *testing.T) {
+	store := newStore(t, &domain.Reminder{
+		ID: "start", UserID: "alice", Time: "07:00", Timezone: "UTC", StartSession: true, CreatedAt: monday,
+	})
+	notifier := &recordingNotifier{}
+	s := scheduler.New(store, notifier, scheduler.WithStarter(&recordingStarter{err: errors.New("too many sessions")}))
+
+	s.Check(context.Background(), monday.Add(7*time.Hour))
+	if n := notifier.count(); n != 1 {
+		t.Fatalf("sent %d notifications, want 1", n)
+	}
+	if got := notifier.sent[0]; got.SessionID != "" || got.Body != "Your training session is due." {
+		t.Fatalf("notification = %+v, want a plain reminder", got)
+	}
+}
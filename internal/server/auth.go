@@ -1,9 +1,11 @@
-package main
+package server
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
+
+	"github.com/hperssn/hound/internal/logging"
 )
 
 type contextKey string
@@ -30,18 +32,23 @@ func ExtractUserMiddleware(next http.Handler) http.Handler {
 		//}
 
 		if userId == "" {
-			log.Printf("missing auth header from traefik")
+			slog.ErrorContext(r.Context(), "missing auth header from traefik")
 			http.Error(w, "Auth misconfig", http.StatusInternalServerError)
 			return
 		}
 
-		log.Printf("Authenticated request from user: %s", userId)
-
-		ctx := context.WithValue(r.Context(), UserIDKey, userId)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, withUser(r, userId))
 	})
 }
 
+// withUser stores the authenticated user on the request and tags its logs.
+func withUser(r *http.Request, userId string) *http.Request {
+	ctx := logging.With(r.Context(), slog.String(logging.UserIDKey, userId))
+	slog.DebugContext(ctx, "authenticated request")
+
+	return r.WithContext(context.WithValue(ctx, UserIDKey, userId))
+}
+
 func GetUserId(r *http.Request) string {
 	userId, ok := r.Context().Value(UserIDKey).(string)
 	if !ok {
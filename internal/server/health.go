@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+const readinessTimeout = 2 * time.Second
+
+const (
+	checkOK   = "ok"
+	checkFail = "fail"
+)
+
+type checkResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type sessionsCheck struct {
+	checkResult
+	runner.Load
+}
+
+type healthReport struct {
+	Status string         `json:"status"`
+	Checks map[string]any `json:"checks,omitempty"`
+}
+
+// liveness reports that the process is serving requests. It deliberately
+// checks nothing else: a failing database should take the pod out of the
+// load balancer, not get it restarted.
+func liveness(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, healthReport{Status: checkOK}, http.StatusOK)
+}
+
+// readiness reports whether the server can take traffic: the database must
+// answer a ping and the session manager must not be shutting down.
+func readiness(repo storage.Repository, m *runner.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := healthReport{Status: checkOK, Checks: make(map[string]any)}
+
+		database := pingDatabase(r.Context(), repo)
+		report.Checks["database"] = database
+
+		start := time.Now()
+		sessions := sessionsCheck{
+			checkResult: checkResult{Status: checkOK},
+			Load:        m.Load(),
+		}
+		if sessions.ShuttingDown {
+			sessions.Status = checkFail
+			sessions.Error = runner.ErrShuttingDown.Error()
+		}
+		sessions.DurationMs = time.Since(start).Milliseconds()
+		report.Checks["sessions"] = sessions
+
+		status := http.StatusOK
+		if database.Status != checkOK || sessions.Status != checkOK {
+			report.Status = checkFail
+			status = http.StatusServiceUnavailable
+		}
+		respondJSON(w, report, status)
+	}
+}
+
+func pingDatabase(ctx context.Context, repo storage.Repository) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := repo.Ping(ctx)
+	result := checkResult{Status: checkOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		slog.WarnContext(ctx, "readiness check failed", "check", "database", "err", err)
+		result.Status = checkFail
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+type readyReport struct {
+	Status string `json:"status"`
+	Checks map[string]struct {
+		Status         string `json:"status"`
+		Error          string `json:"error"`
+		ActiveSessions *int   `json:"activeSessions"`
+	} `json:"checks"`
+}
+
+func probe(t *testing.T, h http.HandlerFunc) (int, readyReport) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report readyReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return rec.Code, report
+}
+
+func TestReadiness(t *testing.T) {
+	newRepo := func(t *testing.T) storage.Repository {
+		repo, err := storage.NewSQLiteRepository(filepath.Join(t.TempDir(), "hound.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		code, report := probe(t, readiness(newRepo(t), runner.NewSessionManager()))
+		if code != http.StatusOK || report.Status != checkOK {
+			t.Fatalf("status = %d %q, want 200 ok", code, report.Status)
+		}
+		if s := report.Checks["sessions"]; s.ActiveSessions == nil || *s.ActiveSessions != 0 {
+			t.Fatalf("sessions check = %+v, want load reported", s)
+		}
+	})
+
+	t.Run("database down", func(t *testing.T) {
+		repo := newRepo(t)
+		repo.Close()
+
+		code, report := probe(t, readiness(repo, runner.NewSessionManager()))
+		if code != http.StatusServiceUnavailable || report.Checks["database"].Status != checkFail {
+			t.Fatalf("status = %d, checks = %+v; want 503 with failing database", code, report.Checks)
+		}
+		if report.Checks["sessions"].Status != checkOK {
+			t.Fatal("sessions check should still pass")
+		}
+	})
+
+	t.Run("shutting down", func(t *testing.T) {
+		manager := runner.NewSessionManager()
+		if err := manager.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		code, report := probe(t, readiness(newRepo(t), manager))
+		if code != http.StatusServiceUnavailable || report.Checks["sessions"].Status != checkFail {
+			t.Fatalf("status = %d, checks = %+v; want 503 with failing sessions", code, report.Checks)
+		}
+	})
+}
+
+func TestLiveness(t *testing.T) {
+	rec := httptest.NewRecorder()
+	liveness(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+}
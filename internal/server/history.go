@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/logging"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+// savedSession loads the completed session named in the URL. It writes a
+// 404 and returns false if the session does not exist or belongs to a
+// household the user is not a member of, and a 403 if their role does not
+// allow the operation. Deleted sessions are returned so they can be
+// restored.
+func savedSession(w http.ResponseWriter, r *http.Request, repo storage.Repository, allowed func(domain.Role) bool) (*storage.SessionRecord, bool) {
+	id := chi.URLParam(r, "id")
+	logging.With(r.Context(), slog.String(logging.SessionIDKey, id))
+
+	record, err := repo.GetSession(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		respondError(w, "session not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get session", "err", err)
+		respondError(w, "failed to load session", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	role, err := memberRole(r.Context(), repo, record.HouseholdID, GetUserId(r))
+	if errors.Is(err, storage.ErrNotFound) {
+		respondError(w, "session not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to resolve household role", "err", err)
+		respondError(w, "failed to load session", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !allowed(role) {
+		respondError(w, "forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return record, true
+}
+
+func updateHistory(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var edit storage.SessionEdit
+		if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+			respondError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if edit.Success != nil && !validRating(*edit.Success) {
+			respondError(w, "invalid success level", http.StatusBadRequest)
+			return
+		}
+		if edit.Success == nil && edit.Comment == nil {
+			respondError(w, "nothing to update", http.StatusBadRequest)
+			return
+		}
+
+		record, ok := savedSession(w, r, repo, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+
+		updated, err := repo.UpdateSession(r.Context(), record.ID, edit, GetUserId(r), time.Now())
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(w, "session not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to update session", "err", err)
+			respondError(w, "failed to update session", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, updated, http.StatusOK)
+	}
+}
+
+func deleteHistory(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, ok := savedSession(w, r, repo, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+
+		err := repo.DeleteSession(r.Context(), record.ID, GetUserId(r), time.Now())
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(w, "session not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete session", "err", err)
+			respondError(w, "failed to delete session", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// restoreHistory undoes deleteHistory.
+func restoreHistory(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, ok := savedSession(w, r, repo, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+
+		err := repo.RestoreSession(r.Context(), record.ID, GetUserId(r), time.Now())
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(w, "session is not deleted", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to restore session", "err", err)
+			respondError(w, "failed to restore session", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func listHistoryChanges(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		record, ok := savedSession(w, r, repo, domain.Role.CanView)
+		if !ok {
+			return
+		}
+
+		changes, err := repo.GetSessionChanges(r.Context(), record.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get session changes", "err", err)
+			respondError(w, "failed to retrieve session changes", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, changes, http.StatusOK)
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+func TestHistoryEditDeleteAndRestore(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	if err := s.repo.CreateHousehold(ctx, domain.PersonalHousehold("alice"), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repo.SetMemberRole(ctx, "alice", "sam", domain.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	err := s.repo.SaveSession(ctx, &storage.SessionRecord{
+		ID:          "s1",
+		UserID:      "alice",
+		HouseholdID: "alice",
+		TargetSec:   100,
+		Success:     storage.SuccessLevelFail,
+		StartedAt:   time.Now().Add(-time.Hour),
+		CompletedAt: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"outsider cannot edit", "bob", http.MethodPatch, "/history/s1", `{"success":"great"}`, http.StatusNotFound},
+		{"viewer cannot edit", "sam", http.MethodPatch, "/history/s1", `{"success":"great"}`, http.StatusForbidden},
+		{"viewer cannot delete", "sam", http.MethodDelete, "/history/s1", "", http.StatusForbidden},
+		{"unknown session", "alice", http.MethodPatch, "/history/missing", `{"success":"great"}`, http.StatusNotFound},
+		{"invalid success level", "alice", http.MethodPatch, "/history/s1", `{"success":"superb"}`, http.StatusBadRequest},
+		{"abandoned is set by the server", "alice", http.MethodPatch, "/history/s1", `{"success":"abandoned"}`, http.StatusBadRequest},
+		{"empty edit", "alice", http.MethodPatch, "/history/s1", `{}`, http.StatusBadRequest},
+		{"restoring a live session", "alice", http.MethodPost, "/history/s1/restore", "", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := s.do(tt.user, tt.method, tt.path, tt.body, nil); code != tt.want {
+				t.Fatalf("status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+
+	var updated storage.SessionRecord
+	if code := s.do("alice", http.MethodPatch, "/history/s1", `{"success":"great","comment":"mis-clicked"}`, &updated); code != http.StatusOK {
+		t.Fatalf("edit status = %d", code)
+	}
+	if updated.Success != storage.SuccessLevelGreat || updated.Comment != "mis-clicked" {
+		t.Fatalf("updated = %+v", updated)
+	}
+
+	var next map[string]int
+	s.do("alice", http.MethodGet, "/next-target", "", &next)
+	if next["nextTarget"] != 120 {
+		t.Fatalf("next target after correcting to great = %d, want 120", next["nextTarget"])
+	}
+
+	if code := s.do("alice", http.MethodDelete, "/history/s1", "", nil); code != http.StatusNoContent {
+		t.Fatalf("delete status = %d", code)
+	}
+	if code := s.do("alice", http.MethodDelete, "/history/s1", "", nil); code != http.StatusNotFound {
+		t.Fatalf("second delete status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := s.do("alice", http.MethodPatch, "/history/s1", `{"comment":"x"}`, nil); code != http.StatusNotFound {
+		t.Fatalf("editing a deleted session status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	var history []storage.SessionRecord
+	s.do("alice", http.MethodGet, "/history", "", &history)
+	if len(history) != 0 {
+		t.Fatalf("history after delete = %+v, want empty", history)
+	}
+	var stats storage.SessionStats
+	s.do("alice", http.MethodGet, "/stats", "", &stats)
+	if stats.TotalSessions != 0 {
+		t.Fatalf("stats after delete = %+v, want empty", stats)
+	}
+
+	if code := s.do("alice", http.MethodPost, "/history/s1/restore", "", nil); code != http.StatusNoContent {
+		t.Fatalf("restore status = %d", code)
+	}
+	s.do("alice", http.MethodGet, "/history", "", &history)
+	if len(history) != 1 || history[0].Success != storage.SuccessLevelGreat {
+		t.Fatalf("history after restore = %+v", history)
+	}
+
+	var changes []storage.SessionChange
+	if code := s.do("sam", http.MethodGet, "/history/s1/changes", "", &changes); code != http.StatusOK {
+		t.Fatalf("changes status = %d", code)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("changes = %+v, want edit, delete and restore", changes)
+	}
+	edit := changes[0]
+	if edit.Action != storage.SessionEdited || edit.UserID != "alice" ||
+		*edit.Before.Success != storage.SuccessLevelFail || *edit.After.Success != storage.SuccessLevelGreat ||
+		*edit.Before.Comment != "" || *edit.After.Comment != "mis-clicked" {
+		t.Fatalf("edit change = %+v", edit)
+	}
+	if changes[1].Action != storage.SessionDeleted || changes[2].Action != storage.SessionRestored {
+		t.Fatalf("changes = %+v", changes)
+	}
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+// memberAuthorizer adapts the repository to runner.Authorizer.
+type memberAuthorizer struct {
+	repo storage.Repository
+}
+
+// NewAuthorizer returns a runner.Authorizer that grants session access by
+// household membership.
+func NewAuthorizer(repo storage.Repository) runner.Authorizer {
+	return memberAuthorizer{repo}
+}
+
+func (a memberAuthorizer) MemberRole(ctx context.Context, householdID, userID string) (domain.Role, error) {
+	role, err := a.repo.GetMemberRole(ctx, householdID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", nil
+	}
+	return role, err
+}
+
+// memberRole returns the user's role in the household. The personal
+// household is created on first use.
+func memberRole(ctx context.Context, repo storage.Repository, householdID, userID string) (domain.Role, error) {
+	role, err := repo.GetMemberRole(ctx, householdID, userID)
+	if !errors.Is(err, storage.ErrNotFound) || householdID != userID {
+		return role, err
+	}
+
+	if err := repo.CreateHousehold(ctx, domain.PersonalHousehold(userID), userID); err != nil {
+		return "", err
+	}
+	return domain.RoleOwner, nil
+}
+
+// requireHousehold resolves the household a request acts on, taken from the
+// "household" query parameter and defaulting to the user's personal
+// household. It writes an error response and returns false if the user is
+// not a member or their role does not allow the operation.
+func requireHousehold(w http.ResponseWriter, r *http.Request, repo storage.Repository, allowed func(domain.Role) bool) (string, bool) {
+	userID := GetUserId(r)
+	if userID == "" {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+
+	householdID := r.URL.Query().Get("household")
+	if householdID == "" {
+		householdID = userID
+	}
+
+	return householdID, checkRole(w, r, repo, householdID, userID, allowed)
+}
+
+func checkRole(w http.ResponseWriter, r *http.Request, repo storage.Repository, householdID, userID string, allowed func(domain.Role) bool) bool {
+	role, err := memberRole(r.Context(), repo, householdID, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		respondError(w, "household not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to resolve household role", "err", err)
+		respondError(w, "failed to resolve household", http.StatusInternalServerError)
+		return false
+	}
+	if !allowed(role) {
+		respondError(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// checkNotLastOwner writes a 409 and returns false if demoting or removing
+// userID would leave the household without an owner.
+func checkNotLastOwner(w http.ResponseWriter, r *http.Request, repo storage.Repository, householdID, userID string) bool {
+	if householdID == userID {
+		respondError(w, "cannot remove the owner of a personal household", http.StatusConflict)
+		return false
+	}
+
+	members, err := repo.GetMembers(r.Context(), householdID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get members", "err", err)
+		respondError(w, "failed to retrieve members", http.StatusInternalServerError)
+		return false
+	}
+
+	owners := 0
+	isOwner := false
+	for _, m := range members {
+		if m.Role == domain.RoleOwner {
+			owners++
+			isOwner = isOwner || m.UserID == userID
+		}
+	}
+
+	if isOwner && owners == 1 {
+		respondError(w, "household must keep at least one owner", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+func listHouseholds(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := GetUserId(r)
+		if userID == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if _, err := memberRole(r.Context(), repo, userID, userID); err != nil {
+			slog.ErrorContext(r.Context(), "failed to create personal household", "err", err)
+			respondError(w, "failed to retrieve households", http.StatusInternalServerError)
+			return
+		}
+
+		households, err := repo.GetHouseholdsForUser(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get households", "err", err)
+			respondError(w, "failed to retrieve households", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, households, http.StatusOK)
+	}
+}
+
+func createHousehold(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := GetUserId(r)
+		if userID == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			respondError(w, "name is required", http.StatusBadRequest)
+			return
+		}
+
+		household := domain.NewHousehold(name, userID)
+		if err := repo.CreateHousehold(r.Context(), household, userID); err != nil {
+			slog.ErrorContext(r.Context(), "failed to create household", "err", err)
+			respondError(w, "failed to create household", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, domain.Membership{Household: *household, Role: domain.RoleOwner}, http.StatusCreated)
+	}
+}
+
+func listMembers(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdID := chi.URLParam(r, "hid")
+		if !checkRole(w, r, repo, householdID, GetUserId(r), domain.Role.CanView) {
+			return
+		}
+
+		members, err := repo.GetMembers(r.Context(), householdID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get members", "err", err)
+			respondError(w, "failed to retrieve members", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, members, http.StatusOK)
+	}
+}
+
+func updateMember(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdID := chi.URLParam(r, "hid")
+		memberID := chi.URLParam(r, "userId")
+
+		var req struct {
+			Role domain.Role `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Role.Valid() {
+			respondError(w, "invalid role", http.StatusBadRequest)
+			return
+		}
+
+		if !checkRole(w, r, repo, householdID, GetUserId(r), domain.Role.CanManage) {
+			return
+		}
+
+		if _, err := repo.GetMemberRole(r.Context(), householdID, memberID); err != nil {
+			respondError(w, "member not found", http.StatusNotFound)
+			return
+		}
+		if req.Role != domain.RoleOwner {
+			if !checkNotLastOwner(w, r, repo, householdID, memberID) {
+				return
+			}
+		}
+
+		if err := repo.SetMemberRole(r.Context(), householdID, memberID, req.Role); err != nil {
+			slog.ErrorContext(r.Context(), "failed to update member", "err", err)
+			respondError(w, "failed to update member", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func removeMember(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := GetUserId(r)
+		householdID := chi.URLParam(r, "hid")
+		memberID := chi.URLParam(r, "userId")
+
+		// members may always leave, only owners may remove others
+		allowed := domain.Role.CanManage
+		if memberID == userID {
+			allowed = domain.Role.CanView
+		}
+		if !checkRole(w, r, repo, householdID, userID, allowed) {
+			return
+		}
+
+		if !checkNotLastOwner(w, r, repo, householdID, memberID) {
+			return
+		}
+
+		if err := repo.RemoveMember(r.Context(), householdID, memberID); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				respondError(w, "member not found", http.StatusNotFound)
+				return
+			}
+			slog.ErrorContext(r.Context(), "failed to remove member", "err", err)
+			respondError(w, "failed to remove member", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func createInvitation(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := GetUserId(r)
+		householdID := chi.URLParam(r, "hid")
+
+		var req struct {
+			Role domain.Role `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Role.Valid() {
+			respondError(w, "invalid role", http.StatusBadRequest)
+			return
+		}
+
+		if !checkRole(w, r, repo, householdID, userID, domain.Role.CanManage) {
+			return
+		}
+
+		token, err := randomString()
+		if err != nil {
+			respondError(w, "failed to create invitation", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		inv := &domain.Invitation{
+			Token:       token,
+			HouseholdID: householdID,
+			Role:        req.Role,
+			CreatedBy:   userID,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(invitationTTL),
+		}
+		if err := repo.CreateInvitation(r.Context(), inv); err != nil {
+			slog.ErrorContext(r.Context(), "failed to create invitation", "err", err)
+			respondError(w, "failed to create invitation", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, inv, http.StatusCreated)
+	}
+}
+
+func acceptInvitation(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := GetUserId(r)
+		if userID == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		token := chi.URLParam(r, "token")
+		err := repo.AcceptInvitation(r.Context(), token, userID)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			respondError(w, "invitation not found", http.StatusNotFound)
+			return
+		case errors.Is(err, storage.ErrInvitationUnusable):
+			respondError(w, err.Error(), http.StatusGone)
+			return
+		case err != nil:
+			slog.ErrorContext(r.Context(), "failed to accept invitation", "err", err)
+			respondError(w, "failed to accept invitation", http.StatusInternalServerError)
+			return
+		}
+
+		inv, err := repo.GetInvitation(r.Context(), token)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to load invitation", "err", err)
+			respondError(w, "failed to accept invitation", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, map[string]string{"householdId": inv.HouseholdID}, http.StatusOK)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hperssn/hound/internal/domain"
+)
+
+func TestHouseholdInvitationGrantsRole(t *testing.T) {
+	s := newTestServer(t)
+
+	var household domain.Membership
+	if code := s.do("alice", http.MethodPost, "/households", `{"name":"Rex"}`, &household); code != http.StatusCreated {
+		t.Fatalf("create household status = %d", code)
+	}
+
+	var inv domain.Invitation
+	path := "/households/" + household.ID + "/invitations"
+	if code := s.do("alice", http.MethodPost, path, `{"role":"viewer"}`, &inv); code != http.StatusCreated {
+		t.Fatalf("create invitation status = %d", code)
+	}
+
+	if code := s.do("sam", http.MethodPost, "/invitations/"+inv.Token+"/accept", "", nil); code != http.StatusOK {
+		t.Fatalf("accept status = %d", code)
+	}
+	if code := s.do("bob", http.MethodPost, "/invitations/"+inv.Token+"/accept", "", nil); code != http.StatusGone {
+		t.Fatalf("second accept status = %d, want %d", code, http.StatusGone)
+	}
+
+	var session domain.Session
+	if code := s.do("alice", http.MethodPost, "/sessions?household="+household.ID, `{"targetSec":60}`, &session); code != http.StatusCreated {
+		t.Fatalf("start session status = %d", code)
+	}
+	if session.HouseholdID != household.ID {
+		t.Fatalf("session household = %q, want %q", session.HouseholdID, household.ID)
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		want   int
+	}{
+		{"viewer can watch", "sam", http.MethodGet, "/sessions/" + session.ID, http.StatusOK},
+		{"viewer cannot train", "sam", http.MethodPost, "/sessions/" + session.ID + "/steps/0/start", http.StatusForbidden},
+		{"viewer cannot start sessions", "sam", http.MethodPost, "/sessions?household=" + household.ID, http.StatusForbidden},
+		{"viewer sees history", "sam", http.MethodGet, "/history?household=" + household.ID, http.StatusOK},
+		{"viewer cannot invite", "sam", http.MethodPost, path, http.StatusForbidden},
+		{"outsider cannot see session", "bob", http.MethodGet, "/sessions/" + session.ID, http.StatusNotFound},
+		{"outsider cannot see history", "bob", http.MethodGet, "/history?household=" + household.ID, http.StatusNotFound},
+		{"owner can train", "alice", http.MethodPost, "/sessions/" + session.ID + "/steps/0/start", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := ""
+			if tt.method == http.MethodPost {
+				body = `{"targetSec":60,"role":"viewer"}`
+			}
+			if code := s.do(tt.user, tt.method, tt.path, body, nil); code != tt.want {
+				t.Fatalf("status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+
+	if code := s.do("alice", http.MethodPut, "/households/"+household.ID+"/members/sam", `{"role":"trainer"}`, nil); code != http.StatusNoContent {
+		t.Fatalf("promote status = %d", code)
+	}
+	if code := s.do("sam", http.MethodPost, "/sessions/"+session.ID+"/steps/0/start", "", nil); code == http.StatusForbidden {
+		t.Fatal("trainer should be allowed to control steps")
+	}
+}
+
+func TestHouseholdKeepsAnOwner(t *testing.T) {
+	s := newTestServer(t)
+
+	var household domain.Membership
+	s.do("alice", http.MethodPost, "/households", `{"name":"Rex"}`, &household)
+
+	if code := s.do("alice", http.MethodDelete, "/households/"+household.ID+"/members/alice", "", nil); code != http.StatusConflict {
+		t.Fatalf("removing last owner status = %d, want %d", code, http.StatusConflict)
+	}
+	if code := s.do("alice", http.MethodDelete, "/households/alice/members/alice", "", nil); code != http.StatusConflict {
+		t.Fatalf("leaving personal household status = %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestPersonalHouseholdCreatedOnFirstUse(t *testing.T) {
+	s := newTestServer(t)
+
+	var households []domain.Membership
+	if code := s.do("alice", http.MethodGet, "/households", "", &households); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+
+	if len(households) != 1 || households[0].ID != "alice" || households[0].Role != domain.RoleOwner {
+		t.Fatalf("households = %+v, want personal household owned by alice", households)
+	}
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/oauth2"
+
+	"github.com/hperssn/hound/internal/config"
+)
+
+const (
+	sessionCookieName = "hound_session"
+	stateCookieName   = "hound_oidc_state"
+
+	sessionTTL = 7 * 24 * time.Hour
+	stateTTL   = 10 * time.Minute
+)
+
+var ErrNotLoggedIn = errors.New("not logged in")
+
+type OIDCAuth struct {
+	oauth2        oauth2.Config
+	verifier      *oidc.IDTokenVerifier
+	signer        *signer
+	userClaim     string
+	cookieSecure  bool
+	endSessionURL string
+}
+
+type stateCookie struct {
+	State   string `json:"s"`
+	Nonce   string `json:"n"`
+	Expires int64  `json:"e"`
+}
+
+type sessionCookie struct {
+	UserID  string `json:"u"`
+	Expires int64  `json:"e"`
+}
+
+func NewOIDCAuth(ctx context.Context, cfg config.OIDCConfig) (*OIDCAuth, error) {
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc: client id and redirect url are required")
+	}
+	if len(cfg.SessionSecret) < 32 {
+		return nil, errors.New("oidc: session secret must be at least 32 characters")
+	}
+
+	claim := cfg.UserClaim
+	if claim == "" {
+		claim = "sub"
+	}
+	if claim != "sub" && claim != "email" {
+		return nil, fmt.Errorf("oidc: unsupported user claim %q", claim)
+	}
+
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed: %w", err)
+	}
+
+	var meta struct {
+		EndSessionURL string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&meta); err != nil {
+		return nil, fmt.Errorf("oidc: invalid provider metadata: %w", err)
+	}
+
+	scopes := []string{oidc.ScopeOpenID}
+	if claim == "email" {
+		scopes = append(scopes, "email")
+	}
+
+	return &OIDCAuth{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier:      provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		signer:        newSigner([]byte(cfg.SessionSecret), purposeSession),
+		userClaim:     claim,
+		cookieSecure:  cfg.CookieSecure,
+		endSessionURL: meta.EndSessionURL,
+	}, nil
+}
+
+func (a *OIDCAuth) Routes(r chi.Router) {
+	r.Get("/auth/login", a.login)
+	r.Get("/auth/callback", a.callback)
+	r.Get("/auth/logout", a.logout)
+	r.Post("/auth/logout", a.logout)
+}
+
+// Middleware resolves the user from the signed session cookie.
+func (a *OIDCAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId, err := a.userFromCookie(r)
+		if err != nil {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, withUser(r, userId))
+	})
+}
+
+func (a *OIDCAuth) login(w http.ResponseWriter, r *http.Request) {
+	state, err := randomString()
+	if err != nil {
+		respondError(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomString()
+	if err != nil {
+		respondError(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	expires := time.Now().Add(stateTTL)
+	a.setCookie(w, stateCookieName, stateCookie{
+		State:   state,
+		Nonce:   nonce,
+		Expires: expires.Unix(),
+	}, expires)
+
+	http.Redirect(w, r, a.oauth2.AuthCodeURL(state, oidc.Nonce(nonce)), http.StatusFound)
+}
+
+func (a *OIDCAuth) callback(w http.ResponseWriter, r *http.Request) {
+	var st stateCookie
+	if err := a.readCookie(r, stateCookieName, &st); err != nil {
+		respondError(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	a.clearCookie(w, stateCookieName)
+
+	if st.State == "" || time.Now().Unix() > st.Expires || r.URL.Query().Get("state") != st.State {
+		respondError(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	if msg := r.URL.Query().Get("error"); msg != "" {
+		slog.WarnContext(r.Context(), "oidc provider returned error", "error", msg)
+		respondError(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := a.oauth2.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "oidc code exchange failed", "err", err)
+		respondError(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		respondError(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	idToken, err := a.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		slog.WarnContext(r.Context(), "oidc id token verification failed", "err", err)
+		respondError(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	if idToken.Nonce != st.Nonce {
+		respondError(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	userId, err := a.userIDFromToken(idToken)
+	if err != nil {
+		slog.WarnContext(r.Context(), "oidc id token rejected", "err", err)
+		respondError(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	expires := time.Now().Add(sessionTTL)
+	a.setCookie(w, sessionCookieName, sessionCookie{
+		UserID:  userId,
+		Expires: expires.Unix(),
+	}, expires)
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (a *OIDCAuth) logout(w http.ResponseWriter, r *http.Request) {
+	a.clearCookie(w, sessionCookieName)
+
+	target := "/"
+	if a.endSessionURL != "" {
+		target = a.endSessionURL
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (a *OIDCAuth) userIDFromToken(idToken *oidc.IDToken) (string, error) {
+	if a.userClaim == "sub" {
+		return idToken.Subject, nil
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return "", err
+	}
+	if claims.Email == "" {
+		return "", errors.New("email claim missing")
+	}
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return "", errors.New("email not verified")
+	}
+	return claims.Email, nil
+}
+
+func (a *OIDCAuth) userFromCookie(r *http.Request) (string, error) {
+	var sc sessionCookie
+	if err := a.readCookie(r, sessionCookieName, &sc); err != nil {
+		return "", err
+	}
+	if sc.UserID == "" || time.Now().Unix() > sc.Expires {
+		return "", ErrNotLoggedIn
+	}
+	return sc.UserID, nil
+}
+
+func (a *OIDCAuth) setCookie(w http.ResponseWriter, name string, value any, expires time.Time) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		slog.Error("failed to encode cookie", "cookie", name, "err", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    a.signer.Sign(payload),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   a.cookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (a *OIDCAuth) readCookie(r *http.Request, name string, dst any) error {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ErrNotLoggedIn
+	}
+
+	payload, err := a.signer.Verify(c.Value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, dst)
+}
+
+func (a *OIDCAuth) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.cookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hperssn/hound/internal/config"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// mockIssuer is a minimal OIDC provider serving discovery, JWKS and a
+// token endpoint that hands out ID tokens for pre-registered codes.
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]map[string]any
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockIssuer{key: key, codes: make(map[string]map[string]any)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/keys",
+			"end_session_endpoint":                  m.URL + "/logout",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		enc := base64.RawURLEncoding
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   enc.EncodeToString(key.N.Bytes()),
+				"e":   enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.mu.Lock()
+		claims, ok := m.codes[r.Form.Get("code")]
+		m.mu.Unlock()
+		if !ok {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     m.sign(t, claims),
+		})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockIssuer) registerCode(code string, claims map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[code] = claims
+}
+
+func (m *mockIssuer) sign(t *testing.T, claims map[string]any) string {
+	enc := base64.RawURLEncoding
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "test"})
+	body, _ := json.Marshal(claims)
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(body)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + enc.EncodeToString(sig)
+}
+
+func newTestOIDC(t *testing.T, issuer *mockIssuer, claim string) (*OIDCAuth, http.Handler) {
+	t.Helper()
+
+	auth, err := NewOIDCAuth(context.Background(), config.OIDCConfig{
+		IssuerURL:     issuer.URL,
+		ClientID:      "hound",
+		ClientSecret:  "secret",
+		RedirectURL:   "http://hound.test/auth/callback",
+		UserClaim:     claim,
+		SessionSecret: testSecret,
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCAuth: %v", err)
+	}
+
+	r := chi.NewRouter()
+	auth.Routes(r)
+	r.With(auth.Middleware).Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetUserId(r)))
+	})
+	return auth, r
+}
+
+// login runs the authorization-code flow and returns the session cookie.
+func login(t *testing.T, issuer *mockIssuer, h http.Handler, claims map[string]any) (*http.Cookie, int) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want %d", rec.Code, http.StatusFound)
+	}
+
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(loc.String(), issuer.URL+"/authorize") {
+		t.Fatalf("login redirected to %s", loc)
+	}
+
+	claims["iss"] = issuer.URL
+	claims["aud"] = "hound"
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	claims["iat"] = time.Now().Unix()
+	claims["nonce"] = loc.Query().Get("nonce")
+	issuer.registerCode("code-1", claims)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?code=code-1&state="+loc.Query().Get("state"), nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookieName && c.Value != "" {
+			return c, rec.Code
+		}
+	}
+	return nil, rec.Code
+}
+
+func whoami(h http.Handler, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOIDCLoginMapsSubject(t *testing.T) {
+	issuer := newMockIssuer(t)
+	_, h := newTestOIDC(t, issuer, "sub")
+
+	cookie, status := login(t, issuer, h, map[string]any{"sub": "user-123"})
+	if status != http.StatusFound || cookie == nil {
+		t.Fatalf("callback status = %d, cookie = %v", status, cookie)
+	}
+
+	rec := whoami(h, cookie)
+	if rec.Code != http.StatusOK || rec.Body.String() != "user-123" {
+		t.Fatalf("whoami = %d %q, want 200 user-123", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOIDCLoginMapsEmail(t *testing.T) {
+	issuer := newMockIssuer(t)
+	_, h := newTestOIDC(t, issuer, "email")
+
+	cookie, _ := login(t, issuer, h, map[string]any{
+		"sub":            "user-123",
+		"email":          "owner@example.com",
+		"email_verified": true,
+	})
+	if cookie == nil {
+		t.Fatal("expected session cookie")
+	}
+
+	if got := whoami(h, cookie).Body.String(); got != "owner@example.com" {
+		t.Fatalf("whoami = %q, want owner@example.com", got)
+	}
+}
+
+func TestOIDCRejectsUnverifiedEmail(t *testing.T) {
+	issuer := newMockIssuer(t)
+	_, h := newTestOIDC(t, issuer, "email")
+
+	cookie, status := login(t, issuer, h, map[string]any{
+		"sub":            "user-123",
+		"email":          "owner@example.com",
+		"email_verified": false,
+	})
+	if cookie != nil || status != http.StatusUnauthorized {
+		t.Fatalf("callback status = %d, cookie = %v; want 401 and no cookie", status, cookie)
+	}
+}
+
+func TestOIDCCallbackRejectsBadState(t *testing.T) {
+	issuer := newMockIssuer(t)
+	_, h := newTestOIDC(t, issuer, "sub")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/callback?code=x&state=forged", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOIDCMiddlewareRejectsTamperedCookie(t *testing.T) {
+	issuer := newMockIssuer(t)
+	_, h := newTestOIDC(t, issuer, "sub")
+
+	if rec := whoami(h, nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no cookie: status = %d, want 401", rec.Code)
+	}
+
+	forged := newSigner([]byte("another-secret-another-secret-00"), purposeSession).Sign([]byte(`{"u":"admin","e":9999999999}`))
+	rec := whoami(h, &http.Cookie{Name: sessionCookieName, Value: forged})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("forged cookie: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestOIDCLogoutClearsCookie(t *testing.T) {
+	issuer := newMockIssuer(t)
+	_, h := newTestOIDC(t, issuer, "sub")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/logout", nil))
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	if loc := rec.Header().Get("Location"); loc != issuer.URL+"/logout" {
+		t.Fatalf("logout redirected to %q", loc)
+	}
+
+	cleared := false
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookieName && c.MaxAge < 0 {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Fatal("session cookie was not cleared")
+	}
+}
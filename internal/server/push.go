@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+// getPushKey returns the applicationServerKey for subscribing to Web Push,
+// or 404 when the server does not send push messages.
+func getPushKey(publicKey string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if publicKey == "" {
+			respondError(w, "web push is not configured", http.StatusNotFound)
+			return
+		}
+		respondJSON(w, map[string]string{"publicKey": publicKey}, http.StatusOK)
+	}
+}
+
+// subscribePush registers the browser's push subscription, as returned by
+// PushSubscription.toJSON(), for the user. Subscribing again updates it.
+func subscribePush(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var sub domain.PushSubscription
+		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+			respondError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := sub.Validate(); err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sub.UserID = userId
+		sub.CreatedAt = time.Now()
+
+		if err := repo.SavePushSubscription(r.Context(), &sub); err != nil {
+			slog.ErrorContext(r.Context(), "failed to save push subscription", "err", err)
+			respondError(w, "failed to save push subscription", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, sub, http.StatusCreated)
+	}
+}
+
+func unsubscribePush(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			Endpoint string `json:"endpoint"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Endpoint == "" {
+			respondError(w, "endpoint is required", http.StatusBadRequest)
+			return
+		}
+
+		err := repo.DeletePushSubscription(r.Context(), userId, req.Endpoint)
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(w, "push subscription not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete push subscription", "err", err)
+			respondError(w, "failed to delete push subscription", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+const testPushKey = "BCUoW4-x2gYXlvM6Ca3dDpsyMSRt-iiZR3yUAOfB9kBZ"
+
+func TestServerPushSubscriptions(t *testing.T) {
+	s := newTestServer(t)
+
+	var key struct {
+		PublicKey string `json:"publicKey"`
+	}
+	if code := s.do("alice", http.MethodGet, "/push/key", "", &key); code != http.StatusOK || key.PublicKey != testPushKey {
+		t.Fatalf("push key status = %d, key = %q", code, key.PublicKey)
+	}
+
+	p256dh := base64.RawURLEncoding.EncodeToString(append([]byte{4}, make([]byte, 64)...))
+	auth := base64.URLEncoding.EncodeToString(make([]byte, 16)) // padded, as some browsers send it
+	sub := `{"endpoint":"https://push.example/abc","keys":{"p256dh":"` + p256dh + `","auth":"` + auth + `"}}`
+
+	for _, body := range []string{
+		`{"endpoint":"ftp://push.example/abc","keys":{"p256dh":"` + p256dh + `","auth":"` + auth + `"}}`,
+		`{"endpoint":"http://169.254.169.254/latest","keys":{"p256dh":"` + p256dh + `","auth":"` + auth + `"}}`,
+		`{"endpoint":"https://push.example/abc","keys":{"p256dh":"BAAA","auth":"` + auth + `"}}`,
+		`{"endpoint":"https://push.example/abc","keys":{"p256dh":"` + p256dh + `"}}`,
+	} {
+		if code := s.do("alice", http.MethodPost, "/push/subscriptions", body, nil); code != http.StatusBadRequest {
+			t.Fatalf("subscribe %s status = %d, want %d", body, code, http.StatusBadRequest)
+		}
+	}
+
+	if code := s.do("alice", http.MethodPost, "/push/subscriptions", sub, nil); code != http.StatusCreated {
+		t.Fatalf("subscribe status = %d, want %d", code, http.StatusCreated)
+	}
+	subs, _ := s.repo.GetPushSubscriptions(context.Background(), "alice")
+	if len(subs) != 1 || subs[0].Endpoint != "https://push.example/abc" || subs[0].UserID != "alice" {
+		t.Fatalf("alice's subscriptions = %+v", subs)
+	}
+
+	endpoint := `{"endpoint":"https://push.example/abc"}`
+	if code := s.do("bob", http.MethodDelete, "/push/subscriptions", endpoint, nil); code != http.StatusNotFound {
+		t.Fatalf("unsubscribe another user's device status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := s.do("alice", http.MethodDelete, "/push/subscriptions", endpoint, nil); code != http.StatusNoContent {
+		t.Fatalf("unsubscribe status = %d, want %d", code, http.StatusNoContent)
+	}
+	if subs, _ := s.repo.GetPushSubscriptions(context.Background(), "alice"); len(subs) != 0 {
+		t.Fatalf("alice's subscriptions after unsubscribing = %+v", subs)
+	}
+}
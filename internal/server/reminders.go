@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+// reminderResponse adds when a reminder fires next.
+type reminderResponse struct {
+	domain.Reminder
+	NextAt *time.Time `json:"nextAt,omitempty"`
+}
+
+func newReminderResponse(r domain.Reminder, now time.Time) reminderResponse {
+	resp := reminderResponse{Reminder: r}
+	if next, ok := r.Next(now); ok {
+		resp.NextAt = &next
+	}
+	return resp
+}
+
+func listReminders(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		reminders, err := repo.GetReminders(r.Context(), userId)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list reminders", "err", err)
+			respondError(w, "failed to list reminders", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		resp := make([]reminderResponse, 0, len(reminders))
+		for _, reminder := range reminders {
+			resp = append(resp, newReminderResponse(reminder, now))
+		}
+		respondJSON(w, resp, http.StatusOK)
+	}
+}
+
+// createReminder sets up a reminder for the user on the given weekdays
+// (0 is Sunday, none means every day) at a local time of day.
+func createReminder(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			Days     []time.Weekday `json:"days"`
+			Time     string         `json:"time"`
+			Timezone string         `json:"timezone"`
+			// StartSession starts a session when the reminder fires.
+			StartSession bool `json:"startSession"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		reminder, err := domain.NewReminder(userId, req.Days, req.Time, req.Timezone)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reminder.StartSession = req.StartSession
+
+		if err := repo.CreateReminder(r.Context(), reminder); err != nil {
+			slog.ErrorContext(r.Context(), "failed to create reminder", "err", err)
+			respondError(w, "failed to create reminder", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, newReminderResponse(*reminder, time.Now()), http.StatusCreated)
+	}
+}
+
+func deleteReminder(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		err := repo.DeleteReminder(r.Context(), chi.URLParam(r, "reminderId"), userId)
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(w, "reminder not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete reminder", "err", err)
+			respondError(w, "failed to delete reminder", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
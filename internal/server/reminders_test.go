@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/config"
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/scheduler"
+)
+
+func TestServerReminders(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, body := range []string{
+		`{"time":"7am","timezone":"UTC"}`,
+		`{"time":"07:00","timezone":"Nowhere/Special"}`,
+		`{"days":[9],"time":"07:00","timezone":"UTC"}`,
+	} {
+		if code := s.do("alice", http.MethodPost, "/reminders", body, nil); code != http.StatusBadRequest {
+			t.Fatalf("create %s status = %d, want %d", body, code, http.StatusBadRequest)
+		}
+	}
+
+	var created reminderResponse
+	if code := s.do("alice", http.MethodPost, "/reminders", `{"days":[1,3,5],"time":"18:30","timezone":"Europe/Stockholm"}`, &created); code != http.StatusCreated {
+		t.Fatalf("create status = %d", code)
+	}
+	if created.ID == "" || created.UserID != "alice" || len(created.Days) != 3 || created.Days[1] != time.Wednesday {
+		t.Fatalf("created = %+v", created)
+	}
+	if created.NextAt == nil || !created.NextAt.After(time.Now()) {
+		t.Fatalf("nextAt = %v, want a future time", created.NextAt)
+	}
+
+	var list []reminderResponse
+	s.do("alice", http.MethodGet, "/reminders", "", &list)
+	if len(list) != 1 || list[0].ID != created.ID {
+		t.Fatalf("alice's reminders = %+v", list)
+	}
+	s.do("bob", http.MethodGet, "/reminders", "", &list)
+	if len(list) != 0 {
+		t.Fatalf("bob's reminders = %+v, want none", list)
+	}
+
+	if code := s.do("bob", http.MethodDelete, "/reminders/"+created.ID, "", nil); code != http.StatusNotFound {
+		t.Fatalf("delete another user's reminder status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := s.do("alice", http.MethodDelete, "/reminders/"+created.ID, "", nil); code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestServerReminderStartsSession(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	var created reminderResponse
+	if code := s.do("alice", http.MethodPost, "/reminders", `{"time":"07:00","timezone":"UTC","startSession":true}`, &created); code != http.StatusCreated {
+		t.Fatalf("create status = %d", code)
+	}
+	if !created.StartSession {
+		t.Fatalf("created = %+v, want it to start a session", created)
+	}
+
+	now := time.Now().UTC()
+	s.repo.MarkReminderFired(ctx, created.ID, now.Add(-48*time.Hour))
+	notifier := &recordingNotifier{}
+	reminders := scheduler.New(s.repo, notifier,
+		scheduler.WithStarter(NewSessionStarter(s.repo, s.manager, config.Default().Targets)),
+		scheduler.WithMaxDelay(24*time.Hour),
+	)
+	if err := reminders.Check(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notifier.sent) != 1 || notifier.sent[0].SessionID == "" {
+		t.Fatalf("sent %+v, want a notification of the started session", notifier.sent)
+	}
+	var session domain.Session
+	if code := s.do("alice", http.MethodGet, "/sessions/"+notifier.sent[0].SessionID, "", &session); code != http.StatusOK {
+		t.Fatalf("started session status = %d", code)
+	}
+	if session.HouseholdID != "alice" || session.TargetSec != config.Default().Targets.DefaultSec {
+		t.Fatalf("started session = %+v, want alice's with the default target", session)
+	}
+}
+
+type recordingNotifier struct {
+	sent []scheduler.Notification
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, notification scheduler.Notification) error {
+	n.sent = append(n.sent, notification)
+	return nil
+}
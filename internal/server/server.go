@@ -0,0 +1,180 @@
+// Package server assembles the HTTP API: routes, middleware and the
+// handlers behind them.
+package server
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/hperssn/hound/internal/config"
+	httpapi "github.com/hperssn/hound/internal/http"
+	"github.com/hperssn/hound/internal/metrics"
+	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/storage"
+	"github.com/hperssn/hound/internal/webhook"
+)
+
+// Config holds what the router needs beyond the repository and session
+// manager. Zero values give a working server: header authentication, no
+// metrics endpoint and no tracing.
+type Config struct {
+	StaticDir string
+	Targets   config.TargetsConfig
+
+	// ShareSecret signs share links. Without it a random key is used, so
+	// links stop working after a restart.
+	ShareSecret []byte
+	// ShareRecheckInterval is how often an open share link stream checks
+	// that its link is still valid, 30 seconds if zero.
+	ShareRecheckInterval time.Duration
+
+	// Webhooks delivers session events to the users' webhooks. The
+	// manager should be given it as a runner.Notifier, and the archiver
+	// too, so every event is sent.
+	Webhooks *webhook.Dispatcher
+
+	// PushPublicKey is the VAPID key browsers subscribe to Web Push with.
+	// Push subscriptions are not offered without it.
+	PushPublicKey string
+
+	// OIDC enables browser login. Without it users are taken from the
+	// headers set by the reverse proxy.
+	OIDC *OIDCAuth
+
+	Logger         *slog.Logger
+	Metrics        metrics.Metrics
+	MetricsHandler http.Handler
+	TracerProvider trace.TracerProvider
+}
+
+// New returns the API handler. The manager should be created with
+// NewAuthorizer(repo) so session access follows household membership.
+func New(cfg Config, repo storage.Repository, m *runner.SessionManager) http.Handler {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = metrics.Nop{}
+	}
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = noop.NewTracerProvider()
+	}
+	if len(cfg.ShareSecret) == 0 {
+		cfg.Logger.Warn("no share secret configured, share links will not survive a restart")
+		cfg.ShareSecret = []byte(rand.Text())
+	}
+	if cfg.ShareRecheckInterval <= 0 {
+		cfg.ShareRecheckInterval = shareRecheckInterval
+	}
+	if cfg.Webhooks == nil {
+		cfg.Webhooks = webhook.New(repo, webhook.WithLogger(cfg.Logger))
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(httpapi.RequestLogger(cfg.Logger))
+	r.Use(middleware.Recoverer)
+	r.Use(httpapi.RequestMetrics(cfg.Metrics))
+	r.Use(httpapi.Tracing(cfg.TracerProvider))
+
+	r.Get("/", serveIndex(cfg.StaticDir))
+	r.Get("/health", healthCheck)
+	r.Get("/healthz", liveness)
+	r.Get("/readyz", readiness(repo, m))
+	if cfg.MetricsHandler != nil {
+		r.Handle("/metrics", cfg.MetricsHandler)
+	}
+	fs := http.FileServer(http.Dir(cfg.StaticDir))
+	r.Handle("/static/*", http.StripPrefix("/static/", fs))
+
+	authMiddleware := ExtractUserMiddleware
+	if cfg.OIDC != nil {
+		cfg.OIDC.Routes(r)
+		authMiddleware = cfg.OIDC.Middleware
+	}
+
+	shares := NewShareTokens(cfg.ShareSecret, repo)
+	r.Route("/shared/{token}", func(r chi.Router) {
+		r.Use(shares.Middleware)
+
+		r.Get("/", getSharedSession(m))
+		r.Get("/events", httpapi.StreamSessionEvents(m, sharedSession, cfg.Metrics,
+			httpapi.WithAccessCheck(cfg.ShareRecheckInterval, shares.StillValid),
+		))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(authMiddleware)
+
+		r.Post("/sessions", startSession(m, repo, cfg.Targets))
+		r.Get("/sessions/active", listActiveSessions(m))
+		r.Get("/sessions/{id}", getSession(m))
+		r.Post("/sessions/{id}/complete", completeSession(m, repo, cfg.Webhooks))
+		r.Post("/sessions/{id}/steps/{idx}/start", stepAction(m, m.StartStep))
+		r.Post("/sessions/{id}/steps/{idx}/pause", stepAction(m, m.PauseStep))
+		r.Post("/sessions/{id}/steps/{idx}/resume", stepAction(m, m.ResumeStep))
+		r.Post("/sessions/{id}/steps/{idx}/stop", stepAction(m, m.StopStep))
+		r.Post("/sessions/{id}/steps", insertStep(m))
+		r.Patch("/sessions/{id}/steps/{idx}", updateStep(m))
+		r.Delete("/sessions/{id}/steps/{idx}", removeStep(m))
+		r.Post("/sessions/{id}/stop", stopSession(m))
+		r.Get("/sessions/{id}/events", httpapi.StreamSessionEvents(m, memberSession(m), cfg.Metrics))
+		r.Get("/sessions/{id}/status", getSessionStatus(m))
+		r.Post("/sessions/{id}/shares", createShareLink(m, repo, shares))
+		r.Get("/sessions/{id}/shares", listShareLinks(m, repo))
+		r.Delete("/sessions/{id}/shares/{shareId}", revokeShareLink(m, repo))
+		r.Get("/next-target", getNextTarget(repo, cfg.Targets))
+
+		r.Get("/history", getHistory(repo))
+		r.Patch("/history/{id}", updateHistory(repo))
+		r.Delete("/history/{id}", deleteHistory(repo))
+		r.Post("/history/{id}/restore", restoreHistory(repo))
+		r.Get("/history/{id}/changes", listHistoryChanges(repo))
+		r.Get("/stats", getStats(repo))
+
+		r.Get("/reminders", listReminders(repo))
+		r.Post("/reminders", createReminder(repo))
+		r.Delete("/reminders/{reminderId}", deleteReminder(repo))
+
+		r.Get("/push/key", getPushKey(cfg.PushPublicKey))
+		r.Post("/push/subscriptions", subscribePush(repo))
+		r.Delete("/push/subscriptions", unsubscribePush(repo))
+
+		r.Get("/webhooks", listWebhooks(repo))
+		r.Post("/webhooks", createWebhook(repo, cfg.Webhooks))
+		r.Delete("/webhooks/{webhookId}", deleteWebhook(repo))
+		r.Get("/webhooks/{webhookId}/deliveries", listWebhookDeliveries(repo))
+		r.Post("/webhooks/{webhookId}/test", testWebhook(repo, cfg.Webhooks))
+
+		r.Get("/households", listHouseholds(repo))
+		r.Post("/households", createHousehold(repo))
+		r.Get("/households/{hid}/members", listMembers(repo))
+		r.Put("/households/{hid}/members/{userId}", updateMember(repo))
+		r.Delete("/households/{hid}/members/{userId}", removeMember(repo))
+		r.Post("/households/{hid}/invitations", createInvitation(repo))
+		r.Post("/invitations/{token}/accept", acceptInvitation(repo))
+	})
+
+	return r
+}
+
+// healthCheck predates /healthz and /readyz and is kept for existing
+// probes.
+func healthCheck(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func serveIndex(staticDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join(staticDir, "index.html"))
+	}
+}
@@ -0,0 +1,547 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/config"
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/storage"
+	"github.com/hperssn/hound/internal/webhook"
+)
+
+type testServer struct {
+	t       *testing.T
+	url     string
+	handler http.Handler
+	repo    storage.Repository
+	manager *runner.SessionManager
+}
+
+type testServerOptions struct {
+	repo    storage.Repository
+	config  func(*Config)
+	manager []runner.Option
+}
+
+type testServerOption func(*testServerOptions)
+
+// withRepo serves from repo instead of a fresh memory repository.
+func withRepo(repo storage.Repository) testServerOption {
+	return func(o *testServerOptions) { o.repo = repo }
+}
+
+// withConfig adjusts the server's Config before it is built.
+func withConfig(f func(*Config)) testServerOption {
+	return func(o *testServerOptions) { o.config = f }
+}
+
+// withManagerOptions adds options to the session manager.
+func withManagerOptions(opts ...runner.Option) testServerOption {
+	return func(o *testServerOptions) { o.manager = append(o.manager, opts...) }
+}
+
+func newTestServer(t *testing.T, opts ...testServerOption) *testServer {
+	t.Helper()
+
+	o := testServerOptions{repo: storage.NewMemoryRepository()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	repo := o.repo
+
+	hooks := webhook.New(repo,
+		webhook.WithRetries(1, time.Millisecond),
+		webhook.WithAllowedNetworks([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}),
+	)
+	manager := runner.NewSessionManager(append([]runner.Option{
+		runner.WithAuthorizer(NewAuthorizer(repo)),
+		runner.WithArchiver(NewArchiver(repo, hooks)),
+		runner.WithNotifier(hooks),
+		runner.WithMaxSessionsPerUser(2),
+	}, o.manager...)...)
+	cfg := Config{
+		Targets:       config.Default().Targets,
+		ShareSecret:   []byte(testSecret),
+		PushPublicKey: testPushKey,
+		Webhooks:      hooks,
+	}
+	if o.config != nil {
+		o.config(&cfg)
+	}
+	handler := New(cfg, repo, manager)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { hooks.Shutdown(context.Background()) })
+
+	return &testServer{t: t, url: srv.URL, handler: handler, repo: repo, manager: manager}
+}
+
+// do sends a request as user, or without a user if it is empty, and
+// decodes a successful response into out.
+func (s *testServer) do(user, method, path, body string, out any) int {
+	s.t.Helper()
+
+	req, err := http.NewRequest(method, s.url+path, strings.NewReader(body))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if user != "" {
+		req.Header.Set("X-Auth-User", user)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			s.t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// stream subscribes to a session's events and delivers them on the returned
+// channel until the test ends.
+func (s *testServer) stream(user, sessionID string) <-chan runner.StepEvent {
+	s.t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+"/sessions/"+sessionID+"/events", nil)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req.Header.Set("X-Auth-User", user)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		resp.Body.Close()
+		s.t.Fatalf("events status = %d, content type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan runner.StepEvent, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var ev runner.StepEvent
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				return
+			}
+			events <- ev
+		}
+	}()
+	return events
+}
+
+func TestServerTrainingSession(t *testing.T) {
+	s := newTestServer(t)
+
+	var next map[string]int
+	if code := s.do("alice", http.MethodGet, "/next-target", "", &next); code != http.StatusOK {
+		t.Fatalf("next target status = %d", code)
+	}
+	if want := config.Default().Targets.DefaultSec; next["nextTarget"] != want {
+		t.Fatalf("next target without history = %d, want %d", next["nextTarget"], want)
+	}
+
+	var session domain.Session
+	if code := s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session); code != http.StatusCreated {
+		t.Fatalf("start session status = %d", code)
+	}
+	if session.TargetSec != 60 || len(session.Steps) == 0 || session.HouseholdID != "alice" {
+		t.Fatalf("session = %+v", session)
+	}
+	path := "/sessions/" + session.ID
+
+	if code := s.do("bob", http.MethodGet, path, "", nil); code != http.StatusNotFound {
+		t.Fatalf("outsider status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	events := s.stream("alice", session.ID)
+
+	if code := s.do("alice", http.MethodPost, path+"/steps/0/start", "", nil); code != http.StatusNoContent {
+		t.Fatalf("start step status = %d", code)
+	}
+
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("event stream closed")
+		}
+		if ev.Index != 0 || ev.Elapsed < 1 {
+			t.Fatalf("event = %+v, want a tick for step 0", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event streamed for the running step")
+	}
+
+	// a one second warmup step has completed by its first tick
+	wantStop := http.StatusNoContent
+	if session.Steps[0].Duration <= 1 {
+		wantStop = http.StatusConflict
+	}
+	if code := s.do("alice", http.MethodPost, path+"/steps/0/stop", "", nil); code != wantStop {
+		t.Fatalf("stop step status = %d, want %d", code, wantStop)
+	}
+
+	var status struct {
+		ID        string `json:"id"`
+		Completed bool   `json:"completed"`
+	}
+	if code := s.do("alice", http.MethodGet, path+"/status", "", &status); code != http.StatusOK {
+		t.Fatalf("status endpoint = %d", code)
+	}
+	if status.ID != session.ID {
+		t.Fatalf("status = %+v", status)
+	}
+
+	if code := s.do("alice", http.MethodPost, path+"/complete", `{"success":"great","comment":"calm"}`, nil); code != http.StatusOK {
+		t.Fatalf("complete status = %d", code)
+	}
+
+	var history []storage.SessionRecord
+	if code := s.do("alice", http.MethodGet, "/history", "", &history); code != http.StatusOK {
+		t.Fatalf("history status = %d", code)
+	}
+	if len(history) != 1 || history[0].ID != session.ID || history[0].Success != storage.SuccessLevelGreat || history[0].Comment != "calm" {
+		t.Fatalf("history = %+v", history)
+	}
+
+	var stats storage.SessionStats
+	if code := s.do("alice", http.MethodGet, "/stats", "", &stats); code != http.StatusOK {
+		t.Fatalf("stats status = %d", code)
+	}
+	want := storage.SessionStats{TotalSessions: 1, SuccessfulCount: 1, AverageTarget: 60, TotalTrainTime: 60, SuccessRate: 100}
+	if stats != want {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+
+	if code := s.do("alice", http.MethodGet, "/next-target", "", &next); code != http.StatusOK {
+		t.Fatalf("next target status = %d", code)
+	}
+	if next["nextTarget"] != 72 {
+		t.Fatalf("next target after a great session = %d, want 72", next["nextTarget"])
+	}
+}
+
+func TestServerSessionDefaultsToNextTarget(t *testing.T) {
+	s := newTestServer(t)
+
+	err := s.repo.SaveSession(context.Background(), &storage.SessionRecord{
+		ID:          "earlier",
+		UserID:      "alice",
+		HouseholdID: "alice",
+		TargetSec:   100,
+		Success:     storage.SuccessLevelFail,
+		StartedAt:   time.Now().Add(-time.Hour),
+		CompletedAt: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var session domain.Session
+	if code := s.do("alice", http.MethodPost, "/sessions", `{}`, &session); code != http.StatusCreated {
+		t.Fatalf("start session status = %d", code)
+	}
+	if session.TargetSec != 90 {
+		t.Fatalf("target after a failed session = %d, want 90", session.TargetSec)
+	}
+}
+
+func TestServerStopSession(t *testing.T) {
+	s := newTestServer(t)
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+
+	if code := s.do("viewer", http.MethodPost, "/sessions/"+session.ID+"/stop", "", nil); code != http.StatusNotFound {
+		t.Fatalf("outsider stop status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := s.do("alice", http.MethodPost, "/sessions/"+session.ID+"/stop", "", nil); code != http.StatusNoContent {
+		t.Fatalf("stop session status = %d", code)
+	}
+	if code := s.do("alice", http.MethodGet, "/sessions/"+session.ID, "", nil); code != http.StatusNotFound {
+		t.Fatalf("stopped session status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	var history []storage.SessionRecord
+	s.do("alice", http.MethodGet, "/history", "", &history)
+	if len(history) != 0 {
+		t.Fatalf("history after stopping an untouched session = %+v, want empty", history)
+	}
+}
+
+func TestServerStoppedSessionIsArchived(t *testing.T) {
+	s := newTestServer(t)
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+	path := "/sessions/" + session.ID
+
+	if code := s.do("alice", http.MethodPost, path+"/steps/0/start", "", nil); code != http.StatusNoContent {
+		t.Fatalf("start step status = %d", code)
+	}
+	if code := s.do("alice", http.MethodPost, path+"/stop", "", nil); code != http.StatusNoContent {
+		t.Fatalf("stop session status = %d", code)
+	}
+
+	var history []storage.SessionRecord
+	s.do("alice", http.MethodGet, "/history", "", &history)
+	if len(history) != 1 || history[0].ID != session.ID || history[0].Success != storage.SuccessLevelAbandoned {
+		t.Fatalf("history = %+v, want the stopped session as abandoned", history)
+	}
+
+	var next map[string]int
+	s.do("alice", http.MethodGet, "/next-target", "", &next)
+	if want := config.Default().Targets.DefaultSec; next["nextTarget"] != want {
+		t.Fatalf("next target after an abandoned session = %d, want %d", next["nextTarget"], want)
+	}
+
+	// rating the session after it was archived replaces the abandoned result
+	if code := s.do("alice", http.MethodPost, path+"/complete", `{"success":"great"}`, nil); code != http.StatusOK {
+		t.Fatalf("complete status = %d", code)
+	}
+	s.do("alice", http.MethodGet, "/history", "", &history)
+	if len(history) != 1 || history[0].Success != storage.SuccessLevelGreat {
+		t.Fatalf("history after completing = %+v", history)
+	}
+}
+
+// Browsers reconnect to event streams while the server shuts down; streams
+// opened after the manager stopped must end rather than hold up the server.
+func TestServerStreamAfterShutdown(t *testing.T) {
+	s := newTestServer(t)
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+	if err := s.manager.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 20 {
+		events := s.stream("alice", session.ID)
+		timeout := time.After(time.Second)
+	drain:
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					break drain
+				}
+			case <-timeout:
+				t.Fatal("stream opened after shutdown is still open")
+			}
+		}
+	}
+}
+
+func TestServerProbesAndRequestID(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, path := range []string{"/health", "/healthz", "/readyz"} {
+		resp, err := http.Get(s.url + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s status = %d", path, resp.StatusCode)
+		}
+		if resp.Header.Get("X-Request-Id") == "" {
+			t.Errorf("%s has no X-Request-Id header", path)
+		}
+	}
+
+	if code := s.do("", http.MethodGet, "/history", "", nil); code != http.StatusInternalServerError {
+		t.Fatalf("request without auth header status = %d, want %d", code, http.StatusInternalServerError)
+	}
+}
+
+func TestServerActiveSessions(t *testing.T) {
+	s := newTestServer(t)
+
+	var active []domain.Session
+	if code := s.do("alice", http.MethodGet, "/sessions/active", "", &active); code != http.StatusOK {
+		t.Fatalf("active sessions status = %d", code)
+	}
+	if active == nil || len(active) != 0 {
+		t.Fatalf("active sessions = %+v, want an empty list", active)
+	}
+
+	var first, second domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &first)
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &second)
+	if code := s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, nil); code != http.StatusConflict {
+		t.Fatalf("session over the limit status = %d, want %d", code, http.StatusConflict)
+	}
+
+	s.do("alice", http.MethodGet, "/sessions/active", "", &active)
+	if len(active) != 2 || active[0].ID != first.ID || active[1].ID != second.ID {
+		t.Fatalf("active sessions = %+v", active)
+	}
+	s.do("bob", http.MethodGet, "/sessions/active", "", &active)
+	if len(active) != 0 {
+		t.Fatalf("bob's active sessions = %+v, want none", active)
+	}
+
+	if code := s.do("alice", http.MethodPost, "/sessions/"+first.ID+"/stop", "", nil); code != http.StatusNoContent {
+		t.Fatalf("stop session status = %d", code)
+	}
+	if code := s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, nil); code != http.StatusCreated {
+		t.Fatalf("session after discarding one status = %d", code)
+	}
+}
+
+func TestServerRejectsIllegalTransitions(t *testing.T) {
+	s := newTestServer(t)
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+	path := "/sessions/" + session.ID
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"step out of order", path + "/steps/1/start", http.StatusConflict},
+		{"stop a pending step", path + "/steps/0/stop", http.StatusConflict},
+		{"start first step", path + "/steps/0/start", http.StatusNoContent},
+		{"start a running step", path + "/steps/0/start", http.StatusConflict},
+		{"resume a running step", path + "/steps/0/resume", http.StatusConflict},
+		{"pause", path + "/steps/0/pause", http.StatusNoContent},
+		{"pause twice", path + "/steps/0/pause", http.StatusConflict},
+		{"start a paused step", path + "/steps/0/start", http.StatusConflict},
+		{"resume", path + "/steps/0/resume", http.StatusNoContent},
+		{"pause again", path + "/steps/0/pause", http.StatusNoContent},
+		{"stop a paused step", path + "/steps/0/stop", http.StatusNoContent},
+		{"resume a stopped step", path + "/steps/0/resume", http.StatusConflict},
+		{"unknown step", path + "/steps/99/start", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code := s.do("alice", http.MethodPost, tt.path, "", nil); code != tt.want {
+			t.Fatalf("%s: status = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	var status struct {
+		State domain.State `json:"state"`
+	}
+	s.do("alice", http.MethodGet, path+"/status", "", &status)
+	if status.State != domain.StatePaused {
+		t.Fatalf("state = %s, want paused between steps", status.State)
+	}
+	var stopped domain.Session
+	s.do("alice", http.MethodGet, path, "", &stopped)
+	if stopped.Steps[0].State != domain.StateCompleted {
+		t.Fatalf("stopped step = %+v, want completed", stopped.Steps[0])
+	}
+
+	s.do("alice", http.MethodPost, path+"/complete", `{"success":"ok"}`, nil)
+	if code := s.do("alice", http.MethodPost, path+"/steps/0/start", "", nil); code != http.StatusConflict {
+		t.Fatalf("start step after completing status = %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestServerAutoAdvanceSession(t *testing.T) {
+	s := newTestServer(t)
+
+	if code := s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60,"autoAdvance":{"restSec":30,"maxRestSec":10}}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("invalid rest range status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60,"autoAdvance":{"restSec":9223372036854775807}}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("endless rest status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	var session domain.Session
+	if code := s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60,"autoAdvance":{"restSec":10,"maxRestSec":30}}`, &session); code != http.StatusCreated {
+		t.Fatalf("start session status = %d", code)
+	}
+	if session.AutoAdvance == nil || *session.AutoAdvance != (domain.AutoAdvance{RestSec: 10, MaxRestSec: 30}) {
+		t.Fatalf("auto advance = %+v", session.AutoAdvance)
+	}
+}
+
+func TestServerEditPlan(t *testing.T) {
+	s := newTestServer(t)
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+	path := "/sessions/" + session.ID
+	last := len(session.Steps) - 1
+
+	var edited domain.Session
+	if code := s.do("alice", http.MethodPatch, fmt.Sprintf("%s/steps/%d", path, last), `{"duration":45}`, &edited); code != http.StatusOK {
+		t.Fatalf("shorten target status = %d", code)
+	}
+	if edited.TargetSec != 45 || edited.Steps[last].Duration != 45 {
+		t.Fatalf("target = %d, last step %+v; want 45", edited.TargetSec, edited.Steps[last])
+	}
+	if code := s.do("alice", http.MethodPost, path+"/steps", fmt.Sprintf(`{"index":%d,"duration":10}`, last), &edited); code != http.StatusOK {
+		t.Fatalf("insert step status = %d", code)
+	}
+	if len(edited.Steps) != len(session.Steps)+1 || edited.Steps[last].Duration != 10 {
+		t.Fatalf("steps after insert = %+v", edited.Steps)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"remove the target", http.MethodDelete, fmt.Sprintf("%s/steps/%d", path, last+1), "", http.StatusConflict},
+		{"zero duration", http.MethodPatch, path + "/steps/0", `{"duration":0}`, http.StatusBadRequest},
+		{"unknown step", http.MethodDelete, path + "/steps/99", "", http.StatusNotFound},
+		{"another household", http.MethodDelete, fmt.Sprintf("%s/steps/%d", path, last), "", http.StatusNotFound},
+		{"remove inserted step", http.MethodDelete, fmt.Sprintf("%s/steps/%d", path, last), "", http.StatusOK},
+		{"start first step", http.MethodPost, path + "/steps/0/start", "", http.StatusNoContent},
+		{"change a started step", http.MethodPatch, path + "/steps/0", `{"duration":5}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		user := "alice"
+		if tt.name == "another household" {
+			user = "mallory"
+		}
+		if code := s.do(user, tt.method, tt.path, tt.body, nil); code != tt.want {
+			t.Fatalf("%s: status = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	s.do("alice", http.MethodPost, path+"/complete", `{"success":"ok"}`, nil)
+	var history []storage.SessionRecord
+	s.do("alice", http.MethodGet, "/history", "", &history)
+	if len(history) != 1 || len(history[0].PlanChanges) != 3 {
+		t.Fatalf("history = %+v, want the session with its 3 plan changes", history)
+	}
+	if c := history[0].PlanChanges[0]; c.Action != domain.PlanStepResized || c.Duration != 45 || c.PreviousDuration != 60 {
+		t.Fatalf("first plan change = %+v, want the target shortened from 60 to 45", c)
+	}
+}
@@ -0,0 +1,581 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hperssn/hound/internal/config"
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/logging"
+	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/scheduler"
+	"github.com/hperssn/hound/internal/storage"
+	"github.com/hperssn/hound/internal/webhook"
+)
+
+func startSession(m *runner.SessionManager, repo storage.Repository, targets config.TargetsConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		householdID, ok := requireHousehold(w, r, repo, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			TargetSec *int `json:"targetSec,omitempty"`
+			// AutoAdvance starts each step after the first on its own,
+			// following a rest of restSec seconds or a random one of up
+			// to maxRestSec.
+			AutoAdvance *struct {
+				RestSec    int `json:"restSec"`
+				MaxRestSec int `json:"maxRestSec"`
+			} `json:"autoAdvance,omitempty"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		var autoAdvance *domain.AutoAdvance
+		if req.AutoAdvance != nil {
+			autoAdvance = &domain.AutoAdvance{RestSec: req.AutoAdvance.RestSec, MaxRestSec: req.AutoAdvance.MaxRestSec}
+			if err := autoAdvance.Validate(); err != nil {
+				respondError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var targetSec int
+
+		if req.TargetSec != nil && *req.TargetSec > 0 {
+			targetSec = *req.TargetSec
+		} else {
+			calculated, err := calculateNextTarget(r.Context(), repo, householdID, targets)
+			if err != nil {
+				slog.WarnContext(r.Context(), "failed to calculate target, using default", "err", err)
+				targetSec = targets.DefaultSec
+			} else {
+				targetSec = calculated
+			}
+		}
+
+		session := domain.NewSession("", userId, targetSec)
+		session.HouseholdID = householdID
+		session.AutoAdvance = autoAdvance
+		logging.With(r.Context(), slog.String(logging.SessionIDKey, session.ID))
+
+		if err := m.StartSession(r.Context(), session); err != nil {
+			respondError(w, err.Error(), managerErrorStatus(err, http.StatusConflict))
+			return
+		}
+
+		respondJSON(w, session, http.StatusCreated)
+	}
+}
+
+func calculateNextTarget(ctx context.Context, repo storage.Repository, householdID string, targets config.TargetsConfig) (int, error) {
+	sessions, err := repo.GetRecentSessions(ctx, householdID, time.Now().AddDate(0, 0, -targets.HistoryDays))
+	if err != nil {
+		return 0, err
+	}
+
+	// abandoned sessions say nothing about how the dog did
+	rated := slices.DeleteFunc(sessions, func(s storage.SessionRecord) bool {
+		return s.Success == storage.SuccessLevelAbandoned
+	})
+	if len(rated) == 0 {
+		return targets.DefaultSec, nil
+	}
+
+	lastSession := rated[0]
+	lastTarget := lastSession.TargetSec
+
+	var next float64
+	switch lastSession.Success {
+	case storage.SuccessLevelGreat:
+		next = float64(lastTarget) * targets.Great
+	case storage.SuccessLevelOK:
+		next = float64(lastTarget) * targets.OK
+	case storage.SuccessLevelFail:
+		next = float64(lastTarget) * targets.Fail
+	default:
+		next = float64(lastTarget)
+	}
+
+	if next <= float64(lastTarget) && lastSession.Success != storage.SuccessLevelFail {
+		next = float64(lastTarget + 1)
+	}
+
+	return int(next), nil
+}
+
+func getNextTarget(repo storage.Repository, targets config.TargetsConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdID, ok := requireHousehold(w, r, repo, domain.Role.CanView)
+		if !ok {
+			return
+		}
+
+		target, err := calculateNextTarget(r.Context(), repo, householdID, targets)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to calculate next target", "err", err)
+			respondError(w, "failed to calculate next target", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, map[string]int{"nextTarget": target}, http.StatusOK)
+	}
+}
+
+func getSession(m *runner.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := authorizedSession(w, r, m, domain.Role.CanView)
+		if !ok {
+			return
+		}
+
+		respondJSON(w, session, http.StatusOK)
+	}
+}
+
+// listActiveSessions returns the sessions the user started and has not
+// completed, so the UI can offer to resume or discard them.
+func listActiveSessions(m *runner.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		respondJSON(w, m.ActiveSessions(userId), http.StatusOK)
+	}
+}
+
+// sessionArchiver adapts the repository to runner.Archiver.
+type sessionArchiver struct {
+	repo  storage.Repository
+	hooks *webhook.Dispatcher
+}
+
+// NewArchiver returns a runner.Archiver that saves sessions to history as
+// abandoned and reports them to hooks, if given.
+func NewArchiver(repo storage.Repository, hooks *webhook.Dispatcher) runner.Archiver {
+	return sessionArchiver{repo, hooks}
+}
+
+func (a sessionArchiver) ArchiveSession(ctx context.Context, s domain.Session) error {
+	record := storage.FromDomainSession(&s, storage.SuccessLevelAbandoned, "")
+	err := a.repo.SaveSession(ctx, record)
+	if errors.Is(err, storage.ErrDuplicate) {
+		return nil // the user completed it first
+	}
+	if err == nil && a.hooks != nil {
+		a.hooks.SessionSaved(ctx, *record)
+	}
+	return err
+}
+
+type sessionStarter struct {
+	repo    storage.Repository
+	m       *runner.SessionManager
+	targets config.TargetsConfig
+}
+
+// NewSessionStarter starts the sessions of reminders set to start one. They
+// are started in the user's personal household, aiming for the target a
+// session started without one gets.
+func NewSessionStarter(repo storage.Repository, m *runner.SessionManager, targets config.TargetsConfig) scheduler.Starter {
+	return sessionStarter{repo, m, targets}
+}
+
+func (st sessionStarter) StartSession(ctx context.Context, userID string) (*domain.Session, error) {
+	// creates the personal household on first use
+	if _, err := memberRole(ctx, st.repo, userID, userID); err != nil {
+		return nil, err
+	}
+
+	targetSec, err := calculateNextTarget(ctx, st.repo, userID, st.targets)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to calculate target, using default", "err", err)
+		targetSec = st.targets.DefaultSec
+	}
+
+	session := domain.NewSession("", userID, targetSec)
+	session.HouseholdID = userID
+	if err := st.m.StartSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// completeSession saves the session to history. Repeating the request is
+// safe: a session that is already saved is answered with the stored record,
+// or a 409 if the repeat reports a different result.
+func completeSession(m *runner.SessionManager, repo storage.Repository, hooks *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Success storage.SuccessLevel `json:"success"`
+			Comment string               `json:"comment"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !validRating(req.Success) {
+			respondError(w, "invalid success level", http.StatusBadRequest)
+			return
+		}
+
+		// repeats usually find the session saved; it may already have
+		// been evicted from the manager
+		_, err := repo.GetSession(r.Context(), chi.URLParam(r, "id"))
+		if err == nil {
+			completeRepeat(w, r, m, repo, req.Success, req.Comment)
+			return
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "failed to get session", "err", err)
+			respondError(w, "failed to save session", http.StatusInternalServerError)
+			return
+		}
+
+		session, ok := authorizedSession(w, r, m, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+		// the session is checkpointed as live and comes back on restart;
+		// saving it now would leave it in history as well
+		if m.Load().ShuttingDown {
+			respondError(w, runner.ErrShuttingDown.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		record := storage.FromDomainSession(session, req.Success, req.Comment)
+		err = repo.SaveSession(r.Context(), record)
+		if errors.Is(err, storage.ErrDuplicate) {
+			// a concurrent request saved it first
+			completeRepeat(w, r, m, repo, req.Success, req.Comment)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to save session", "err", err)
+			respondError(w, "failed to save session", http.StatusInternalServerError)
+			return
+		}
+		if err := m.CompleteSession(r.Context(), session.ID); err != nil {
+			slog.ErrorContext(r.Context(), "failed to mark session complete", "err", err)
+		}
+		hooks.SessionSaved(context.WithoutCancel(r.Context()), *record)
+
+		respondCompleted(w, record)
+	}
+}
+
+// validRating reports whether l is a result users may give a session.
+// Sessions are only saved as abandoned by the server.
+func validRating(l storage.SuccessLevel) bool {
+	return l.Valid() && l != storage.SuccessLevelAbandoned
+}
+
+// completeRepeat answers a completion request for a session that is already
+// saved.
+func completeRepeat(w http.ResponseWriter, r *http.Request, m *runner.SessionManager, repo storage.Repository, success storage.SuccessLevel, comment string) {
+	record, ok := savedSession(w, r, repo, domain.Role.CanTrain)
+	if !ok {
+		return
+	}
+
+	// a session saved as abandoned can still be rated by its user
+	if record.Success == storage.SuccessLevelAbandoned {
+		updated, err := repo.UpdateSession(r.Context(), record.ID, storage.SessionEdit{Success: &success, Comment: &comment}, GetUserId(r), time.Now())
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "failed to rate abandoned session", "err", err)
+			respondError(w, "failed to save session", http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			record = updated
+		}
+	}
+
+	if record.Success != success || record.Comment != comment {
+		respondError(w, "session was already completed with a different result", http.StatusConflict)
+		return
+	}
+
+	// the first request may have saved the session but failed to mark it
+	// complete
+	if err := m.CompleteSession(r.Context(), record.ID); err != nil && !errors.Is(err, runner.ErrSessionNotFound) {
+		slog.ErrorContext(r.Context(), "failed to mark session complete", "err", err)
+	}
+
+	respondCompleted(w, record)
+}
+
+func respondCompleted(w http.ResponseWriter, record *storage.SessionRecord) {
+	resp := struct {
+		Status  string                 `json:"status"`
+		Session *storage.SessionRecord `json:"session"`
+	}{
+		Status:  "saved",
+		Session: record,
+	}
+	respondJSON(w, resp, http.StatusOK)
+}
+
+func getSessionStatus(m *runner.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := authorizedSession(w, r, m, domain.Role.CanView)
+		if !ok {
+			return
+		}
+
+		status := struct {
+			ID        string       `json:"id"`
+			State     domain.State `json:"state"`
+			Completed bool         `json:"completed"`
+			Current   int          `json:"currentStep"`
+		}{
+			ID:        session.ID,
+			State:     session.State,
+			Completed: session.Completed,
+			Current:   session.CurrentIdx,
+		}
+
+		respondJSON(w, status, http.StatusOK)
+	}
+}
+
+func stopSession(m *runner.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := authorizedSession(w, r, m, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+
+		if err := m.StopSession(r.Context(), session.ID); err != nil {
+			respondError(w, err.Error(), managerErrorStatus(err, http.StatusNotFound))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// stepAction serves the endpoints that start, pause, resume and stop a
+// step by calling action for the step in the URL.
+func stepAction(m *runner.SessionManager, action func(ctx context.Context, sessionID string, idx int) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stepIdx, err := parseStepIndex(r)
+		if err != nil {
+			respondError(w, "invalid step index", http.StatusBadRequest)
+			return
+		}
+
+		session, ok := authorizedSession(w, r, m, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+
+		if err := action(r.Context(), session.ID, stepIdx); err != nil {
+			respondError(w, err.Error(), managerErrorStatus(err, http.StatusNotFound))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// insertStep adds a step before a step that has not started yet.
+func insertStep(m *runner.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Index    int `json:"index"`
+			Duration int `json:"duration"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		editPlan(w, r, m, func(ctx context.Context, sessionID string) error {
+			return m.InsertStep(ctx, sessionID, req.Index, req.Duration)
+		})
+	}
+}
+
+// removeStep drops a step that has not started yet.
+func removeStep(m *runner.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stepIdx, err := parseStepIndex(r)
+		if err != nil {
+			respondError(w, "invalid step index", http.StatusBadRequest)
+			return
+		}
+
+		editPlan(w, r, m, func(ctx context.Context, sessionID string) error {
+			return m.RemoveStep(ctx, sessionID, stepIdx)
+		})
+	}
+}
+
+// updateStep changes the duration of a step that has not started yet.
+func updateStep(m *runner.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stepIdx, err := parseStepIndex(r)
+		if err != nil {
+			respondError(w, "invalid step index", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Duration int `json:"duration"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		editPlan(w, r, m, func(ctx context.Context, sessionID string) error {
+			return m.SetStepDuration(ctx, sessionID, stepIdx, req.Duration)
+		})
+	}
+}
+
+// editPlan applies edit to the session named in the URL and responds with
+// the session's new plan.
+func editPlan(w http.ResponseWriter, r *http.Request, m *runner.SessionManager, edit func(ctx context.Context, sessionID string) error) {
+	session, ok := authorizedSession(w, r, m, domain.Role.CanTrain)
+	if !ok {
+		return
+	}
+
+	if err := edit(r.Context(), session.ID); err != nil {
+		status := http.StatusNotFound
+		switch {
+		case errors.Is(err, domain.ErrInvalidDuration):
+			status = http.StatusBadRequest
+		case errors.Is(err, domain.ErrStepStarted), errors.Is(err, domain.ErrTargetStep):
+			status = http.StatusConflict
+		}
+		respondError(w, err.Error(), managerErrorStatus(err, status))
+		return
+	}
+
+	session, exists := m.GetSession(session.ID)
+	if !exists {
+		respondError(w, "session not found", http.StatusNotFound)
+		return
+	}
+	respondJSON(w, session, http.StatusOK)
+}
+
+func getHistory(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdID, ok := requireHousehold(w, r, repo, domain.Role.CanView)
+		if !ok {
+			return
+		}
+
+		sessions, err := repo.GetSessionsByHousehold(r.Context(), householdID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get history", "err", err)
+			respondError(w, "failed to retrieve history", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, sessions, http.StatusOK)
+	}
+}
+
+func getStats(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		householdID, ok := requireHousehold(w, r, repo, domain.Role.CanView)
+		if !ok {
+			return
+		}
+
+		stats, err := repo.GetSessionStats(r.Context(), householdID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get stats", "err", err)
+			respondError(w, "failed to retrieve stats", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, stats, http.StatusOK)
+	}
+}
+
+// helpers below
+
+// managerErrorStatus maps SessionManager errors that mean the same thing
+// for every endpoint, falling back to status.
+func managerErrorStatus(err error, status int) int {
+	switch {
+	case errors.Is(err, runner.ErrShuttingDown):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, domain.ErrInvalidTransition), errors.Is(err, domain.ErrStepOutOfOrder):
+		return http.StatusConflict
+	}
+	return status
+}
+
+// authorizedSession loads the session named in the URL for the requesting
+// user. It writes a 404 and returns false if the session does not exist or
+// belongs to a household the user is not a member of, and a 403 if their
+// role does not allow the operation.
+func authorizedSession(w http.ResponseWriter, r *http.Request, m *runner.SessionManager, allowed func(domain.Role) bool) (*domain.Session, bool) {
+	id := chi.URLParam(r, "id")
+	logging.With(r.Context(), slog.String(logging.SessionIDKey, id))
+
+	session, role, err := m.GetSessionForUser(r.Context(), id, GetUserId(r))
+	if errors.Is(err, runner.ErrSessionNotFound) {
+		respondError(w, "session not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to authorize session", "err", err)
+		respondError(w, "failed to load session", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !allowed(role) {
+		respondError(w, "forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return session, true
+}
+
+func parseStepIndex(r *http.Request) (int, error) {
+	idxStr := chi.URLParam(r, "idx")
+	return strconv.Atoi(idxStr)
+}
+
+func respondJSON(w http.ResponseWriter, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("failed to encode response", "err", err)
+	}
+}
+
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
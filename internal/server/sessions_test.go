@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+func TestSessionEndpointsEnforceOwnership(t *testing.T) {
+	s := newTestServer(t)
+
+	var session domain.Session
+	if code := s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session); code != http.StatusCreated {
+		t.Fatalf("start session status = %d", code)
+	}
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/sessions/" + session.ID, ""},
+		{http.MethodPost, "/sessions/" + session.ID + "/complete", `{"success":"great"}`},
+		{http.MethodPost, "/sessions/" + session.ID + "/steps/0/start", ""},
+		{http.MethodPost, "/sessions/" + session.ID + "/steps/0/stop", ""},
+		{http.MethodPost, "/sessions/" + session.ID + "/stop", ""},
+		{http.MethodGet, "/sessions/" + session.ID + "/events", ""},
+		{http.MethodGet, "/sessions/" + session.ID + "/status", ""},
+	}
+
+	for _, tt := range requests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if code := s.do("bob", tt.method, tt.path, tt.body, nil); code != http.StatusNotFound {
+				t.Fatalf("status = %d, want %d", code, http.StatusNotFound)
+			}
+		})
+	}
+
+	if _, _, err := s.manager.GetSessionForUser(context.Background(), session.ID, "alice"); err != nil {
+		t.Fatalf("foreign requests must not affect the owner's session: %v", err)
+	}
+	if code := s.do("alice", http.MethodGet, "/sessions/"+session.ID, "", nil); code != http.StatusOK {
+		t.Fatalf("owner status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestCompleteSessionIsIdempotent(t *testing.T) {
+	s := newTestServer(t)
+
+	var session domain.Session
+	if code := s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session); code != http.StatusCreated {
+		t.Fatalf("start session status = %d", code)
+	}
+	path := "/sessions/" + session.ID + "/complete"
+	body := `{"success":"great","comment":"calm"}`
+
+	for _, bad := range []string{`{}`, `{"success":"bogus"}`, `{"success":"abandoned"}`} {
+		if code := s.do("alice", http.MethodPost, path, bad, nil); code != http.StatusBadRequest {
+			t.Fatalf("complete with %s status = %d, want %d", bad, code, http.StatusBadRequest)
+		}
+	}
+
+	// a double tap sends the same request twice at once
+	var wg sync.WaitGroup
+	codes := make(chan int, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- s.do("alice", http.MethodPost, path, body, nil)
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("concurrent complete status = %d, want %d", code, http.StatusOK)
+		}
+	}
+
+	var resp struct {
+		Status  string                `json:"status"`
+		Session storage.SessionRecord `json:"session"`
+	}
+	if code := s.do("alice", http.MethodPost, path, body, &resp); code != http.StatusOK {
+		t.Fatalf("repeat status = %d", code)
+	}
+	if resp.Status != "saved" || resp.Session.ID != session.ID || resp.Session.Success != storage.SuccessLevelGreat {
+		t.Fatalf("repeat response = %+v, want the saved record", resp)
+	}
+
+	var history []storage.SessionRecord
+	s.do("alice", http.MethodGet, "/history", "", &history)
+	if len(history) != 1 {
+		t.Fatalf("history has %d records, want 1", len(history))
+	}
+
+	if code := s.do("alice", http.MethodPost, path, `{"success":"fail","comment":"calm"}`, nil); code != http.StatusConflict {
+		t.Fatalf("different payload status = %d, want %d", code, http.StatusConflict)
+	}
+	if code := s.do("bob", http.MethodPost, path, body, nil); code != http.StatusNotFound {
+		t.Fatalf("outsider status = %d, want %d", code, http.StatusNotFound)
+	}
+
+	// repeats still succeed once the session has left the manager
+	if err := s.manager.StopSession(context.Background(), session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if code := s.do("alice", http.MethodPost, path, body, nil); code != http.StatusOK {
+		t.Fatalf("repeat after eviction status = %d, want %d", code, http.StatusOK)
+	}
+}
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hperssn/hound/internal/domain"
+	httpapi "github.com/hperssn/hound/internal/http"
+	"github.com/hperssn/hound/internal/logging"
+	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+const (
+	ShareLinkKey contextKey = "shareLink"
+
+	defaultShareTTL = 24 * time.Hour
+	maxShareTTL     = 7 * 24 * time.Hour
+
+	// shareRecheckInterval is the default for Config.ShareRecheckInterval.
+	shareRecheckInterval = 30 * time.Second
+)
+
+var ErrShareLinkInvalid = errors.New("invalid or expired share link")
+
+// ShareTokens issues and validates the signed tokens embedded in share
+// links. The signature lets forged tokens be rejected without touching the
+// database; revocation is checked against the repository.
+type ShareTokens struct {
+	signer *signer
+	repo   storage.Repository
+}
+
+type shareToken struct {
+	LinkID    string `json:"l"`
+	SessionID string `json:"s"`
+	Expires   int64  `json:"e"`
+}
+
+func NewShareTokens(secret []byte, repo storage.Repository) *ShareTokens {
+	return &ShareTokens{signer: newSigner(secret, purposeShare), repo: repo}
+}
+
+func (t *ShareTokens) Issue(link *domain.ShareLink) (string, error) {
+	payload, err := json.Marshal(shareToken{
+		LinkID:    link.ID,
+		SessionID: link.SessionID,
+		Expires:   link.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return t.signer.Sign(payload), nil
+}
+
+func (t *ShareTokens) Validate(ctx context.Context, token string) (*domain.ShareLink, error) {
+	payload, err := t.signer.Verify(token)
+	if err != nil {
+		return nil, ErrShareLinkInvalid
+	}
+
+	var st shareToken
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return nil, ErrShareLinkInvalid
+	}
+
+	now := time.Now()
+	if now.Unix() > st.Expires {
+		return nil, ErrShareLinkInvalid
+	}
+
+	link, err := t.repo.GetShareLink(ctx, st.LinkID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrShareLinkInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if link.SessionID != st.SessionID || !link.Active(now) {
+		return nil, ErrShareLinkInvalid
+	}
+	return link, nil
+}
+
+// Middleware authenticates requests carrying a share token in the URL.
+// It is deliberately separate from the user middlewares: a share link
+// never yields a user ID, only access to the linked session.
+func (t *ShareTokens) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		link, err := t.Validate(r.Context(), chi.URLParam(r, "token"))
+		if errors.Is(err, ErrShareLinkInvalid) {
+			respondError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to validate share link", "err", err)
+			respondError(w, "failed to validate share link", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := logging.With(r.Context(), slog.String(logging.SessionIDKey, link.SessionID))
+		ctx = context.WithValue(ctx, ShareLinkKey, link)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// StillValid reports whether the request's share link may still be used.
+// Streams opened with the link check it periodically.
+func (t *ShareTokens) StillValid(r *http.Request) bool {
+	_, err := t.Validate(r.Context(), chi.URLParam(r, "token"))
+	if err != nil && !errors.Is(err, ErrShareLinkInvalid) {
+		slog.ErrorContext(r.Context(), "failed to revalidate share link", "err", err)
+	}
+	return err == nil
+}
+
+func GetShareLink(r *http.Request) *domain.ShareLink {
+	link, _ := r.Context().Value(ShareLinkKey).(*domain.ShareLink)
+	return link
+}
+
+// sharedSession resolves the session granted by the request's share link.
+func sharedSession(w http.ResponseWriter, r *http.Request) (string, bool) {
+	link := GetShareLink(r)
+	if link == nil {
+		respondError(w, "session not found", http.StatusNotFound)
+		return "", false
+	}
+	return link.SessionID, true
+}
+
+// memberSession resolves the session in the URL for a household member.
+func memberSession(m *runner.SessionManager) httpapi.SessionResolver {
+	return func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		session, ok := authorizedSession(w, r, m, domain.Role.CanView)
+		if !ok {
+			return "", false
+		}
+		return session.ID, true
+	}
+}
+
+// sharedSessionView is what a share link shows of a session: its progress,
+// without the user and household it belongs to. Fields keep the names
+// domain.Session is encoded with, so clients read both alike.
+type sharedSessionView struct {
+	ID          string
+	TargetSec   int
+	Steps       []domain.Step
+	CurrentIdx  int
+	StartedAt   time.Time
+	State       domain.State
+	Completed   bool
+	AutoAdvance *domain.AutoAdvance
+	PlanChanges []domain.PlanChange
+}
+
+func newSharedSessionView(s *domain.Session) sharedSessionView {
+	return sharedSessionView{
+		ID:          s.ID,
+		TargetSec:   s.TargetSec,
+		Steps:       s.Steps,
+		CurrentIdx:  s.CurrentIdx,
+		StartedAt:   s.StartedAt,
+		State:       s.State,
+		Completed:   s.Completed,
+		AutoAdvance: s.AutoAdvance,
+		PlanChanges: s.PlanChanges,
+	}
+}
+
+func getSharedSession(m *runner.SessionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := sharedSession(w, r)
+		if !ok {
+			return
+		}
+
+		session, ok := m.GetSession(id)
+		if !ok {
+			respondError(w, "session not found", http.StatusNotFound)
+			return
+		}
+
+		respondJSON(w, newSharedSessionView(session), http.StatusOK)
+	}
+}
+
+func createShareLink(m *runner.SessionManager, repo storage.Repository, tokens *ShareTokens) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			TTLMinutes int `json:"ttlMinutes"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				respondError(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		ttl := defaultShareTTL
+		if req.TTLMinutes > 0 {
+			ttl = min(time.Duration(req.TTLMinutes)*time.Minute, maxShareTTL)
+		}
+
+		session, ok := authorizedSession(w, r, m, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+
+		link := domain.NewShareLink(session, GetUserId(r), ttl)
+		if err := repo.CreateShareLink(r.Context(), link); err != nil {
+			slog.ErrorContext(r.Context(), "failed to create share link", "err", err)
+			respondError(w, "failed to create share link", http.StatusInternalServerError)
+			return
+		}
+
+		token, err := tokens.Issue(link)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to sign share link", "err", err)
+			respondError(w, "failed to create share link", http.StatusInternalServerError)
+			return
+		}
+
+		resp := struct {
+			*domain.ShareLink
+			Token string `json:"token"`
+			URL   string `json:"url"`
+		}{
+			ShareLink: link,
+			Token:     token,
+			URL:       "/shared/" + token,
+		}
+		respondJSON(w, resp, http.StatusCreated)
+	}
+}
+
+func listShareLinks(m *runner.SessionManager, repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := authorizedSession(w, r, m, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+
+		links, err := repo.GetShareLinks(r.Context(), session.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get share links", "err", err)
+			respondError(w, "failed to retrieve share links", http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(w, links, http.StatusOK)
+	}
+}
+
+func revokeShareLink(m *runner.SessionManager, repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := authorizedSession(w, r, m, domain.Role.CanTrain)
+		if !ok {
+			return
+		}
+
+		link, err := repo.GetShareLink(r.Context(), chi.URLParam(r, "shareId"))
+		if err != nil || link.SessionID != session.ID {
+			respondError(w, "share link not found", http.StatusNotFound)
+			return
+		}
+
+		if err := repo.RevokeShareLink(r.Context(), link.ID, time.Now()); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "failed to revoke share link", "err", err)
+			respondError(w, "failed to revoke share link", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
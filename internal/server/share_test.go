@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+// newShareServer returns a test server backed by SQLite whose share link
+// streams recheck their links quickly, and the tokens it accepts.
+func newShareServer(t *testing.T) (*testServer, *ShareTokens) {
+	t.Helper()
+
+	repo, err := storage.NewSQLiteRepository(filepath.Join(t.TempDir(), "hound.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	s := newTestServer(t, withRepo(repo), withConfig(func(cfg *Config) {
+		cfg.ShareRecheckInterval = 10 * time.Millisecond
+	}))
+	return s, NewShareTokens([]byte(testSecret), repo)
+}
+
+func TestShareLinkGrantsReadOnlyAccess(t *testing.T) {
+	s, _ := newShareServer(t)
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+
+	var share struct {
+		ID    string `json:"id"`
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}
+	if code := s.do("alice", http.MethodPost, "/sessions/"+session.ID+"/shares", `{"ttlMinutes":30}`, &share); code != http.StatusCreated {
+		t.Fatalf("create share status = %d", code)
+	}
+
+	var shared map[string]any
+	if code := s.do("", http.MethodGet, share.URL+"/", "", &shared); code != http.StatusOK {
+		t.Fatalf("shared session status = %d", code)
+	}
+	if shared["ID"] != session.ID || shared["TargetSec"] != float64(60) {
+		t.Fatalf("shared session = %+v, want %s", shared, session.ID)
+	}
+	for _, key := range []string{"UserID", "HouseholdID"} {
+		if _, ok := shared[key]; ok {
+			t.Errorf("shared session includes %s: %+v", key, shared)
+		}
+	}
+
+	if code := s.do("", http.MethodPost, share.URL+"/", "", nil); code != http.StatusMethodNotAllowed {
+		t.Fatalf("write through share link status = %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+
+	tampered := share.Token[:len(share.Token)-2] + "xx"
+	if code := s.do("", http.MethodGet, "/shared/"+tampered+"/", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("tampered token status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	if code := s.do("bob", http.MethodDelete, "/sessions/"+session.ID+"/shares/"+share.ID, "", nil); code != http.StatusNotFound {
+		t.Fatalf("foreign revoke status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := s.do("alice", http.MethodDelete, "/sessions/"+session.ID+"/shares/"+share.ID, "", nil); code != http.StatusNoContent {
+		t.Fatalf("revoke status = %d", code)
+	}
+	if code := s.do("", http.MethodGet, share.URL+"/", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("revoked token status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestShareLinkExpires(t *testing.T) {
+	s, shares := newShareServer(t)
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+
+	link := domain.NewShareLink(&session, "alice", -time.Minute)
+	if err := s.repo.CreateShareLink(context.Background(), link); err != nil {
+		t.Fatal(err)
+	}
+	token, err := shares.Issue(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := s.do("", http.MethodGet, "/shared/"+token+"/", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("expired token status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+// openStream opens a share link's event stream and returns a channel
+// closed once the server ends it.
+func openStream(t *testing.T, s *testServer, token string) <-chan struct{} {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.url+"/shared/"+token+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("events status = %d", resp.StatusCode)
+	}
+
+	ended := make(chan struct{})
+	go func() {
+		defer close(ended)
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+	}()
+	return ended
+}
+
+func TestShareLinkStreamEnds(t *testing.T) {
+	s, shares := newShareServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		end  func(session *domain.Session, link *domain.ShareLink)
+	}{
+		{"revoked", func(_ *domain.Session, link *domain.ShareLink) {
+			s.repo.RevokeShareLink(ctx, link.ID, time.Now())
+		}},
+		{"session stopped", func(session *domain.Session, _ *domain.ShareLink) {
+			s.manager.StopSession(ctx, session.ID)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var session domain.Session
+			s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+			link := domain.NewShareLink(&session, "alice", time.Hour)
+			if err := s.repo.CreateShareLink(ctx, link); err != nil {
+				t.Fatal(err)
+			}
+			token, _ := shares.Issue(link)
+
+			ended := openStream(t, s, token)
+			select {
+			case <-ended:
+				t.Fatal("stream ended before access did")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			tt.end(&session, link)
+			select {
+			case <-ended:
+			case <-time.After(time.Second):
+				t.Fatal("stream still open")
+			}
+		})
+	}
+}
+
+func TestShareTokensRejectOtherSignatures(t *testing.T) {
+	s, shares := newShareServer(t)
+	ctx := context.Background()
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+	link := domain.NewShareLink(&session, "alice", time.Hour)
+	if err := s.repo.CreateShareLink(ctx, link); err != nil {
+		t.Fatal(err)
+	}
+	token, _ := shares.Issue(link)
+	if _, err := shares.Validate(ctx, token); err != nil {
+		t.Fatalf("share token: %v", err)
+	}
+
+	// the same payload signed with the same secret for login cookies
+	payload, _, _ := strings.Cut(token, ".")
+	decoded, _ := base64.RawURLEncoding.DecodeString(payload)
+	cookie := newSigner([]byte(testSecret), purposeSession).Sign(decoded)
+	if _, err := shares.Validate(ctx, cookie); err != ErrShareLinkInvalid {
+		t.Fatalf("token signed for login cookies err = %v, want %v", err, ErrShareLinkInvalid)
+	}
+}
+
+func TestShareLinksWithoutSecret(t *testing.T) {
+	s := newTestServer(t, withConfig(func(cfg *Config) { cfg.ShareSecret = nil }))
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+	var share struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	s.do("alice", http.MethodPost, "/sessions/"+session.ID+"/shares", "", &share)
+
+	if code := s.do("", http.MethodGet, share.URL+"/", "", nil); code != http.StatusOK {
+		t.Fatalf("shared session status = %d, want %d", code, http.StatusOK)
+	}
+	forged := newSigner(nil, purposeShare).Sign([]byte(`{"l":"` + share.ID + `","s":"` + session.ID + `","e":9999999999}`))
+	if code := s.do("", http.MethodGet, "/shared/"+forged+"/", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("token signed with an empty key status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
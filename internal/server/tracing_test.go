@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+func TestCompleteSessionIsTraced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	sqlite, err := storage.NewSQLiteRepository(filepath.Join(t.TempDir(), "hound.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	if err := sqlite.CreateHousehold(context.Background(), domain.PersonalHousehold("alice"), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	repo := storage.Trace(sqlite, tp)
+
+	s := newTestServer(t,
+		withRepo(repo),
+		withManagerOptions(runner.WithTracerProvider(tp)),
+		withConfig(func(cfg *Config) { cfg.TracerProvider = tp }),
+	)
+	session := domain.NewSession("", "alice", 60)
+	if err := s.manager.StartSession(context.Background(), session); err != nil {
+		t.Fatal(err)
+	}
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/sessions/"+session.ID+"/complete", strings.NewReader(`{"success":"great"}`))
+	req.Header.Set("X-Auth-User", "alice")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	// served in process so the server span has ended once this returns
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+
+	server, ok := spans["POST /sessions/{id}/complete"]
+	if !ok {
+		t.Fatalf("no server span named after the route; got %v", names(spans))
+	}
+	complete, ok := spans["SessionManager.CompleteSession"]
+	if !ok {
+		t.Fatalf("no manager span; got %v", names(spans))
+	}
+	save, ok := spans["Repository.SaveSession"]
+	if !ok {
+		t.Fatalf("no repository span; got %v", names(spans))
+	}
+
+	for _, s := range []sdktrace.ReadOnlySpan{server, complete, save} {
+		if got := s.SpanContext().TraceID().String(); got != traceID {
+			t.Errorf("span %q has trace %s, want the caller's %s", s.Name(), got, traceID)
+		}
+	}
+	if complete.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("manager span is not a child of the server span")
+	}
+	if save.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("repository span is not a child of the server span")
+	}
+}
+
+func names(spans map[string]sdktrace.ReadOnlySpan) []string {
+	var out []string
+	for name := range spans {
+		out = append(out, name)
+	}
+	return out
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/storage"
+	"github.com/hperssn/hound/internal/webhook"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+func listWebhooks(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		hooks, err := repo.GetWebhooks(r.Context(), userId)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list webhooks", "err", err)
+			respondError(w, "failed to list webhooks", http.StatusInternalServerError)
+			return
+		}
+		if hooks == nil {
+			hooks = []domain.Webhook{}
+		}
+		respondJSON(w, hooks, http.StatusOK)
+	}
+}
+
+// createWebhook registers a URL for the user's session events. The signing
+// secret is only returned here.
+func createWebhook(repo storage.Repository, hooks *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			URL    string                `json:"url"`
+			Events []domain.WebhookEvent `json:"events"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		hook, err := domain.NewWebhook(userId, req.URL, req.Events)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := hooks.CheckURL(hook.URL); err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := repo.CreateWebhook(r.Context(), hook); err != nil {
+			slog.ErrorContext(r.Context(), "failed to create webhook", "err", err)
+			respondError(w, "failed to create webhook", http.StatusInternalServerError)
+			return
+		}
+
+		resp := struct {
+			*domain.Webhook
+			Secret string `json:"secret"`
+		}{hook, hook.Secret}
+		respondJSON(w, resp, http.StatusCreated)
+	}
+}
+
+func deleteWebhook(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := GetUserId(r)
+		if userId == "" {
+			respondError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		err := repo.DeleteWebhook(r.Context(), chi.URLParam(r, "webhookId"), userId)
+		if errors.Is(err, storage.ErrNotFound) {
+			respondError(w, "webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete webhook", "err", err)
+			respondError(w, "failed to delete webhook", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listWebhookDeliveries returns the webhook's latest delivery attempts,
+// newest first.
+func listWebhookDeliveries(repo storage.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hook, ok := ownWebhook(w, r, repo)
+		if !ok {
+			return
+		}
+
+		limit := defaultDeliveryLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxDeliveryLimit {
+				respondError(w, "limit must be between 1 and "+strconv.Itoa(maxDeliveryLimit), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		deliveries, err := repo.GetWebhookDeliveries(r.Context(), hook.ID, limit)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list webhook deliveries", "err", err)
+			respondError(w, "failed to list webhook deliveries", http.StatusInternalServerError)
+			return
+		}
+		if deliveries == nil {
+			deliveries = []domain.WebhookDelivery{}
+		}
+		respondJSON(w, deliveries, http.StatusOK)
+	}
+}
+
+// testWebhook sends the webhook a ping and answers with the attempt, so
+// users can check their receiver. A receiver failing is not an error here.
+func testWebhook(repo storage.Repository, hooks *webhook.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hook, ok := ownWebhook(w, r, repo)
+		if !ok {
+			return
+		}
+
+		delivery, err := hooks.Test(r.Context(), *hook)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to test webhook", "err", err)
+			respondError(w, "failed to test webhook", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, delivery, http.StatusOK)
+	}
+}
+
+// ownWebhook loads the {webhookId} webhook of the requesting user. On
+// failure it writes the response and returns false.
+func ownWebhook(w http.ResponseWriter, r *http.Request, repo storage.Repository) (*domain.Webhook, bool) {
+	userId := GetUserId(r)
+	if userId == "" {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	hook, err := repo.GetWebhook(r.Context(), chi.URLParam(r, "webhookId"), userId)
+	if errors.Is(err, storage.ErrNotFound) {
+		respondError(w, "webhook not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get webhook", "err", err)
+		respondError(w, "failed to get webhook", http.StatusInternalServerError)
+		return nil, false
+	}
+	return hook, true
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/webhook"
+)
+
+func TestServerWebhooks(t *testing.T) {
+	s := newTestServer(t)
+
+	var secret string
+	var mu sync.Mutex
+	events := make(chan domain.WebhookEvent, 10)
+	status := http.StatusOK
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if err := webhook.Verify(secret, r.Header.Get(webhook.SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			t.Errorf("delivery signature: %v", err)
+		}
+		var p webhook.Payload
+		json.Unmarshal(body, &p)
+		events <- p.Event
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	for _, body := range []string{
+		`{"url":"ftp://hooks.example"}`,
+		`{"url":"http://169.254.169.254/latest/meta-data"}`,
+		`{"url":"http://10.0.0.1:8123/hook"}`,
+		`{"url":"` + receiver.URL + `","events":["session.exploded"]}`,
+	} {
+		if code := s.do("alice", http.MethodPost, "/webhooks", body, nil); code != http.StatusBadRequest {
+			t.Fatalf("create %s status = %d, want %d", body, code, http.StatusBadRequest)
+		}
+	}
+
+	var created struct {
+		domain.Webhook
+		Secret string `json:"secret"`
+	}
+	body := `{"url":"` + receiver.URL + `","events":["session.started","session.saved"]}`
+	if code := s.do("alice", http.MethodPost, "/webhooks", body, &created); code != http.StatusCreated {
+		t.Fatalf("create status = %d", code)
+	}
+	if created.ID == "" || created.UserID != "alice" || !strings.HasPrefix(created.Secret, "whsec_") {
+		t.Fatalf("created = %+v", created)
+	}
+	mu.Lock()
+	secret = created.Secret
+	mu.Unlock()
+
+	var listed []map[string]any
+	s.do("alice", http.MethodGet, "/webhooks", "", &listed)
+	if len(listed) != 1 || listed[0]["id"] != created.ID {
+		t.Fatalf("alice's webhooks = %+v", listed)
+	}
+	if _, ok := listed[0]["secret"]; ok {
+		t.Fatal("listed webhook includes its secret")
+	}
+	s.do("bob", http.MethodGet, "/webhooks", "", &listed)
+	if len(listed) != 0 {
+		t.Fatalf("bob's webhooks = %+v, want none", listed)
+	}
+
+	path := "/webhooks/" + created.ID
+	mu.Lock()
+	status = http.StatusInternalServerError
+	mu.Unlock()
+	var delivery domain.WebhookDelivery
+	if code := s.do("alice", http.MethodPost, path+"/test", "", &delivery); code != http.StatusOK {
+		t.Fatalf("test status = %d", code)
+	}
+	if delivery.Event != domain.WebhookPing || delivery.Success || delivery.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("test delivery = %+v, want a failed ping", delivery)
+	}
+	if ev := <-events; ev != domain.WebhookPing {
+		t.Fatalf("receiver got %q, want ping", ev)
+	}
+	if code := s.do("bob", http.MethodPost, path+"/test", "", nil); code != http.StatusNotFound {
+		t.Fatalf("testing another user's webhook status = %d, want %d", code, http.StatusNotFound)
+	}
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+
+	var session domain.Session
+	s.do("alice", http.MethodPost, "/sessions", `{"targetSec":60}`, &session)
+	if code := s.do("alice", http.MethodPost, "/sessions/"+session.ID+"/complete", `{"success":"great"}`, nil); code != http.StatusOK {
+		t.Fatalf("complete status = %d", code)
+	}
+	for _, want := range []domain.WebhookEvent{domain.WebhookSessionStarted, domain.WebhookSessionSaved} {
+		select {
+		case ev := <-events:
+			if ev != want {
+				t.Fatalf("receiver got %q, want %q", ev, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("receiver did not get %q", want)
+		}
+	}
+
+	var deliveries []domain.WebhookDelivery
+	deadline := time.Now().Add(3 * time.Second)
+	for len(deliveries) < 3 && time.Now().Before(deadline) {
+		s.do("alice", http.MethodGet, path+"/deliveries", "", &deliveries)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(deliveries) != 3 || deliveries[2].Event != domain.WebhookPing || !deliveries[0].Success {
+		t.Fatalf("deliveries = %+v, want the ping and two events", deliveries)
+	}
+	if code := s.do("alice", http.MethodGet, path+"/deliveries?limit=0", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("deliveries with limit 0 status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := s.do("alice", http.MethodGet, path+"/deliveries?limit=1", "", &deliveries); code != http.StatusOK || len(deliveries) != 1 {
+		t.Fatalf("deliveries with limit 1 status = %d, got %d", code, len(deliveries))
+	}
+
+	if code := s.do("bob", http.MethodDelete, path, "", nil); code != http.StatusNotFound {
+		t.Fatalf("delete another user's webhook status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := s.do("alice", http.MethodDelete, path, "", nil); code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := s.do("alice", http.MethodGet, path+"/deliveries", "", nil); code != http.StatusNotFound {
+		t.Fatalf("deliveries of a deleted webhook status = %d, want %d", code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,832 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+)
+
+// The conformance suite runs every Repository implementation through the
+// same checks, so the memory repository used by handler tests behaves like
+// the databases used in production. Postgres is included when
+// HOUND_TEST_POSTGRES_DSN names a database the suite may wipe.
+
+type repoFactory func(t *testing.T) Repository
+
+func repoFactories() map[string]repoFactory {
+	factories := map[string]repoFactory{
+		"memory": func(t *testing.T) Repository {
+			return NewMemoryRepository()
+		},
+		"sqlite": func(t *testing.T) Repository {
+			repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "hound.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+	}
+
+	if dsn := os.Getenv("HOUND_TEST_POSTGRES_DSN"); dsn != "" {
+		factories["postgres"] = func(t *testing.T) Repository {
+			repo, err := NewPostgresRepository(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { repo.Close() })
+
+			_, err = repo.db.Exec(`TRUNCATE sessions, session_changes, households, household_members, household_invitations, share_links, session_checkpoints, reminders, push_subscriptions, webhooks, webhook_deliveries`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return repo
+		}
+	}
+	return factories
+}
+
+func TestRepositoryConformance(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo Repository)
+	}{
+		{"sessions", testSessions},
+		{"session stats", testSessionStats},
+		{"session edits", testSessionEdits},
+		{"households", testHouseholds},
+		{"invitations", testInvitations},
+		{"share links", testShareLinks},
+		{"reminders", testReminders},
+		{"push subscriptions", testPushSubscriptions},
+		{"webhooks", testWebhooks},
+		{"checkpoints", testCheckpoints},
+		{"ping", testPing},
+	}
+
+	for name, newRepo := range repoFactories() {
+		t.Run(name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, newRepo(t))
+				})
+			}
+		})
+	}
+}
+
+var base = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func record(id, householdID string, success SuccessLevel, targetSec int, completedAt time.Time) *SessionRecord {
+	return &SessionRecord{
+		ID:          id,
+		UserID:      "alice",
+		HouseholdID: householdID,
+		TargetSec:   targetSec,
+		Success:     success,
+		Comment:     "comment " + id,
+		StartedAt:   completedAt.Add(-time.Duration(targetSec) * time.Second),
+		CompletedAt: completedAt,
+		Steps: []StepRecord{
+			{SessionID: id, Index: 0, Duration: targetSec, ActualSec: targetSec, Completed: true},
+		},
+	}
+}
+
+func sessionIDs(records []SessionRecord) []string {
+	var ids []string
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func equalIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testSessions(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	edited := record("new", "h1", SuccessLevelGreat, 90, base)
+	edited.PlanChanges = []domain.PlanChange{
+		{Action: domain.PlanStepResized, Index: 0, Duration: 90, PreviousDuration: 120, ChangedAt: base.Add(-time.Minute)},
+	}
+
+	for _, r := range []*SessionRecord{
+		record("old", "h1", SuccessLevelOK, 60, base.Add(-48*time.Hour)),
+		edited,
+		record("mid", "h1", SuccessLevelFail, 30, base.Add(-time.Hour)),
+		record("other", "h2", SuccessLevelOK, 60, base),
+	} {
+		if err := repo.SaveSession(ctx, r); err != nil {
+			t.Fatalf("SaveSession(%s): %v", r.ID, err)
+		}
+	}
+
+	if err := repo.SaveSession(ctx, record("new", "h1", SuccessLevelOK, 60, base)); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("saving a duplicate session ID: err = %v, want ErrDuplicate", err)
+	}
+
+	all, err := repo.GetSessionsByHousehold(ctx, "h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(all); !equalIDs(got, []string{"new", "mid", "old"}) {
+		t.Fatalf("GetSessionsByHousehold = %v, want newest first", got)
+	}
+
+	// the duplicate save left the first record untouched
+	got := all[0]
+	want := record("new", "h1", SuccessLevelGreat, 90, base)
+	if got.UserID != want.UserID || got.TargetSec != want.TargetSec || got.Success != want.Success ||
+		got.Comment != want.Comment || !got.StartedAt.Equal(want.StartedAt) || !got.CompletedAt.Equal(want.CompletedAt) {
+		t.Errorf("round trip = %+v, want %+v", got, *want)
+	}
+	if len(got.Steps) != 1 || got.Steps[0] != want.Steps[0] {
+		t.Errorf("steps = %+v, want %+v", got.Steps, want.Steps)
+	}
+	if len(got.PlanChanges) != 1 || got.PlanChanges[0].Action != domain.PlanStepResized ||
+		got.PlanChanges[0].PreviousDuration != 120 || !got.PlanChanges[0].ChangedAt.Equal(edited.PlanChanges[0].ChangedAt) {
+		t.Errorf("plan changes = %+v, want %+v", got.PlanChanges, edited.PlanChanges)
+	}
+	if len(all[1].PlanChanges) != 0 {
+		t.Errorf("unedited session plan changes = %+v, want none", all[1].PlanChanges)
+	}
+
+	recent, err := repo.GetRecentSessions(ctx, "h1", base.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(recent); !equalIDs(got, []string{"new", "mid"}) {
+		t.Fatalf("GetRecentSessions = %v, want sessions since the cutoff, inclusive", got)
+	}
+
+	none, err := repo.GetSessionsByHousehold(ctx, "empty")
+	if err != nil || len(none) != 0 {
+		t.Fatalf("unknown household = %v, %v; want no sessions", none, err)
+	}
+}
+
+func testSessionStats(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	empty, err := repo.GetSessionStats(ctx, "h1")
+	if err != nil {
+		t.Fatalf("stats without sessions: %v", err)
+	}
+	if *empty != (SessionStats{}) {
+		t.Fatalf("stats without sessions = %+v, want zero", *empty)
+	}
+
+	for _, r := range []*SessionRecord{
+		record("a", "h1", SuccessLevelGreat, 60, base),
+		record("b", "h1", SuccessLevelOK, 90, base.Add(time.Minute)),
+		record("c", "h1", SuccessLevelFail, 30, base.Add(2*time.Minute)),
+		record("d", "h1", SuccessLevelFail, 20, base.Add(3*time.Minute)),
+		// abandoned sessions do not lower the success rate
+		record("f", "h1", SuccessLevelAbandoned, 500, base.Add(4*time.Minute)),
+		record("e", "h2", SuccessLevelGreat, 1000, base),
+	} {
+		if err := repo.SaveSession(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := repo.GetSessionStats(ctx, "h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SessionStats{
+		TotalSessions:   4,
+		SuccessfulCount: 2,
+		AbandonedCount:  1,
+		AverageTarget:   50,
+		TotalTrainTime:  200,
+		SuccessRate:     50,
+	}
+	if *stats != want {
+		t.Fatalf("stats = %+v, want %+v", *stats, want)
+	}
+}
+
+func testSessionEdits(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	for _, r := range []*SessionRecord{
+		record("kept", "h1", SuccessLevelOK, 60, base),
+		record("edited", "h1", SuccessLevelFail, 100, base.Add(time.Minute)),
+	} {
+		if err := repo.SaveSession(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	great := SuccessLevelGreat
+	comment := "mis-clicked"
+	same := "comment edited"
+	updated, err := repo.UpdateSession(ctx, "edited", SessionEdit{Success: &great, Comment: &same}, "bob", base.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Success != SuccessLevelGreat || updated.Comment != same {
+		t.Fatalf("updated = %+v", updated)
+	}
+	if _, err := repo.UpdateSession(ctx, "edited", SessionEdit{Comment: &comment}, "bob", base.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// an edit that changes nothing leaves no trace
+	if _, err := repo.UpdateSession(ctx, "edited", SessionEdit{Success: &great}, "bob", base.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateSession(ctx, "missing", SessionEdit{Success: &great}, "bob", base); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("editing unknown session: err = %v, want ErrNotFound", err)
+	}
+
+	got, err := repo.GetSession(ctx, "edited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Success != SuccessLevelGreat || got.Comment != comment || got.DeletedAt != nil || len(got.Steps) != 1 {
+		t.Fatalf("GetSession = %+v", got)
+	}
+	if _, err := repo.GetSession(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown session: err = %v, want ErrNotFound", err)
+	}
+
+	stats, err := repo.GetSessionStats(ctx, "h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.SuccessfulCount != 2 {
+		t.Fatalf("stats after edit = %+v, want both sessions successful", *stats)
+	}
+
+	deletedAt := base.Add(4 * time.Hour)
+	if err := repo.DeleteSession(ctx, "edited", "alice", deletedAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteSession(ctx, "edited", "alice", deletedAt); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleting twice: err = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.UpdateSession(ctx, "edited", SessionEdit{Comment: &comment}, "bob", deletedAt); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("editing deleted session: err = %v, want ErrNotFound", err)
+	}
+
+	all, err := repo.GetSessionsByHousehold(ctx, "h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(all); !equalIDs(got, []string{"kept"}) {
+		t.Fatalf("history after delete = %v, want only kept", got)
+	}
+	recent, err := repo.GetRecentSessions(ctx, "h1", base.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(recent); !equalIDs(got, []string{"kept"}) {
+		t.Fatalf("recent after delete = %v, want only kept", got)
+	}
+	stats, err = repo.GetSessionStats(ctx, "h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalSessions != 1 || stats.TotalTrainTime != 60 {
+		t.Fatalf("stats after delete = %+v, want only kept", *stats)
+	}
+
+	got, err = repo.GetSession(ctx, "edited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DeletedAt == nil || !got.DeletedAt.Equal(deletedAt) {
+		t.Fatalf("DeletedAt = %v, want %v", got.DeletedAt, deletedAt)
+	}
+
+	if err := repo.RestoreSession(ctx, "edited", "alice", base.Add(5*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RestoreSession(ctx, "edited", "alice", base.Add(5*time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("restoring twice: err = %v, want ErrNotFound", err)
+	}
+	all, _ = repo.GetSessionsByHousehold(ctx, "h1")
+	if got := sessionIDs(all); !equalIDs(got, []string{"edited", "kept"}) {
+		t.Fatalf("history after restore = %v", got)
+	}
+
+	changes, err := repo.GetSessionChanges(ctx, "edited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, c := range changes {
+		actions = append(actions, string(c.Action))
+	}
+	if !equalIDs(actions, []string{"edited", "edited", "deleted", "restored"}) {
+		t.Fatalf("audit trail = %v", actions)
+	}
+
+	first := changes[0]
+	if first.UserID != "bob" || !first.ChangedAt.Equal(base.Add(time.Hour)) ||
+		first.Before.Success == nil || *first.Before.Success != SuccessLevelFail ||
+		first.After.Success == nil || *first.After.Success != SuccessLevelGreat {
+		t.Fatalf("first change = %+v", first)
+	}
+	// the comment was already "comment edited", so only success changed
+	if first.Before.Comment != nil || first.After.Comment != nil {
+		t.Fatalf("unchanged comment recorded: %+v", first.After)
+	}
+	if second := changes[1]; second.Before.Success != nil || second.After.Comment == nil || *second.After.Comment != comment {
+		t.Fatalf("second change = %+v", second)
+	}
+
+	none, err := repo.GetSessionChanges(ctx, "kept")
+	if err != nil || len(none) != 0 {
+		t.Fatalf("untouched session changes = %v, %v", none, err)
+	}
+}
+
+func testHouseholds(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	first := &domain.Household{ID: "h1", Name: "Rex", CreatedBy: "alice", CreatedAt: base}
+	second := &domain.Household{ID: "h2", Name: "Fido", CreatedBy: "bob", CreatedAt: base.Add(time.Hour)}
+	if err := repo.CreateHousehold(ctx, second, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateHousehold(ctx, first, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// creating an existing household is a no-op and keeps its owner
+	if err := repo.CreateHousehold(ctx, &domain.Household{ID: "h1", Name: "Other", CreatedAt: base}, "mallory"); err != nil {
+		t.Fatalf("recreating household: %v", err)
+	}
+	if _, err := repo.GetMemberRole(ctx, "h1", "mallory"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("recreating household added its owner: err = %v", err)
+	}
+
+	role, err := repo.GetMemberRole(ctx, "h1", "alice")
+	if err != nil || role != domain.RoleOwner {
+		t.Fatalf("creator role = %q, %v; want owner", role, err)
+	}
+
+	if err := repo.SetMemberRole(ctx, "h2", "alice", domain.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	memberships, err := repo.GetHouseholdsForUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memberships) != 2 || memberships[0].ID != "h1" || memberships[1].ID != "h2" {
+		t.Fatalf("memberships = %+v, want h1 then h2 by creation time", memberships)
+	}
+	if memberships[0].Name != "Rex" || memberships[0].Role != domain.RoleOwner || memberships[1].Role != domain.RoleViewer {
+		t.Fatalf("memberships = %+v", memberships)
+	}
+
+	// changing a role keeps the member's position
+	if err := repo.SetMemberRole(ctx, "h2", "alice", domain.RoleTrainer); err != nil {
+		t.Fatal(err)
+	}
+	members, err := repo.GetMembers(ctx, "h2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members[0].UserID != "bob" || members[1].UserID != "alice" || members[1].Role != domain.RoleTrainer {
+		t.Fatalf("members = %+v, want bob then alice (trainer)", members)
+	}
+
+	if err := repo.RemoveMember(ctx, "h2", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RemoveMember(ctx, "h2", "alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("removing twice: err = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.GetMemberRole(ctx, "h2", "alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("removed member role: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testInvitations(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	if err := repo.CreateHousehold(ctx, &domain.Household{ID: "h1", Name: "Rex", CreatedBy: "alice", CreatedAt: base}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	valid := &domain.Invitation{Token: "valid", HouseholdID: "h1", Role: domain.RoleTrainer, CreatedBy: "alice", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	expired := &domain.Invitation{Token: "expired", HouseholdID: "h1", Role: domain.RoleViewer, CreatedBy: "alice", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)}
+	for _, inv := range []*domain.Invitation{valid, expired} {
+		if err := repo.CreateInvitation(ctx, inv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := repo.GetInvitation(ctx, "valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HouseholdID != "h1" || got.Role != domain.RoleTrainer || got.AcceptedBy != "" || !got.ExpiresAt.Equal(valid.ExpiresAt) {
+		t.Fatalf("invitation = %+v", got)
+	}
+	if _, err := repo.GetInvitation(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown invitation: err = %v, want ErrNotFound", err)
+	}
+
+	if err := repo.AcceptInvitation(ctx, "valid", "sam"); err != nil {
+		t.Fatal(err)
+	}
+	if role, err := repo.GetMemberRole(ctx, "h1", "sam"); err != nil || role != domain.RoleTrainer {
+		t.Fatalf("accepted role = %q, %v; want trainer", role, err)
+	}
+	if got, _ := repo.GetInvitation(ctx, "valid"); got.AcceptedBy != "sam" {
+		t.Fatalf("AcceptedBy = %q, want sam", got.AcceptedBy)
+	}
+
+	tests := []struct {
+		token string
+		want  error
+	}{
+		{"valid", ErrInvitationUnusable},
+		{"expired", ErrInvitationUnusable},
+		{"missing", ErrNotFound},
+	}
+	for _, tt := range tests {
+		if err := repo.AcceptInvitation(ctx, tt.token, "bob"); !errors.Is(err, tt.want) {
+			t.Errorf("accept %s: err = %v, want %v", tt.token, err, tt.want)
+		}
+	}
+
+	// accepting keeps an existing member's role
+	owner := &domain.Invitation{Token: "owner", HouseholdID: "h1", Role: domain.RoleViewer, CreatedBy: "alice", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := repo.CreateInvitation(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AcceptInvitation(ctx, "owner", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if role, _ := repo.GetMemberRole(ctx, "h1", "alice"); role != domain.RoleOwner {
+		t.Fatalf("existing member role = %q, want owner", role)
+	}
+
+	// only one of several users accepting at once joins
+	contested := &domain.Invitation{Token: "contested", HouseholdID: "h1", Role: domain.RoleTrainer, CreatedBy: "alice", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := repo.CreateInvitation(ctx, contested); err != nil {
+		t.Fatal(err)
+	}
+	errs := make([]error, 8)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repo.AcceptInvitation(ctx, "contested", fmt.Sprintf("racer%d", i))
+		}()
+	}
+	wg.Wait()
+
+	var joined []string
+	for i, err := range errs {
+		user := fmt.Sprintf("racer%d", i)
+		_, memberErr := repo.GetMemberRole(ctx, "h1", user)
+		if (err == nil) != (memberErr == nil) {
+			t.Fatalf("%s: accept err = %v, membership err = %v", user, err, memberErr)
+		}
+		if err == nil {
+			joined = append(joined, user)
+		}
+	}
+	if len(joined) != 1 {
+		t.Fatalf("users joined with one invitation = %v, want exactly one", joined)
+	}
+	if got, _ := repo.GetInvitation(ctx, "contested"); got.AcceptedBy != joined[0] {
+		t.Fatalf("AcceptedBy = %q, want %s", got.AcceptedBy, joined[0])
+	}
+}
+
+func testShareLinks(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	links := []*domain.ShareLink{
+		{ID: "second", SessionID: "s1", HouseholdID: "h1", CreatedBy: "alice", CreatedAt: base.Add(time.Minute), ExpiresAt: base.Add(time.Hour)},
+		{ID: "first", SessionID: "s1", HouseholdID: "h1", CreatedBy: "alice", CreatedAt: base, ExpiresAt: base.Add(time.Hour)},
+		{ID: "other", SessionID: "s2", HouseholdID: "h1", CreatedBy: "alice", CreatedAt: base, ExpiresAt: base.Add(time.Hour)},
+	}
+	for _, link := range links {
+		if err := repo.CreateShareLink(ctx, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := repo.GetShareLink(ctx, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SessionID != "s1" || got.RevokedAt != nil || !got.ExpiresAt.Equal(base.Add(time.Hour)) {
+		t.Fatalf("share link = %+v", got)
+	}
+	if _, err := repo.GetShareLink(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown link: err = %v, want ErrNotFound", err)
+	}
+
+	list, err := repo.GetShareLinks(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "first" || list[1].ID != "second" {
+		t.Fatalf("GetShareLinks = %+v, want first then second", list)
+	}
+
+	revokedAt := base.Add(30 * time.Minute)
+	if err := repo.RevokeShareLink(ctx, "first", revokedAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RevokeShareLink(ctx, "first", revokedAt.Add(time.Minute)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("revoking twice: err = %v, want ErrNotFound", err)
+	}
+	if err := repo.RevokeShareLink(ctx, "missing", revokedAt); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("revoking unknown link: err = %v, want ErrNotFound", err)
+	}
+
+	got, err = repo.GetShareLink(ctx, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RevokedAt == nil || !got.RevokedAt.Equal(revokedAt) {
+		t.Fatalf("RevokedAt = %v, want %v", got.RevokedAt, revokedAt)
+	}
+}
+
+func testReminders(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	reminders := []*domain.Reminder{
+		{ID: "evening", UserID: "alice", Time: "18:30", Timezone: "Europe/Stockholm", CreatedAt: base.Add(time.Minute), StartSession: true},
+		{ID: "morning", UserID: "alice", Days: []time.Weekday{time.Monday, time.Friday}, Time: "07:00", Timezone: "UTC", CreatedAt: base},
+		{ID: "bob", UserID: "bob", Time: "12:00", Timezone: "UTC", CreatedAt: base},
+	}
+	for _, reminder := range reminders {
+		if err := repo.CreateReminder(ctx, reminder); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := repo.GetReminders(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "morning" || list[1].ID != "evening" {
+		t.Fatalf("GetReminders = %+v, want morning then evening", list)
+	}
+	if got := list[0]; len(got.Days) != 2 || got.Days[1] != time.Friday || got.Time != "07:00" || got.LastFiredAt != nil || got.StartSession {
+		t.Fatalf("round trip = %+v", got)
+	}
+	if !list[1].StartSession {
+		t.Fatalf("round trip = %+v, want it to start a session", list[1])
+	}
+	all, err := repo.GetAllReminders(ctx)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("GetAllReminders = %+v, %v; want all 3", all, err)
+	}
+
+	// each occurrence fires once, and an occurrence older than the last one
+	// fired never does
+	fired := base.Add(7 * time.Hour)
+	if err := repo.MarkReminderFired(ctx, "morning", fired); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkReminderFired(ctx, "morning", fired); !errors.Is(err, ErrAlreadyFired) {
+		t.Fatalf("firing twice: err = %v, want ErrAlreadyFired", err)
+	}
+	if err := repo.MarkReminderFired(ctx, "morning", fired.Add(-24*time.Hour)); !errors.Is(err, ErrAlreadyFired) {
+		t.Fatalf("firing an earlier occurrence: err = %v, want ErrAlreadyFired", err)
+	}
+	if err := repo.MarkReminderFired(ctx, "missing", fired); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("firing unknown reminder: err = %v, want ErrNotFound", err)
+	}
+	list, _ = repo.GetReminders(ctx, "alice")
+	if list[0].LastFiredAt == nil || !list[0].LastFiredAt.Equal(fired) {
+		t.Fatalf("LastFiredAt = %v, want %v", list[0].LastFiredAt, fired)
+	}
+
+	if err := repo.DeleteReminder(ctx, "bob", "alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleting another user's reminder: err = %v, want ErrNotFound", err)
+	}
+	if err := repo.DeleteReminder(ctx, "morning", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteReminder(ctx, "morning", "alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleting twice: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testPushSubscriptions(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	sub := func(endpoint, userID string, createdAt time.Time) *domain.PushSubscription {
+		return &domain.PushSubscription{
+			Endpoint:  endpoint,
+			UserID:    userID,
+			Keys:      domain.PushKeys{P256dh: "key-" + endpoint, Auth: "auth-" + endpoint},
+			CreatedAt: createdAt,
+		}
+	}
+	for _, s := range []*domain.PushSubscription{
+		sub("https://push.example/phone", "alice", base.Add(time.Minute)),
+		sub("https://push.example/laptop", "alice", base),
+		sub("https://push.example/tablet", "bob", base),
+	} {
+		if err := repo.SavePushSubscription(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	subs, err := repo.GetPushSubscriptions(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 2 || subs[0].Endpoint != "https://push.example/laptop" || subs[1].Keys.Auth != "auth-https://push.example/phone" {
+		t.Fatalf("GetPushSubscriptions = %+v, want laptop then phone", subs)
+	}
+
+	// the tablet is now alice's
+	if err := repo.SavePushSubscription(ctx, sub("https://push.example/tablet", "alice", base.Add(2*time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	if subs, _ := repo.GetPushSubscriptions(ctx, "bob"); len(subs) != 0 {
+		t.Fatalf("bob's subscriptions = %+v, want none after the tablet moved", subs)
+	}
+	if subs, _ := repo.GetPushSubscriptions(ctx, "alice"); len(subs) != 3 {
+		t.Fatalf("alice's subscriptions = %+v, want 3", subs)
+	}
+
+	if err := repo.DeletePushSubscription(ctx, "bob", "https://push.example/tablet"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleting another user's subscription: err = %v, want ErrNotFound", err)
+	}
+	if err := repo.DeletePushSubscription(ctx, "alice", "https://push.example/tablet"); err != nil {
+		t.Fatal(err)
+	}
+	if subs, _ := repo.GetPushSubscriptions(ctx, "alice"); len(subs) != 2 {
+		t.Fatalf("alice's subscriptions = %+v, want 2 after deleting one", subs)
+	}
+}
+
+func testWebhooks(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	hook := func(id, userID string, createdAt time.Time, events ...domain.WebhookEvent) *domain.Webhook {
+		return &domain.Webhook{
+			ID:        id,
+			UserID:    userID,
+			URL:       "https://hooks.example/" + id,
+			Events:    events,
+			Secret:    "secret-" + id,
+			CreatedAt: createdAt,
+		}
+	}
+	for _, w := range []*domain.Webhook{
+		hook("lights", "alice", base.Add(time.Minute), domain.WebhookStepStarted, domain.WebhookStepCompleted),
+		hook("camera", "alice", base),
+		hook("bobs", "bob", base),
+	} {
+		if err := repo.CreateWebhook(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hooks, err := repo.GetWebhooks(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 2 || hooks[0].ID != "camera" || hooks[1].ID != "lights" {
+		t.Fatalf("GetWebhooks = %+v, want camera then lights", hooks)
+	}
+	if len(hooks[1].Events) != 2 || hooks[1].Events[1] != domain.WebhookStepCompleted || hooks[1].Secret != "secret-lights" {
+		t.Fatalf("lights webhook = %+v", hooks[1])
+	}
+
+	if _, err := repo.GetWebhook(ctx, "lights", "bob"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("getting another user's webhook: err = %v, want ErrNotFound", err)
+	}
+	got, err := repo.GetWebhook(ctx, "lights", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.URL != "https://hooks.example/lights" || !got.CreatedAt.Equal(base.Add(time.Minute)) {
+		t.Fatalf("GetWebhook = %+v", got)
+	}
+
+	for i, status := range []int{503, 0, 200} {
+		d := &domain.WebhookDelivery{
+			ID:          fmt.Sprintf("d%d", i),
+			WebhookID:   "lights",
+			EventID:     "ev1",
+			Event:       domain.WebhookStepStarted,
+			Attempt:     i + 1,
+			StatusCode:  status,
+			Success:     status == 200,
+			DurationMs:  int64(10 * i),
+			DeliveredAt: base.Add(time.Duration(i) * time.Second),
+		}
+		if status == 0 {
+			d.Error = "connection refused"
+		}
+		if err := repo.AddWebhookDelivery(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.AddWebhookDelivery(ctx, &domain.WebhookDelivery{ID: "other", WebhookID: "camera", EventID: "ev2", Event: domain.WebhookPing, Attempt: 1, DeliveredAt: base})
+
+	deliveries, err := repo.GetWebhookDeliveries(ctx, "lights", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 || deliveries[0].ID != "d2" || deliveries[1].ID != "d1" {
+		t.Fatalf("GetWebhookDeliveries = %+v, want d2 then d1", deliveries)
+	}
+	if !deliveries[0].Success || deliveries[0].StatusCode != 200 || deliveries[1].Error != "connection refused" || deliveries[1].Attempt != 2 {
+		t.Fatalf("deliveries = %+v", deliveries)
+	}
+
+	if err := repo.DeleteWebhook(ctx, "lights", "bob"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleting another user's webhook: err = %v, want ErrNotFound", err)
+	}
+	if err := repo.DeleteWebhook(ctx, "lights", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if hooks, _ := repo.GetWebhooks(ctx, "alice"); len(hooks) != 1 {
+		t.Fatalf("alice's webhooks = %+v, want 1 after deleting one", hooks)
+	}
+	if deliveries, _ := repo.GetWebhookDeliveries(ctx, "lights", 10); len(deliveries) != 0 {
+		t.Fatalf("deliveries of a deleted webhook = %+v, want none", deliveries)
+	}
+	if deliveries, _ := repo.GetWebhookDeliveries(ctx, "camera", 10); len(deliveries) != 1 {
+		t.Fatalf("camera deliveries = %+v, want 1", deliveries)
+	}
+}
+
+func testCheckpoints(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	session := func(id string, elapsed int) domain.Session {
+		return domain.Session{
+			ID:          id,
+			UserID:      "alice",
+			HouseholdID: "h1",
+			TargetSec:   60,
+			StartedAt:   base,
+			Steps:       []domain.Step{{Index: 0, Duration: 60, Elapsed: elapsed}},
+		}
+	}
+
+	if err := repo.SaveCheckpoints(ctx, []domain.Session{session("s1", 10)}); err != nil {
+		t.Fatal(err)
+	}
+	// saving a session again replaces its checkpoint
+	if err := repo.SaveCheckpoints(ctx, []domain.Session{session("s1", 20)}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := repo.SaveCheckpoints(ctx, []domain.Session{session("s2", 5)}); err != nil {
+		t.Fatal(err)
+	}
+
+	taken, err := repo.TakeCheckpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taken) != 2 || taken[0].ID != "s1" || taken[1].ID != "s2" {
+		t.Fatalf("TakeCheckpoints = %+v, want s1 then s2", taken)
+	}
+	if taken[0].Steps[0].Elapsed != 20 || taken[0].HouseholdID != "h1" {
+		t.Fatalf("checkpoint = %+v, want the latest save", taken[0])
+	}
+
+	again, err := repo.TakeCheckpoints(ctx)
+	if err != nil || len(again) != 0 {
+		t.Fatalf("second TakeCheckpoints = %v, %v; want none", again, err)
+	}
+}
+
+func testPing(t *testing.T, repo Repository) {
+	if err := repo.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
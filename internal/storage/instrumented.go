@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/metrics"
+)
+
+// instrumented times every call to the wrapped repository and logs it at
+// debug level.
+type instrumented struct {
+	repo    Repository
+	metrics metrics.Metrics
+}
+
+// Instrument wraps repo so each method reports its latency and outcome to m.
+func Instrument(repo Repository, m metrics.Metrics) Repository {
+	return &instrumented{repo: repo, metrics: m}
+}
+
+func (i *instrumented) observe(ctx context.Context, method string, start time.Time, err *error) {
+	d := time.Since(start)
+	i.metrics.ObserveQuery(method, d, *err)
+
+	if *err != nil {
+		slog.DebugContext(ctx, "repository call", "method", method, "duration", d, "err", *err)
+		return
+	}
+	slog.DebugContext(ctx, "repository call", "method", method, "duration", d)
+}
+
+func (i *instrumented) SaveSession(ctx context.Context, record *SessionRecord) (err error) {
+	defer i.observe(ctx, "SaveSession", time.Now(), &err)
+	return i.repo.SaveSession(ctx, record)
+}
+
+func (i *instrumented) GetSessionsByHousehold(ctx context.Context, householdID string) (_ []SessionRecord, err error) {
+	defer i.observe(ctx, "GetSessionsByHousehold", time.Now(), &err)
+	return i.repo.GetSessionsByHousehold(ctx, householdID)
+}
+
+func (i *instrumented) GetRecentSessions(ctx context.Context, householdID string, since time.Time) (_ []SessionRecord, err error) {
+	defer i.observe(ctx, "GetRecentSessions", time.Now(), &err)
+	return i.repo.GetRecentSessions(ctx, householdID, since)
+}
+
+func (i *instrumented) GetSessionStats(ctx context.Context, householdID string) (_ *SessionStats, err error) {
+	defer i.observe(ctx, "GetSessionStats", time.Now(), &err)
+	return i.repo.GetSessionStats(ctx, householdID)
+}
+
+func (i *instrumented) GetSession(ctx context.Context, id string) (_ *SessionRecord, err error) {
+	defer i.observe(ctx, "GetSession", time.Now(), &err)
+	return i.repo.GetSession(ctx, id)
+}
+
+func (i *instrumented) UpdateSession(ctx context.Context, id string, edit SessionEdit, userID string, at time.Time) (_ *SessionRecord, err error) {
+	defer i.observe(ctx, "UpdateSession", time.Now(), &err)
+	return i.repo.UpdateSession(ctx, id, edit, userID, at)
+}
+
+func (i *instrumented) DeleteSession(ctx context.Context, id, userID string, at time.Time) (err error) {
+	defer i.observe(ctx, "DeleteSession", time.Now(), &err)
+	return i.repo.DeleteSession(ctx, id, userID, at)
+}
+
+func (i *instrumented) RestoreSession(ctx context.Context, id, userID string, at time.Time) (err error) {
+	defer i.observe(ctx, "RestoreSession", time.Now(), &err)
+	return i.repo.RestoreSession(ctx, id, userID, at)
+}
+
+func (i *instrumented) GetSessionChanges(ctx context.Context, id string) (_ []SessionChange, err error) {
+	defer i.observe(ctx, "GetSessionChanges", time.Now(), &err)
+	return i.repo.GetSessionChanges(ctx, id)
+}
+
+func (i *instrumented) CreateHousehold(ctx context.Context, h *domain.Household, ownerID string) (err error) {
+	defer i.observe(ctx, "CreateHousehold", time.Now(), &err)
+	return i.repo.CreateHousehold(ctx, h, ownerID)
+}
+
+func (i *instrumented) GetHouseholdsForUser(ctx context.Context, userID string) (_ []domain.Membership, err error) {
+	defer i.observe(ctx, "GetHouseholdsForUser", time.Now(), &err)
+	return i.repo.GetHouseholdsForUser(ctx, userID)
+}
+
+func (i *instrumented) GetMemberRole(ctx context.Context, householdID, userID string) (_ domain.Role, err error) {
+	defer i.observe(ctx, "GetMemberRole", time.Now(), &err)
+	return i.repo.GetMemberRole(ctx, householdID, userID)
+}
+
+func (i *instrumented) GetMembers(ctx context.Context, householdID string) (_ []domain.Member, err error) {
+	defer i.observe(ctx, "GetMembers", time.Now(), &err)
+	return i.repo.GetMembers(ctx, householdID)
+}
+
+func (i *instrumented) SetMemberRole(ctx context.Context, householdID, userID string, role domain.Role) (err error) {
+	defer i.observe(ctx, "SetMemberRole", time.Now(), &err)
+	return i.repo.SetMemberRole(ctx, householdID, userID, role)
+}
+
+func (i *instrumented) RemoveMember(ctx context.Context, householdID, userID string) (err error) {
+	defer i.observe(ctx, "RemoveMember", time.Now(), &err)
+	return i.repo.RemoveMember(ctx, householdID, userID)
+}
+
+func (i *instrumented) CreateInvitation(ctx context.Context, inv *domain.Invitation) (err error) {
+	defer i.observe(ctx, "CreateInvitation", time.Now(), &err)
+	return i.repo.CreateInvitation(ctx, inv)
+}
+
+func (i *instrumented) GetInvitation(ctx context.Context, token string) (_ *domain.Invitation, err error) {
+	defer i.observe(ctx, "GetInvitation", time.Now(), &err)
+	return i.repo.GetInvitation(ctx, token)
+}
+
+func (i *instrumented) AcceptInvitation(ctx context.Context, token, userID string) (err error) {
+	defer i.observe(ctx, "AcceptInvitation", time.Now(), &err)
+	return i.repo.AcceptInvitation(ctx, token, userID)
+}
+
+func (i *instrumented) CreateShareLink(ctx context.Context, link *domain.ShareLink) (err error) {
+	defer i.observe(ctx, "CreateShareLink", time.Now(), &err)
+	return i.repo.CreateShareLink(ctx, link)
+}
+
+func (i *instrumented) GetShareLink(ctx context.Context, id string) (_ *domain.ShareLink, err error) {
+	defer i.observe(ctx, "GetShareLink", time.Now(), &err)
+	return i.repo.GetShareLink(ctx, id)
+}
+
+func (i *instrumented) GetShareLinks(ctx context.Context, sessionID string) (_ []domain.ShareLink, err error) {
+	defer i.observe(ctx, "GetShareLinks", time.Now(), &err)
+	return i.repo.GetShareLinks(ctx, sessionID)
+}
+
+func (i *instrumented) RevokeShareLink(ctx context.Context, id string, at time.Time) (err error) {
+	defer i.observe(ctx, "RevokeShareLink", time.Now(), &err)
+	return i.repo.RevokeShareLink(ctx, id, at)
+}
+
+func (i *instrumented) CreateReminder(ctx context.Context, reminder *domain.Reminder) (err error) {
+	defer i.observe(ctx, "CreateReminder", time.Now(), &err)
+	return i.repo.CreateReminder(ctx, reminder)
+}
+
+func (i *instrumented) GetReminders(ctx context.Context, userID string) (_ []domain.Reminder, err error) {
+	defer i.observe(ctx, "GetReminders", time.Now(), &err)
+	return i.repo.GetReminders(ctx, userID)
+}
+
+func (i *instrumented) GetAllReminders(ctx context.Context) (_ []domain.Reminder, err error) {
+	defer i.observe(ctx, "GetAllReminders", time.Now(), &err)
+	return i.repo.GetAllReminders(ctx)
+}
+
+func (i *instrumented) DeleteReminder(ctx context.Context, id, userID string) (err error) {
+	defer i.observe(ctx, "DeleteReminder", time.Now(), &err)
+	return i.repo.DeleteReminder(ctx, id, userID)
+}
+
+func (i *instrumented) MarkReminderFired(ctx context.Context, id string, at time.Time) (err error) {
+	defer i.observe(ctx, "MarkReminderFired", time.Now(), &err)
+	return i.repo.MarkReminderFired(ctx, id, at)
+}
+
+func (i *instrumented) SavePushSubscription(ctx context.Context, sub *domain.PushSubscription) (err error) {
+	defer i.observe(ctx, "SavePushSubscription", time.Now(), &err)
+	return i.repo.SavePushSubscription(ctx, sub)
+}
+
+func (i *instrumented) GetPushSubscriptions(ctx context.Context, userID string) (_ []domain.PushSubscription, err error) {
+	defer i.observe(ctx, "GetPushSubscriptions", time.Now(), &err)
+	return i.repo.GetPushSubscriptions(ctx, userID)
+}
+
+func (i *instrumented) DeletePushSubscription(ctx context.Context, userID, endpoint string) (err error) {
+	defer i.observe(ctx, "DeletePushSubscription", time.Now(), &err)
+	return i.repo.DeletePushSubscription(ctx, userID, endpoint)
+}
+
+func (i *instrumented) CreateWebhook(ctx context.Context, w *domain.Webhook) (err error) {
+	defer i.observe(ctx, "CreateWebhook", time.Now(), &err)
+	return i.repo.CreateWebhook(ctx, w)
+}
+
+func (i *instrumented) GetWebhooks(ctx context.Context, userID string) (_ []domain.Webhook, err error) {
+	defer i.observe(ctx, "GetWebhooks", time.Now(), &err)
+	return i.repo.GetWebhooks(ctx, userID)
+}
+
+func (i *instrumented) GetWebhook(ctx context.Context, id, userID string) (_ *domain.Webhook, err error) {
+	defer i.observe(ctx, "GetWebhook", time.Now(), &err)
+	return i.repo.GetWebhook(ctx, id, userID)
+}
+
+func (i *instrumented) DeleteWebhook(ctx context.Context, id, userID string) (err error) {
+	defer i.observe(ctx, "DeleteWebhook", time.Now(), &err)
+	return i.repo.DeleteWebhook(ctx, id, userID)
+}
+
+func (i *instrumented) AddWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) (err error) {
+	defer i.observe(ctx, "AddWebhookDelivery", time.Now(), &err)
+	return i.repo.AddWebhookDelivery(ctx, d)
+}
+
+func (i *instrumented) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) (_ []domain.WebhookDelivery, err error) {
+	defer i.observe(ctx, "GetWebhookDeliveries", time.Now(), &err)
+	return i.repo.GetWebhookDeliveries(ctx, webhookID, limit)
+}
+
+func (i *instrumented) SaveCheckpoints(ctx context.Context, sessions []domain.Session) (err error) {
+	defer i.observe(ctx, "SaveCheckpoints", time.Now(), &err)
+	return i.repo.SaveCheckpoints(ctx, sessions)
+}
+
+func (i *instrumented) TakeCheckpoints(ctx context.Context) (_ []domain.Session, err error) {
+	defer i.observe(ctx, "TakeCheckpoints", time.Now(), &err)
+	return i.repo.TakeCheckpoints(ctx)
+}
+
+func (i *instrumented) Ping(ctx context.Context) (err error) {
+	defer i.observe(ctx, "Ping", time.Now(), &err)
+	return i.repo.Ping(ctx)
+}
+
+func (i *instrumented) Close() error {
+	return i.repo.Close()
+}
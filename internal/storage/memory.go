@@ -0,0 +1,645 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+)
+
+// MemoryRepository keeps everything in process memory. It mirrors the SQL
+// repositories, including result ordering and error values, and is meant
+// for tests and demo mode; data is lost on restart.
+type MemoryRepository struct {
+	mu sync.RWMutex
+
+	sessions    map[string]SessionRecord
+	changes     map[string][]SessionChange
+	households  map[string]domain.Household
+	members     map[string]map[string]domain.Member // household ID -> user ID
+	invitations map[string]domain.Invitation
+	shareLinks  map[string]domain.ShareLink
+	reminders   map[string]domain.Reminder
+	webhooks    map[string]domain.Webhook
+	deliveries  []domain.WebhookDelivery
+	pushSubs    map[string]domain.PushSubscription // by endpoint
+	checkpoints map[string]checkpoint
+	seq         int
+}
+
+type checkpoint struct {
+	session domain.Session
+	savedAt time.Time
+	seq     int
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		sessions:    make(map[string]SessionRecord),
+		changes:     make(map[string][]SessionChange),
+		households:  make(map[string]domain.Household),
+		members:     make(map[string]map[string]domain.Member),
+		invitations: make(map[string]domain.Invitation),
+		shareLinks:  make(map[string]domain.ShareLink),
+		reminders:   make(map[string]domain.Reminder),
+		webhooks:    make(map[string]domain.Webhook),
+		pushSubs:    make(map[string]domain.PushSubscription),
+		checkpoints: make(map[string]checkpoint),
+	}
+}
+
+func (r *MemoryRepository) SaveSession(ctx context.Context, record *SessionRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.sessions[record.ID]; exists {
+		return ErrDuplicate
+	}
+	r.sessions[record.ID] = copyRecord(*record)
+	return nil
+}
+
+func (r *MemoryRepository) GetSessionsByHousehold(ctx context.Context, householdID string) ([]SessionRecord, error) {
+	return r.findSessions(func(s SessionRecord) bool {
+		return s.HouseholdID == householdID && s.DeletedAt == nil
+	}), nil
+}
+
+func (r *MemoryRepository) GetRecentSessions(ctx context.Context, householdID string, since time.Time) ([]SessionRecord, error) {
+	return r.findSessions(func(s SessionRecord) bool {
+		return s.HouseholdID == householdID && s.DeletedAt == nil && !s.CompletedAt.Before(since)
+	}), nil
+}
+
+// findSessions returns matching sessions, most recently completed first.
+func (r *MemoryRepository) findSessions(match func(SessionRecord) bool) []SessionRecord {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var records []SessionRecord
+	for _, s := range r.sessions {
+		if match(s) {
+			records = append(records, copyRecord(s))
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CompletedAt.After(records[j].CompletedAt)
+	})
+	return records
+}
+
+func (r *MemoryRepository) GetSessionStats(ctx context.Context, householdID string) (*SessionStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var stats SessionStats
+	for _, s := range r.sessions {
+		if s.HouseholdID != householdID || s.DeletedAt != nil {
+			continue
+		}
+		if s.Success == SuccessLevelAbandoned {
+			stats.AbandonedCount++
+			continue
+		}
+		stats.TotalSessions++
+		stats.TotalTrainTime += s.TargetSec
+		if s.Success == SuccessLevelOK || s.Success == SuccessLevelGreat {
+			stats.SuccessfulCount++
+		}
+	}
+
+	if stats.TotalSessions > 0 {
+		stats.AverageTarget = float64(stats.TotalTrainTime) / float64(stats.TotalSessions)
+		stats.SuccessRate = float64(stats.SuccessfulCount) / float64(stats.TotalSessions) * 100
+	}
+	return &stats, nil
+}
+
+func (r *MemoryRepository) GetSession(ctx context.Context, id string) (*SessionRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	s = copyRecord(s)
+	return &s, nil
+}
+
+func (r *MemoryRepository) UpdateSession(ctx context.Context, id string, edit SessionEdit, userID string, at time.Time) (*SessionRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok || s.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	before, after := edit.apply(&s)
+	if !after.empty() {
+		r.sessions[id] = s
+		r.changes[id] = append(r.changes[id], SessionChange{
+			SessionID: id, UserID: userID, Action: SessionEdited, Before: before, After: after, ChangedAt: at,
+		})
+	}
+
+	s = copyRecord(s)
+	return &s, nil
+}
+
+func (r *MemoryRepository) DeleteSession(ctx context.Context, id, userID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok || s.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	s.DeletedAt = &at
+	r.sessions[id] = s
+	r.changes[id] = append(r.changes[id], SessionChange{SessionID: id, UserID: userID, Action: SessionDeleted, ChangedAt: at})
+	return nil
+}
+
+func (r *MemoryRepository) RestoreSession(ctx context.Context, id, userID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok || s.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	s.DeletedAt = nil
+	r.sessions[id] = s
+	r.changes[id] = append(r.changes[id], SessionChange{SessionID: id, UserID: userID, Action: SessionRestored, ChangedAt: at})
+	return nil
+}
+
+func (r *MemoryRepository) GetSessionChanges(ctx context.Context, id string) ([]SessionChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	changes := append([]SessionChange(nil), r.changes[id]...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].ChangedAt.Before(changes[j].ChangedAt)
+	})
+	return changes, nil
+}
+
+func (r *MemoryRepository) CreateHousehold(ctx context.Context, h *domain.Household, ownerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.households[h.ID]; exists {
+		return nil // household already exists
+	}
+
+	r.households[h.ID] = *h
+	r.members[h.ID] = map[string]domain.Member{
+		ownerID: {HouseholdID: h.ID, UserID: ownerID, Role: domain.RoleOwner, JoinedAt: h.CreatedAt},
+	}
+	return nil
+}
+
+func (r *MemoryRepository) GetHouseholdsForUser(ctx context.Context, userID string) ([]domain.Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var memberships []domain.Membership
+	for id, members := range r.members {
+		if m, ok := members[userID]; ok {
+			memberships = append(memberships, domain.Membership{Household: r.households[id], Role: m.Role})
+		}
+	}
+	sort.SliceStable(memberships, func(i, j int) bool {
+		return memberships[i].CreatedAt.Before(memberships[j].CreatedAt)
+	})
+	return memberships, nil
+}
+
+func (r *MemoryRepository) GetMemberRole(ctx context.Context, householdID, userID string) (domain.Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.members[householdID][userID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return m.Role, nil
+}
+
+func (r *MemoryRepository) GetMembers(ctx context.Context, householdID string) ([]domain.Member, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var members []domain.Member
+	for _, m := range r.members[householdID] {
+		members = append(members, m)
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].JoinedAt.Before(members[j].JoinedAt)
+	})
+	return members, nil
+}
+
+func (r *MemoryRepository) SetMemberRole(ctx context.Context, householdID, userID string, role domain.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, exists := r.members[householdID][userID]; exists {
+		m.Role = role
+		r.members[householdID][userID] = m
+		return nil
+	}
+	r.addMember(householdID, userID, role, time.Now())
+	return nil
+}
+
+// addMember adds userID unless they already belong to the household. It
+// must be called with r.mu held.
+func (r *MemoryRepository) addMember(householdID, userID string, role domain.Role, joinedAt time.Time) {
+	members, ok := r.members[householdID]
+	if !ok {
+		members = make(map[string]domain.Member)
+		r.members[householdID] = members
+	}
+	if _, exists := members[userID]; !exists {
+		members[userID] = domain.Member{HouseholdID: householdID, UserID: userID, Role: role, JoinedAt: joinedAt}
+	}
+}
+
+func (r *MemoryRepository) RemoveMember(ctx context.Context, householdID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.members[householdID][userID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.members[householdID], userID)
+	return nil
+}
+
+func (r *MemoryRepository) CreateInvitation(ctx context.Context, inv *domain.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.invitations[inv.Token]; exists {
+		return fmt.Errorf("invitation %s already exists", inv.Token)
+	}
+	r.invitations[inv.Token] = *inv
+	return nil
+}
+
+func (r *MemoryRepository) GetInvitation(ctx context.Context, token string) (*domain.Invitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	inv, ok := r.invitations[token]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &inv, nil
+}
+
+func (r *MemoryRepository) AcceptInvitation(ctx context.Context, token, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, ok := r.invitations[token]
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	if !inv.Usable(now) {
+		return ErrInvitationUnusable
+	}
+
+	inv.AcceptedBy = userID
+	r.invitations[token] = inv
+	r.addMember(inv.HouseholdID, userID, inv.Role, now)
+	return nil
+}
+
+func (r *MemoryRepository) CreateShareLink(ctx context.Context, link *domain.ShareLink) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.shareLinks[link.ID]; exists {
+		return fmt.Errorf("share link %s already exists", link.ID)
+	}
+	r.shareLinks[link.ID] = copyShareLink(*link)
+	return nil
+}
+
+func (r *MemoryRepository) GetShareLink(ctx context.Context, id string) (*domain.ShareLink, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	link, ok := r.shareLinks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	link = copyShareLink(link)
+	return &link, nil
+}
+
+func (r *MemoryRepository) GetShareLinks(ctx context.Context, sessionID string) ([]domain.ShareLink, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var links []domain.ShareLink
+	for _, link := range r.shareLinks {
+		if link.SessionID == sessionID {
+			links = append(links, copyShareLink(link))
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].CreatedAt.Before(links[j].CreatedAt)
+	})
+	return links, nil
+}
+
+func (r *MemoryRepository) RevokeShareLink(ctx context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link, ok := r.shareLinks[id]
+	if !ok || link.RevokedAt != nil {
+		return ErrNotFound
+	}
+	link.RevokedAt = &at
+	r.shareLinks[id] = link
+	return nil
+}
+
+func (r *MemoryRepository) CreateReminder(ctx context.Context, reminder *domain.Reminder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.reminders[reminder.ID]; exists {
+		return fmt.Errorf("reminder %s already exists", reminder.ID)
+	}
+	r.reminders[reminder.ID] = copyReminder(*reminder)
+	return nil
+}
+
+func (r *MemoryRepository) GetReminders(ctx context.Context, userID string) ([]domain.Reminder, error) {
+	return r.findReminders(func(reminder domain.Reminder) bool {
+		return reminder.UserID == userID
+	}), nil
+}
+
+func (r *MemoryRepository) GetAllReminders(ctx context.Context) ([]domain.Reminder, error) {
+	return r.findReminders(func(domain.Reminder) bool { return true }), nil
+}
+
+func (r *MemoryRepository) findReminders(match func(domain.Reminder) bool) []domain.Reminder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var reminders []domain.Reminder
+	for _, reminder := range r.reminders {
+		if match(reminder) {
+			reminders = append(reminders, copyReminder(reminder))
+		}
+	}
+	sort.SliceStable(reminders, func(i, j int) bool {
+		return reminders[i].CreatedAt.Before(reminders[j].CreatedAt)
+	})
+	return reminders
+}
+
+func (r *MemoryRepository) DeleteReminder(ctx context.Context, id, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminder, ok := r.reminders[id]
+	if !ok || reminder.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.reminders, id)
+	return nil
+}
+
+func (r *MemoryRepository) MarkReminderFired(ctx context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminder, ok := r.reminders[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if reminder.LastFiredAt != nil && !at.After(*reminder.LastFiredAt) {
+		return ErrAlreadyFired
+	}
+	reminder.LastFiredAt = &at
+	r.reminders[id] = reminder
+	return nil
+}
+
+func (r *MemoryRepository) SavePushSubscription(ctx context.Context, sub *domain.PushSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pushSubs[sub.Endpoint] = *sub
+	return nil
+}
+
+func (r *MemoryRepository) GetPushSubscriptions(ctx context.Context, userID string) ([]domain.PushSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var subs []domain.PushSubscription
+	for _, sub := range r.pushSubs {
+		if sub.UserID == userID {
+			subs = append(subs, sub)
+		}
+	}
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs, nil
+}
+
+func (r *MemoryRepository) DeletePushSubscription(ctx context.Context, userID, endpoint string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sub, ok := r.pushSubs[endpoint]
+	if !ok || sub.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.pushSubs, endpoint)
+	return nil
+}
+
+func (r *MemoryRepository) CreateWebhook(ctx context.Context, w *domain.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.webhooks[w.ID]; exists {
+		return fmt.Errorf("webhook %s already exists", w.ID)
+	}
+	r.webhooks[w.ID] = copyWebhook(*w)
+	return nil
+}
+
+func (r *MemoryRepository) GetWebhooks(ctx context.Context, userID string) ([]domain.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var hooks []domain.Webhook
+	for _, w := range r.webhooks {
+		if w.UserID == userID {
+			hooks = append(hooks, copyWebhook(w))
+		}
+	}
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+	})
+	return hooks, nil
+}
+
+func (r *MemoryRepository) GetWebhook(ctx context.Context, id, userID string) (*domain.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	w, ok := r.webhooks[id]
+	if !ok || w.UserID != userID {
+		return nil, ErrNotFound
+	}
+	w = copyWebhook(w)
+	return &w, nil
+}
+
+func (r *MemoryRepository) DeleteWebhook(ctx context.Context, id, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.webhooks[id]
+	if !ok || w.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.webhooks, id)
+	r.deliveries = slices.DeleteFunc(r.deliveries, func(d domain.WebhookDelivery) bool {
+		return d.WebhookID == id
+	})
+	return nil
+}
+
+func (r *MemoryRepository) AddWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deliveries = append(r.deliveries, *d)
+	return nil
+}
+
+func (r *MemoryRepository) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]domain.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var deliveries []domain.WebhookDelivery
+	for i := len(r.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if r.deliveries[i].WebhookID == webhookID {
+			deliveries = append(deliveries, r.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *MemoryRepository) SaveCheckpoints(ctx context.Context, sessions []domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, s := range sessions {
+		r.seq++
+		r.checkpoints[s.ID] = checkpoint{
+			session: copySession(s),
+			savedAt: now,
+			seq:     r.seq,
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) TakeCheckpoints(ctx context.Context) ([]domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	saved := make([]checkpoint, 0, len(r.checkpoints))
+	for _, c := range r.checkpoints {
+		saved = append(saved, c)
+	}
+	sort.SliceStable(saved, func(i, j int) bool {
+		if !saved[i].savedAt.Equal(saved[j].savedAt) {
+			return saved[i].savedAt.Before(saved[j].savedAt)
+		}
+		return saved[i].seq < saved[j].seq
+	})
+
+	var sessions []domain.Session
+	for _, c := range saved {
+		sessions = append(sessions, c.session)
+	}
+	r.checkpoints = make(map[string]checkpoint)
+	return sessions, nil
+}
+
+func (r *MemoryRepository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (r *MemoryRepository) Close() error {
+	return nil
+}
+
+// The copy helpers keep callers from mutating stored values through
+// shared slices and pointers.
+
+func copyRecord(s SessionRecord) SessionRecord {
+	s.Steps = append([]StepRecord(nil), s.Steps...)
+	s.PlanChanges = append([]domain.PlanChange(nil), s.PlanChanges...)
+	if s.DeletedAt != nil {
+		at := *s.DeletedAt
+		s.DeletedAt = &at
+	}
+	return s
+}
+
+func copyReminder(reminder domain.Reminder) domain.Reminder {
+	reminder.Days = append([]time.Weekday(nil), reminder.Days...)
+	if reminder.LastFiredAt != nil {
+		at := *reminder.LastFiredAt
+		reminder.LastFiredAt = &at
+	}
+	return reminder
+}
+
+func copyWebhook(w domain.Webhook) domain.Webhook {
+	w.Events = append([]domain.WebhookEvent(nil), w.Events...)
+	return w
+}
+
+func copyShareLink(link domain.ShareLink) domain.ShareLink {
+	if link.RevokedAt != nil {
+		at := *link.RevokedAt
+		link.RevokedAt = &at
+	}
+	return link
+}
+
+func copySession(s domain.Session) domain.Session {
+	s.Steps = append([]domain.Step(nil), s.Steps...)
+	s.PlanChanges = append([]domain.PlanChange(nil), s.PlanChanges...)
+	return s
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/hperssn/hound/internal/domain"
@@ -12,17 +13,83 @@ const (
 	SuccessLevelFail  SuccessLevel = "fail"
 	SuccessLevelOK    SuccessLevel = "ok"
 	SuccessLevelGreat SuccessLevel = "great"
+
+	// SuccessLevelAbandoned marks sessions saved automatically because the
+	// user stopped or left them without rating them.
+	SuccessLevelAbandoned SuccessLevel = "abandoned"
 )
 
 type SessionRecord struct {
 	ID          string
 	UserID      string
+	HouseholdID string
 	TargetSec   int
 	Success     SuccessLevel
 	Comment     string
 	StartedAt   time.Time
 	CompletedAt time.Time
 	Steps       []StepRecord
+	// PlanChanges lists the edits made to the plan during the session.
+	PlanChanges []domain.PlanChange `json:",omitempty"`
+
+	// DeletedAt is set while the session is soft-deleted. Deleted sessions
+	// are left out of history and stats until restored.
+	DeletedAt *time.Time `json:",omitempty"`
+}
+
+// Valid reports whether l is one of the known success levels.
+func (l SuccessLevel) Valid() bool {
+	switch l {
+	case SuccessLevelFail, SuccessLevelOK, SuccessLevelGreat, SuccessLevelAbandoned:
+		return true
+	}
+	return false
+}
+
+// SessionEdit holds the fields of a saved session that can be corrected
+// afterwards. Nil fields are left unchanged.
+type SessionEdit struct {
+	Success *SuccessLevel `json:"success,omitempty"`
+	Comment *string       `json:"comment,omitempty"`
+}
+
+func (e SessionEdit) empty() bool {
+	return e.Success == nil && e.Comment == nil
+}
+
+// apply changes record according to e and returns the previous and new
+// values of the fields that actually changed.
+func (e SessionEdit) apply(record *SessionRecord) (before, after SessionEdit) {
+	if e.Success != nil && *e.Success != record.Success {
+		old, updated := record.Success, *e.Success
+		before.Success, after.Success = &old, &updated
+		record.Success = updated
+	}
+	if e.Comment != nil && *e.Comment != record.Comment {
+		old, updated := record.Comment, *e.Comment
+		before.Comment, after.Comment = &old, &updated
+		record.Comment = updated
+	}
+	return before, after
+}
+
+type SessionAction string
+
+const (
+	SessionEdited   SessionAction = "edited"
+	SessionDeleted  SessionAction = "deleted"
+	SessionRestored SessionAction = "restored"
+)
+
+// SessionChange is one entry in a saved session's audit trail. For edits,
+// Before and After hold only the fields that changed.
+type SessionChange struct {
+	SessionID string        `json:"sessionId"`
+	UserID    string        `json:"userId"`
+	Action    SessionAction `json:"action"`
+	Before    SessionEdit   `json:"before"`
+	After     SessionEdit   `json:"after"`
+	ChangedAt time.Time     `json:"changedAt"`
 }
 
 type StepRecord struct {
@@ -30,6 +97,7 @@ type StepRecord struct {
 	Index     int
 	Duration  int
 	ActualSec int // How long it actually took (might be less if failed)
+	PausedSec int // How long it was paused
 	Completed bool
 }
 
@@ -37,16 +105,13 @@ type StepRecord struct {
 func FromDomainSession(s *domain.Session, success SuccessLevel, comment string) *SessionRecord {
 	steps := make([]StepRecord, len(s.Steps))
 	for i, step := range s.Steps {
-		actualSec := 0
-		if !step.StartedAt.IsZero() {
-			actualSec = int(time.Since(step.StartedAt).Seconds())
-		}
-
 		steps[i] = StepRecord{
 			SessionID: s.ID,
 			Index:     step.Index,
 			Duration:  step.Duration,
-			ActualSec: actualSec,
+			// snapshots carry the time each step actually ran
+			ActualSec: step.Elapsed,
+			PausedSec: step.PausedSec,
 			Completed: step.Completed,
 		}
 	}
@@ -54,12 +119,22 @@ func FromDomainSession(s *domain.Session, success SuccessLevel, comment string)
 	return &SessionRecord{
 		ID:          s.ID,
 		UserID:      s.UserID,
+		HouseholdID: s.HouseholdID,
 		TargetSec:   s.TargetSec,
 		Success:     success,
 		Comment:     comment,
 		StartedAt:   s.StartedAt,
 		CompletedAt: time.Now(),
 		Steps:       steps,
+		PlanChanges: s.PlanChanges,
 	}
 }
 
+// encodePlanChanges encodes a record's plan changes for the database. No
+// changes are stored as [], like the column default, rather than null.
+func encodePlanChanges(changes []domain.PlanChange) ([]byte, error) {
+	if changes == nil {
+		changes = []domain.PlanChange{}
+	}
+	return json.Marshal(changes)
+}
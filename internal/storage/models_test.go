@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+)
+
+func TestFromDomainSessionRecordsRunTime(t *testing.T) {
+	s := domain.NewSession("", "alice", 60)
+	s.Steps = []domain.Step{
+		{Index: 0, Duration: 30, StartedAt: time.Now().Add(-10 * time.Minute), State: domain.StateCompleted, Completed: true, Elapsed: 30},
+		// paused within its first second and left for two hours
+		{Index: 1, Duration: 60, StartedAt: time.Now().Add(-2 * time.Hour), State: domain.StatePaused, PausedSec: 7200},
+		{Index: 2, Duration: 60, State: domain.StatePending},
+	}
+
+	record := FromDomainSession(s, SuccessLevelAbandoned, "")
+	for i, want := range []int{30, 0, 0} {
+		if got := record.Steps[i].ActualSec; got != want {
+			t.Errorf("step %d actual = %d, want %d", i, got, want)
+		}
+	}
+}
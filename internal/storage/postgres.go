@@ -1,11 +1,15 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"time"
 
 	_ "github.com/lib/pq"
+
+	"github.com/hperssn/hound/internal/domain"
 )
 
 type PostgresRepository struct {
@@ -42,47 +46,180 @@ func (r *PostgresRepository) createTables() error {
 
 	CREATE INDEX IF NOT EXISTS idx_user_id ON sessions(user_id);
 	CREATE INDEX IF NOT EXISTS idx_completed_at ON sessions(completed_at);
+
+	-- households were added after the first release; old sessions are
+	-- moved into their owner's personal household
+	ALTER TABLE sessions ADD COLUMN IF NOT EXISTS household_id TEXT;
+	UPDATE sessions SET household_id = user_id WHERE household_id IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_household_id ON sessions(household_id);
+
+	ALTER TABLE sessions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+	ALTER TABLE sessions ADD COLUMN IF NOT EXISTS plan_changes_json JSONB NOT NULL DEFAULT '[]';
+
+	CREATE TABLE IF NOT EXISTS households (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS household_members (
+		household_id TEXT NOT NULL REFERENCES households(id),
+		user_id TEXT NOT NULL,
+		role TEXT NOT NULL,
+		joined_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (household_id, user_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_member_user_id ON household_members(user_id);
+
+	CREATE TABLE IF NOT EXISTS household_invitations (
+		token TEXT PRIMARY KEY,
+		household_id TEXT NOT NULL REFERENCES households(id),
+		role TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		accepted_by TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS share_links (
+		id TEXT PRIMARY KEY,
+		session_id TEXT NOT NULL,
+		household_id TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		revoked_at TIMESTAMPTZ
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_share_session_id ON share_links(session_id);
+
+	CREATE TABLE IF NOT EXISTS session_checkpoints (
+		id TEXT PRIMARY KEY,
+		session_json JSONB NOT NULL,
+		saved_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS session_changes (
+		id BIGSERIAL PRIMARY KEY,
+		session_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		action TEXT NOT NULL,
+		before_json JSONB NOT NULL,
+		after_json JSONB NOT NULL,
+		changed_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_change_session_id ON session_changes(session_id);
+
+	CREATE TABLE IF NOT EXISTS reminders (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		days_json JSONB NOT NULL,
+		time_of_day TEXT NOT NULL,
+		timezone TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		last_fired_at TIMESTAMPTZ,
+		start_session BOOLEAN NOT NULL DEFAULT FALSE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_reminder_user_id ON reminders(user_id);
+	ALTER TABLE reminders ADD COLUMN IF NOT EXISTS start_session BOOLEAN NOT NULL DEFAULT FALSE;
+
+	CREATE TABLE IF NOT EXISTS push_subscriptions (
+		endpoint TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		p256dh TEXT NOT NULL,
+		auth TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_push_user_id ON push_subscriptions(user_id);
+
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		url TEXT NOT NULL,
+		events_json JSONB NOT NULL,
+		secret TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_user_id ON webhooks(user_id);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id TEXT PRIMARY KEY,
+		webhook_id TEXT NOT NULL,
+		event_id TEXT NOT NULL,
+		event TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		status_code INTEGER NOT NULL,
+		error TEXT NOT NULL,
+		success BOOLEAN NOT NULL,
+		duration_ms BIGINT NOT NULL,
+		delivered_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_delivery_webhook_id ON webhook_deliveries(webhook_id, delivered_at);
 	`
 
 	_, err := r.db.Exec(schema)
 	return err
 }
 
-func (r *PostgresRepository) SaveSession(record *SessionRecord) error {
+func (r *PostgresRepository) SaveSession(ctx context.Context, record *SessionRecord) error {
 	stepsJSON, err := json.Marshal(record.Steps)
 	if err != nil {
 		return err
 	}
+	planChangesJSON, err := encodePlanChanges(record.PlanChanges)
+	if err != nil {
+		return err
+	}
 
 	query := `
-		INSERT INTO sessions (id, user_id, target_sec, success, comment, started_at, completed_at, steps_json)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO sessions (id, user_id, household_id, target_sec, success, comment, started_at, completed_at, steps_json, plan_changes_json)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO NOTHING
 	`
 
-	_, err = r.db.Exec(
+	res, err := r.db.ExecContext(ctx,
 		query,
 		record.ID,
 		record.UserID,
+		record.HouseholdID,
 		record.TargetSec,
 		record.Success,
 		record.Comment,
 		record.StartedAt,
 		record.CompletedAt,
 		stepsJSON,
+		planChangesJSON,
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDuplicate
+	}
+	return nil
 }
 
-func (r *PostgresRepository) GetSessionsByUser(userID string) ([]SessionRecord, error) {
+func (r *PostgresRepository) GetSessionsByHousehold(ctx context.Context, householdID string) ([]SessionRecord, error) {
 	query := `
-		SELECT id, user_id, target_sec, success, comment, started_at, completed_at, steps_json
+		SELECT id, user_id, household_id, target_sec, success, comment, started_at, completed_at, steps_json, plan_changes_json, deleted_at
 		FROM sessions
-		WHERE user_id = $1
+		WHERE household_id = $1 AND deleted_at IS NULL
 		ORDER BY completed_at DESC
 	`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, householdID)
 	if err != nil {
 		return nil, err
 	}
@@ -91,15 +228,15 @@ func (r *PostgresRepository) GetSessionsByUser(userID string) ([]SessionRecord,
 	return r.scanSessions(rows)
 }
 
-func (r *PostgresRepository) GetRecentSessions(userID string, since time.Time) ([]SessionRecord, error) {
+func (r *PostgresRepository) GetRecentSessions(ctx context.Context, householdID string, since time.Time) ([]SessionRecord, error) {
 	query := `
-		SELECT id, user_id, target_sec, success, comment, started_at, completed_at, steps_json
+		SELECT id, user_id, household_id, target_sec, success, comment, started_at, completed_at, steps_json, plan_changes_json, deleted_at
 		FROM sessions
-		WHERE user_id = $1 AND completed_at >= $2
+		WHERE household_id = $1 AND completed_at >= $2 AND deleted_at IS NULL
 		ORDER BY completed_at DESC
 	`
 
-	rows, err := r.db.Query(query, userID, since)
+	rows, err := r.db.QueryContext(ctx, query, householdID, since)
 	if err != nil {
 		return nil, err
 	}
@@ -108,24 +245,26 @@ func (r *PostgresRepository) GetRecentSessions(userID string, since time.Time) (
 	return r.scanSessions(rows)
 }
 
-func (r *PostgresRepository) GetSessionStats(userID string) (*SessionStats, error) {
+func (r *PostgresRepository) GetSessionStats(ctx context.Context, householdID string) (*SessionStats, error) {
 	query := `
 		SELECT 
-			COUNT(*) as total,
-			SUM(CASE WHEN success IN ('ok', 'great') THEN 1 ELSE 0 END) as successful,
-			AVG(target_sec) as avg_target,
-			SUM(target_sec) as total_time
+			COALESCE(SUM(CASE WHEN success <> 'abandoned' THEN 1 ELSE 0 END), 0) as total,
+			COALESCE(SUM(CASE WHEN success IN ('ok', 'great') THEN 1 ELSE 0 END), 0) as successful,
+			COALESCE(SUM(CASE WHEN success = 'abandoned' THEN 1 ELSE 0 END), 0) as abandoned,
+			AVG(CASE WHEN success <> 'abandoned' THEN target_sec END) as avg_target,
+			SUM(CASE WHEN success <> 'abandoned' THEN target_sec END) as total_time
 		FROM sessions
-		WHERE user_id = $1
+		WHERE household_id = $1 AND deleted_at IS NULL
 	`
 
 	var stats SessionStats
 	var totalTime sql.NullInt64
 	var avgTarget sql.NullFloat64
 
-	err := r.db.QueryRow(query, userID).Scan(
+	err := r.db.QueryRowContext(ctx, query, householdID).Scan(
 		&stats.TotalSessions,
 		&stats.SuccessfulCount,
+		&stats.AbandonedCount,
 		&avgTarget,
 		&totalTime,
 	)
@@ -151,31 +290,876 @@ func (r *PostgresRepository) scanSessions(rows *sql.Rows) ([]SessionRecord, erro
 	var records []SessionRecord
 
 	for rows.Next() {
-		var record SessionRecord
-		var stepsJSON []byte
-
-		err := rows.Scan(
-			&record.ID,
-			&record.UserID,
-			&record.TargetSec,
-			&record.Success,
-			&record.Comment,
-			&record.StartedAt,
-			&record.CompletedAt,
-			&stepsJSON,
-		)
+		record, err := r.scanSession(rows)
 		if err != nil {
 			return nil, err
 		}
+		records = append(records, *record)
+	}
+
+	return records, rows.Err()
+}
+
+func (r *PostgresRepository) scanSession(row rowScanner) (*SessionRecord, error) {
+	var record SessionRecord
+	var stepsJSON, planChangesJSON []byte
+	var deletedAt sql.NullTime
+
+	err := row.Scan(
+		&record.ID,
+		&record.UserID,
+		&record.HouseholdID,
+		&record.TargetSec,
+		&record.Success,
+		&record.Comment,
+		&record.StartedAt,
+		&record.CompletedAt,
+		&stepsJSON,
+		&planChangesJSON,
+		&deletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(stepsJSON, &record.Steps); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(planChangesJSON, &record.PlanChanges); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		record.DeletedAt = &deletedAt.Time
+	}
+
+	return &record, nil
+}
+
+func (r *PostgresRepository) GetSession(ctx context.Context, id string) (*SessionRecord, error) {
+	return r.getSession(ctx, r.db, id)
+}
+
+func (r *PostgresRepository) getSession(ctx context.Context, q queryer, id string) (*SessionRecord, error) {
+	query := `
+		SELECT id, user_id, household_id, target_sec, success, comment, started_at, completed_at, steps_json, plan_changes_json, deleted_at
+		FROM sessions
+		WHERE id = $1
+	`
+
+	record, err := r.scanSession(q.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return record, err
+}
+
+func (r *PostgresRepository) UpdateSession(ctx context.Context, id string, edit SessionEdit, userID string, at time.Time) (*SessionRecord, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	record, err := r.getSession(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	before, after := edit.apply(record)
+	if after.empty() {
+		return record, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET success = $1, comment = $2 WHERE id = $3`, record.Success, record.Comment, id); err != nil {
+		return nil, err
+	}
+
+	change := SessionChange{SessionID: id, UserID: userID, Action: SessionEdited, Before: before, After: after, ChangedAt: at}
+	if err := r.recordChange(ctx, tx, change); err != nil {
+		return nil, err
+	}
+
+	return record, tx.Commit()
+}
+
+func (r *PostgresRepository) DeleteSession(ctx context.Context, id, userID string, at time.Time) error {
+	return r.setDeleted(ctx, `UPDATE sessions SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`, at,
+		SessionChange{SessionID: id, UserID: userID, Action: SessionDeleted, ChangedAt: at})
+}
+
+func (r *PostgresRepository) RestoreSession(ctx context.Context, id, userID string, at time.Time) error {
+	return r.setDeleted(ctx, `UPDATE sessions SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NOT NULL`, nil,
+		SessionChange{SessionID: id, UserID: userID, Action: SessionRestored, ChangedAt: at})
+}
+
+// setDeleted runs a soft-delete or restore query and records the change.
+func (r *PostgresRepository) setDeleted(ctx context.Context, query string, deletedAt any, change SessionChange) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, deletedAt, change.SessionID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	if err := r.recordChange(ctx, tx, change); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) recordChange(ctx context.Context, tx *sql.Tx, change SessionChange) error {
+	before, err := json.Marshal(change.Before)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(change.After)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO session_changes (session_id, user_id, action, before_json, after_json, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = tx.ExecContext(ctx, query, change.SessionID, change.UserID, change.Action, before, after, change.ChangedAt)
+	return err
+}
+
+func (r *PostgresRepository) GetSessionChanges(ctx context.Context, id string) ([]SessionChange, error) {
+	query := `
+		SELECT session_id, user_id, action, before_json, after_json, changed_at
+		FROM session_changes
+		WHERE session_id = $1
+		ORDER BY changed_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []SessionChange
+	for rows.Next() {
+		var c SessionChange
+		var before, after []byte
+		if err := rows.Scan(&c.SessionID, &c.UserID, &c.Action, &before, &after, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(before, &c.Before); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(after, &c.After); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+func (r *PostgresRepository) CreateHousehold(ctx context.Context, h *domain.Household, ownerID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-		if err := json.Unmarshal(stepsJSON, &record.Steps); err != nil {
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO households (id, name, created_by, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO NOTHING
+	`, h.ID, h.Name, h.CreatedBy, h.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil // household already exists
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO household_members (household_id, user_id, role, joined_at)
+		VALUES ($1, $2, $3, $4)
+	`, h.ID, ownerID, domain.RoleOwner, h.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) GetHouseholdsForUser(ctx context.Context, userID string) ([]domain.Membership, error) {
+	query := `
+		SELECT h.id, h.name, h.created_by, h.created_at, m.role
+		FROM households h
+		JOIN household_members m ON m.household_id = h.id
+		WHERE m.user_id = $1
+		ORDER BY h.created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []domain.Membership
+	for rows.Next() {
+		var m domain.Membership
+		if err := rows.Scan(&m.ID, &m.Name, &m.CreatedBy, &m.CreatedAt, &m.Role); err != nil {
 			return nil, err
 		}
+		memberships = append(memberships, m)
+	}
+
+	return memberships, rows.Err()
+}
+
+func (r *PostgresRepository) GetMemberRole(ctx context.Context, householdID, userID string) (domain.Role, error) {
+	query := `SELECT role FROM household_members WHERE household_id = $1 AND user_id = $2`
 
-		records = append(records, record)
+	var role domain.Role
+	err := r.db.QueryRowContext(ctx, query, householdID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
 	}
+	return role, err
+}
 
-	return records, rows.Err()
+func (r *PostgresRepository) GetMembers(ctx context.Context, householdID string) ([]domain.Member, error) {
+	query := `
+		SELECT household_id, user_id, role, joined_at
+		FROM household_members
+		WHERE household_id = $1
+		ORDER BY joined_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []domain.Member
+	for rows.Next() {
+		var m domain.Member
+		if err := rows.Scan(&m.HouseholdID, &m.UserID, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *PostgresRepository) SetMemberRole(ctx context.Context, householdID, userID string, role domain.Role) error {
+	query := `
+		INSERT INTO household_members (household_id, user_id, role, joined_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (household_id, user_id) DO UPDATE SET role = excluded.role
+	`
+
+	_, err := r.db.ExecContext(ctx, query, householdID, userID, role, time.Now())
+	return err
+}
+
+func (r *PostgresRepository) RemoveMember(ctx context.Context, householdID, userID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM household_members WHERE household_id = $1 AND user_id = $2`, householdID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CreateInvitation(ctx context.Context, inv *domain.Invitation) error {
+	query := `
+		INSERT INTO household_invitations (token, household_id, role, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, inv.Token, inv.HouseholdID, inv.Role, inv.CreatedBy, inv.CreatedAt, inv.ExpiresAt)
+	return err
+}
+
+func (r *PostgresRepository) GetInvitation(ctx context.Context, token string) (*domain.Invitation, error) {
+	return r.getInvitation(ctx, r.db, token)
+}
+
+func (r *PostgresRepository) getInvitation(ctx context.Context, q queryer, token string) (*domain.Invitation, error) {
+	query := `
+		SELECT token, household_id, role, created_by, created_at, expires_at, COALESCE(accepted_by, '')
+		FROM household_invitations
+		WHERE token = $1
+	`
+
+	var inv domain.Invitation
+	err := q.QueryRowContext(ctx, query, token).Scan(
+		&inv.Token,
+		&inv.HouseholdID,
+		&inv.Role,
+		&inv.CreatedBy,
+		&inv.CreatedAt,
+		&inv.ExpiresAt,
+		&inv.AcceptedBy,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *PostgresRepository) AcceptInvitation(ctx context.Context, token, userID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	inv, err := r.getInvitation(ctx, tx, token)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if !inv.Usable(now) {
+		return ErrInvitationUnusable
+	}
+
+	// the guards keep a concurrent accept from using the invitation twice
+	res, err := tx.ExecContext(ctx, `
+		UPDATE household_invitations SET accepted_by = $1
+		WHERE token = $2 AND accepted_by IS NULL AND expires_at > $3
+	`, userID, token, now)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvitationUnusable
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO household_members (household_id, user_id, role, joined_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (household_id, user_id) DO NOTHING
+	`, inv.HouseholdID, userID, inv.Role, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) CreateShareLink(ctx context.Context, link *domain.ShareLink) error {
+	query := `
+		INSERT INTO share_links (id, session_id, household_id, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, link.ID, link.SessionID, link.HouseholdID, link.CreatedBy, link.CreatedAt, link.ExpiresAt)
+	return err
+}
+
+func (r *PostgresRepository) GetShareLink(ctx context.Context, id string) (*domain.ShareLink, error) {
+	query := `
+		SELECT id, session_id, household_id, created_by, created_at, expires_at, revoked_at
+		FROM share_links
+		WHERE id = $1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links, err := r.scanShareLinks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, ErrNotFound
+	}
+	return &links[0], nil
+}
+
+func (r *PostgresRepository) GetShareLinks(ctx context.Context, sessionID string) ([]domain.ShareLink, error) {
+	query := `
+		SELECT id, session_id, household_id, created_by, created_at, expires_at, revoked_at
+		FROM share_links
+		WHERE session_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanShareLinks(rows)
+}
+
+func (r *PostgresRepository) RevokeShareLink(ctx context.Context, id string, at time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE share_links SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`, at, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) scanShareLinks(rows *sql.Rows) ([]domain.ShareLink, error) {
+	var links []domain.ShareLink
+
+	for rows.Next() {
+		var link domain.ShareLink
+		var revokedAt sql.NullTime
+
+		err := rows.Scan(
+			&link.ID,
+			&link.SessionID,
+			&link.HouseholdID,
+			&link.CreatedBy,
+			&link.CreatedAt,
+			&link.ExpiresAt,
+			&revokedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if revokedAt.Valid {
+			link.RevokedAt = &revokedAt.Time
+		}
+
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+func (r *PostgresRepository) CreateReminder(ctx context.Context, reminder *domain.Reminder) error {
+	daysJSON, err := json.Marshal(reminder.Days)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO reminders (id, user_id, days_json, time_of_day, timezone, created_at, start_session)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err = r.db.ExecContext(ctx, query, reminder.ID, reminder.UserID, daysJSON, reminder.Time, reminder.Timezone, reminder.CreatedAt, reminder.StartSession)
+	return err
+}
+
+func (r *PostgresRepository) GetReminders(ctx context.Context, userID string) ([]domain.Reminder, error) {
+	query := `
+		SELECT id, user_id, days_json, time_of_day, timezone, created_at, last_fired_at, start_session
+		FROM reminders
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanReminders(rows)
+}
+
+func (r *PostgresRepository) GetAllReminders(ctx context.Context) ([]domain.Reminder, error) {
+	query := `
+		SELECT id, user_id, days_json, time_of_day, timezone, created_at, last_fired_at, start_session
+		FROM reminders
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanReminders(rows)
+}
+
+func (r *PostgresRepository) DeleteReminder(ctx context.Context, id, userID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM reminders WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) MarkReminderFired(ctx context.Context, id string, at time.Time) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE reminders SET last_fired_at = $1
+		WHERE id = $2 AND (last_fired_at IS NULL OR last_fired_at < $1)
+	`, at, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM reminders WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrAlreadyFired
+}
+
+func (r *PostgresRepository) scanReminders(rows *sql.Rows) ([]domain.Reminder, error) {
+	var reminders []domain.Reminder
+
+	for rows.Next() {
+		var reminder domain.Reminder
+		var daysJSON []byte
+		var lastFiredAt sql.NullTime
+
+		err := rows.Scan(
+			&reminder.ID,
+			&reminder.UserID,
+			&daysJSON,
+			&reminder.Time,
+			&reminder.Timezone,
+			&reminder.CreatedAt,
+			&lastFiredAt,
+			&reminder.StartSession,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(daysJSON, &reminder.Days); err != nil {
+			return nil, err
+		}
+		if lastFiredAt.Valid {
+			reminder.LastFiredAt = &lastFiredAt.Time
+		}
+
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
+
+func (r *PostgresRepository) SavePushSubscription(ctx context.Context, sub *domain.PushSubscription) error {
+	query := `
+		INSERT INTO push_subscriptions (endpoint, user_id, p256dh, auth, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (endpoint) DO UPDATE SET
+			user_id = excluded.user_id,
+			p256dh = excluded.p256dh,
+			auth = excluded.auth,
+			created_at = excluded.created_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query, sub.Endpoint, sub.UserID, sub.Keys.P256dh, sub.Keys.Auth, sub.CreatedAt)
+	return err
+}
+
+func (r *PostgresRepository) GetPushSubscriptions(ctx context.Context, userID string) ([]domain.PushSubscription, error) {
+	query := `
+		SELECT endpoint, user_id, p256dh, auth, created_at
+		FROM push_subscriptions
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []domain.PushSubscription
+	for rows.Next() {
+		var sub domain.PushSubscription
+		if err := rows.Scan(&sub.Endpoint, &sub.UserID, &sub.Keys.P256dh, &sub.Keys.Auth, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (r *PostgresRepository) DeletePushSubscription(ctx context.Context, userID, endpoint string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM push_subscriptions WHERE user_id = $1 AND endpoint = $2`, userID, endpoint)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CreateWebhook(ctx context.Context, w *domain.Webhook) error {
+	eventsJSON, err := json.Marshal(w.Events)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webhooks (id, user_id, url, events_json, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = r.db.ExecContext(ctx, query, w.ID, w.UserID, w.URL, eventsJSON, w.Secret, w.CreatedAt)
+	return err
+}
+
+func (r *PostgresRepository) GetWebhooks(ctx context.Context, userID string) ([]domain.Webhook, error) {
+	query := `
+		SELECT id, user_id, url, events_json, secret, created_at
+		FROM webhooks
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []domain.Webhook
+	for rows.Next() {
+		w, err := r.scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *w)
+	}
+	return hooks, rows.Err()
+}
+
+func (r *PostgresRepository) GetWebhook(ctx context.Context, id, userID string) (*domain.Webhook, error) {
+	query := `
+		SELECT id, user_id, url, events_json, secret, created_at
+		FROM webhooks
+		WHERE id = $1 AND user_id = $2
+	`
+
+	w, err := r.scanWebhook(r.db.QueryRowContext(ctx, query, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return w, err
+}
+
+func (r *PostgresRepository) DeleteWebhook(ctx context.Context, id, userID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) AddWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event, attempt, status_code, error, success, duration_ms, delivered_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, d.ID, d.WebhookID, d.EventID, d.Event, d.Attempt, d.StatusCode, d.Error, d.Success, d.DurationMs, d.DeliveredAt)
+	return err
+}
+
+func (r *PostgresRepository) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]domain.WebhookDelivery, error) {
+	query := `
+		SELECT id, webhook_id, event_id, event, attempt, status_code, error, success, duration_ms, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY delivered_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []domain.WebhookDelivery
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.Attempt, &d.StatusCode, &d.Error, &d.Success, &d.DurationMs, &d.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *PostgresRepository) scanWebhook(row rowScanner) (*domain.Webhook, error) {
+	var w domain.Webhook
+	var eventsJSON []byte
+
+	if err := row.Scan(&w.ID, &w.UserID, &w.URL, &eventsJSON, &w.Secret, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(eventsJSON, &w.Events); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *PostgresRepository) SaveCheckpoints(ctx context.Context, sessions []domain.Session) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO session_checkpoints (id, session_json, saved_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET session_json = excluded.session_json, saved_at = excluded.saved_at
+	`
+
+	now := time.Now()
+	for _, s := range sessions {
+		sessionJSON, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, s.ID, string(sessionJSON), now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) TakeCheckpoints(ctx context.Context) ([]domain.Session, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT session_json FROM session_checkpoints ORDER BY saved_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []domain.Session
+	for rows.Next() {
+		var sessionJSON []byte
+		if err := rows.Scan(&sessionJSON); err != nil {
+			return nil, err
+		}
+
+		var s domain.Session
+		if err := json.Unmarshal(sessionJSON, &s); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM session_checkpoints`); err != nil {
+		return nil, err
+	}
+
+	return sessions, tx.Commit()
+}
+
+func (r *PostgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
 func (r *PostgresRepository) Close() error {
@@ -1,23 +1,167 @@
 package storage
 
-import "time"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
 
+	"github.com/hperssn/hound/internal/domain"
+)
+
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrDuplicate          = errors.New("already exists")
+	ErrInvitationUnusable = errors.New("invitation expired or already used")
+	ErrAlreadyFired       = errors.New("reminder already fired")
+)
+
+// Repository persists sessions, households and share links. Methods take
+// the caller's context so tracing spans nest under the request.
 type Repository interface {
-	SaveSession(record *SessionRecord) error
+	// SaveSession returns ErrDuplicate and leaves the stored record
+	// untouched if a session with the same ID was saved before.
+	SaveSession(ctx context.Context, record *SessionRecord) error
+
+	GetSessionsByHousehold(ctx context.Context, householdID string) ([]SessionRecord, error)
+
+	GetRecentSessions(ctx context.Context, householdID string, since time.Time) ([]SessionRecord, error)
+
+	GetSessionStats(ctx context.Context, householdID string) (*SessionStats, error)
+
+	// GetSession returns ErrNotFound for unknown sessions. Deleted sessions
+	// are returned with DeletedAt set.
+	GetSession(ctx context.Context, id string) (*SessionRecord, error)
+
+	// UpdateSession applies edit and records the change in the session's
+	// audit trail. It returns ErrNotFound for unknown or deleted sessions.
+	UpdateSession(ctx context.Context, id string, edit SessionEdit, userID string, at time.Time) (*SessionRecord, error)
+
+	// DeleteSession hides a session from history and stats until it is
+	// restored. It returns ErrNotFound if the session is unknown or
+	// already deleted.
+	DeleteSession(ctx context.Context, id, userID string, at time.Time) error
+
+	// RestoreSession undoes DeleteSession. It returns ErrNotFound if the
+	// session is unknown or not deleted.
+	RestoreSession(ctx context.Context, id, userID string, at time.Time) error
+
+	// GetSessionChanges returns the session's audit trail, oldest first.
+	GetSessionChanges(ctx context.Context, id string) ([]SessionChange, error)
+
+	// CreateHousehold stores h with ownerID as its first owner. Creating a
+	// household whose ID already exists is a no-op.
+	CreateHousehold(ctx context.Context, h *domain.Household, ownerID string) error
+
+	GetHouseholdsForUser(ctx context.Context, userID string) ([]domain.Membership, error)
+
+	// GetMemberRole returns ErrNotFound if userID is not a member.
+	GetMemberRole(ctx context.Context, householdID, userID string) (domain.Role, error)
+
+	GetMembers(ctx context.Context, householdID string) ([]domain.Member, error)
+
+	// SetMemberRole adds userID to the household or changes their role.
+	SetMemberRole(ctx context.Context, householdID, userID string, role domain.Role) error
+
+	RemoveMember(ctx context.Context, householdID, userID string) error
+
+	CreateInvitation(ctx context.Context, inv *domain.Invitation) error
+
+	GetInvitation(ctx context.Context, token string) (*domain.Invitation, error)
+
+	// AcceptInvitation marks the invitation used and adds userID to the
+	// household. Existing members keep their current role.
+	AcceptInvitation(ctx context.Context, token, userID string) error
+
+	CreateShareLink(ctx context.Context, link *domain.ShareLink) error
+
+	// GetShareLink returns ErrNotFound for unknown links; revoked and
+	// expired links are returned as stored.
+	GetShareLink(ctx context.Context, id string) (*domain.ShareLink, error)
 
-	GetSessionsByUser(userID string) ([]SessionRecord, error)
+	GetShareLinks(ctx context.Context, sessionID string) ([]domain.ShareLink, error)
 
-	GetRecentSessions(userID string, since time.Time) ([]SessionRecord, error)
+	RevokeShareLink(ctx context.Context, id string, at time.Time) error
 
-	GetSessionStats(userID string) (*SessionStats, error)
+	CreateReminder(ctx context.Context, r *domain.Reminder) error
+
+	// GetReminders returns the user's reminders, oldest first.
+	GetReminders(ctx context.Context, userID string) ([]domain.Reminder, error)
+
+	// GetAllReminders returns every user's reminders for the scheduler.
+	GetAllReminders(ctx context.Context) ([]domain.Reminder, error)
+
+	// DeleteReminder returns ErrNotFound if the reminder does not exist or
+	// belongs to another user.
+	DeleteReminder(ctx context.Context, id, userID string) error
+
+	// MarkReminderFired records that the reminder fired for the occurrence
+	// at. It returns ErrAlreadyFired if it already fired for that occurrence
+	// or a later one, so each occurrence is sent once even across restarts,
+	// and ErrNotFound for unknown reminders.
+	MarkReminderFired(ctx context.Context, id string, at time.Time) error
+
+	// SavePushSubscription stores a browser's push subscription. Saving an
+	// endpoint again replaces it, including its user, since a browser that
+	// registers again under another login is that user's device now.
+	SavePushSubscription(ctx context.Context, sub *domain.PushSubscription) error
+
+	// GetPushSubscriptions returns the user's subscriptions, oldest first.
+	GetPushSubscriptions(ctx context.Context, userID string) ([]domain.PushSubscription, error)
+
+	// DeletePushSubscription returns ErrNotFound if the user has no
+	// subscription with that endpoint.
+	DeletePushSubscription(ctx context.Context, userID, endpoint string) error
+
+	CreateWebhook(ctx context.Context, w *domain.Webhook) error
+
+	// GetWebhooks returns the user's webhooks, oldest first.
+	GetWebhooks(ctx context.Context, userID string) ([]domain.Webhook, error)
+
+	// GetWebhook returns ErrNotFound if the webhook does not exist or
+	// belongs to another user.
+	GetWebhook(ctx context.Context, id, userID string) (*domain.Webhook, error)
+
+	// DeleteWebhook deletes the webhook with its delivery log. It returns
+	// ErrNotFound if the webhook does not exist or belongs to another user.
+	DeleteWebhook(ctx context.Context, id, userID string) error
+
+	AddWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error
+
+	// GetWebhookDeliveries returns up to limit of the webhook's latest
+	// delivery attempts, newest first.
+	GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]domain.WebhookDelivery, error)
+
+	// SaveCheckpoints stores in-flight sessions during shutdown, replacing
+	// earlier checkpoints of the same sessions.
+	SaveCheckpoints(ctx context.Context, sessions []domain.Session) error
+
+	// TakeCheckpoints returns and removes all stored checkpoints.
+	TakeCheckpoints(ctx context.Context) ([]domain.Session, error)
+
+	// Ping reports whether the database is reachable.
+	Ping(ctx context.Context) error
 
 	Close() error
 }
 
+// SessionStats summarizes the sessions that were trained to the end.
+// Abandoned sessions are only counted in AbandonedCount.
 type SessionStats struct {
 	TotalSessions   int     `json:"totalSessions"`
 	SuccessfulCount int     `json:"successfulCount"`
+	AbandonedCount  int     `json:"abandonedCount"`
 	AverageTarget   float64 `json:"averageTarget"`
 	TotalTrainTime  int     `json:"totalTrainTime"`
 	SuccessRate     float64 `json:"successRate"`
 }
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
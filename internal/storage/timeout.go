@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+)
+
+// timeout bounds every call to the wrapped repository, so a database that
+// stops answering fails requests instead of hanging them. The caller's
+// deadline still applies when it is shorter.
+type timeout struct {
+	repo Repository
+	d    time.Duration
+}
+
+// Timeout wraps repo so each method gives up after d.
+func Timeout(repo Repository, d time.Duration) Repository {
+	return &timeout{repo: repo, d: d}
+}
+
+func (t *timeout) SaveSession(ctx context.Context, record *SessionRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.SaveSession(ctx, record)
+}
+
+func (t *timeout) GetSessionsByHousehold(ctx context.Context, householdID string) ([]SessionRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetSessionsByHousehold(ctx, householdID)
+}
+
+func (t *timeout) GetRecentSessions(ctx context.Context, householdID string, since time.Time) ([]SessionRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetRecentSessions(ctx, householdID, since)
+}
+
+func (t *timeout) GetSessionStats(ctx context.Context, householdID string) (*SessionStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetSessionStats(ctx, householdID)
+}
+
+func (t *timeout) GetSession(ctx context.Context, id string) (*SessionRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetSession(ctx, id)
+}
+
+func (t *timeout) UpdateSession(ctx context.Context, id string, edit SessionEdit, userID string, at time.Time) (*SessionRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.UpdateSession(ctx, id, edit, userID, at)
+}
+
+func (t *timeout) DeleteSession(ctx context.Context, id, userID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.DeleteSession(ctx, id, userID, at)
+}
+
+func (t *timeout) RestoreSession(ctx context.Context, id, userID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.RestoreSession(ctx, id, userID, at)
+}
+
+func (t *timeout) GetSessionChanges(ctx context.Context, id string) ([]SessionChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetSessionChanges(ctx, id)
+}
+
+func (t *timeout) CreateHousehold(ctx context.Context, h *domain.Household, ownerID string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.CreateHousehold(ctx, h, ownerID)
+}
+
+func (t *timeout) GetHouseholdsForUser(ctx context.Context, userID string) ([]domain.Membership, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetHouseholdsForUser(ctx, userID)
+}
+
+func (t *timeout) GetMemberRole(ctx context.Context, householdID, userID string) (domain.Role, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetMemberRole(ctx, householdID, userID)
+}
+
+func (t *timeout) GetMembers(ctx context.Context, householdID string) ([]domain.Member, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetMembers(ctx, householdID)
+}
+
+func (t *timeout) SetMemberRole(ctx context.Context, householdID, userID string, role domain.Role) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.SetMemberRole(ctx, householdID, userID, role)
+}
+
+func (t *timeout) RemoveMember(ctx context.Context, householdID, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.RemoveMember(ctx, householdID, userID)
+}
+
+func (t *timeout) CreateInvitation(ctx context.Context, inv *domain.Invitation) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.CreateInvitation(ctx, inv)
+}
+
+func (t *timeout) GetInvitation(ctx context.Context, token string) (*domain.Invitation, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetInvitation(ctx, token)
+}
+
+func (t *timeout) AcceptInvitation(ctx context.Context, token, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.AcceptInvitation(ctx, token, userID)
+}
+
+func (t *timeout) CreateShareLink(ctx context.Context, link *domain.ShareLink) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.CreateShareLink(ctx, link)
+}
+
+func (t *timeout) GetShareLink(ctx context.Context, id string) (*domain.ShareLink, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetShareLink(ctx, id)
+}
+
+func (t *timeout) GetShareLinks(ctx context.Context, sessionID string) ([]domain.ShareLink, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetShareLinks(ctx, sessionID)
+}
+
+func (t *timeout) RevokeShareLink(ctx context.Context, id string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.RevokeShareLink(ctx, id, at)
+}
+
+func (t *timeout) CreateReminder(ctx context.Context, reminder *domain.Reminder) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.CreateReminder(ctx, reminder)
+}
+
+func (t *timeout) GetReminders(ctx context.Context, userID string) ([]domain.Reminder, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetReminders(ctx, userID)
+}
+
+func (t *timeout) GetAllReminders(ctx context.Context) ([]domain.Reminder, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetAllReminders(ctx)
+}
+
+func (t *timeout) DeleteReminder(ctx context.Context, id, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.DeleteReminder(ctx, id, userID)
+}
+
+func (t *timeout) MarkReminderFired(ctx context.Context, id string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.MarkReminderFired(ctx, id, at)
+}
+
+func (t *timeout) SavePushSubscription(ctx context.Context, sub *domain.PushSubscription) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.SavePushSubscription(ctx, sub)
+}
+
+func (t *timeout) GetPushSubscriptions(ctx context.Context, userID string) ([]domain.PushSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetPushSubscriptions(ctx, userID)
+}
+
+func (t *timeout) DeletePushSubscription(ctx context.Context, userID, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.DeletePushSubscription(ctx, userID, endpoint)
+}
+
+func (t *timeout) CreateWebhook(ctx context.Context, w *domain.Webhook) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.CreateWebhook(ctx, w)
+}
+
+func (t *timeout) GetWebhooks(ctx context.Context, userID string) ([]domain.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetWebhooks(ctx, userID)
+}
+
+func (t *timeout) GetWebhook(ctx context.Context, id, userID string) (*domain.Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetWebhook(ctx, id, userID)
+}
+
+func (t *timeout) DeleteWebhook(ctx context.Context, id, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.DeleteWebhook(ctx, id, userID)
+}
+
+func (t *timeout) AddWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.AddWebhookDelivery(ctx, d)
+}
+
+func (t *timeout) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]domain.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.GetWebhookDeliveries(ctx, webhookID, limit)
+}
+
+func (t *timeout) SaveCheckpoints(ctx context.Context, sessions []domain.Session) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.SaveCheckpoints(ctx, sessions)
+}
+
+func (t *timeout) TakeCheckpoints(ctx context.Context) ([]domain.Session, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.TakeCheckpoints(ctx)
+}
+
+func (t *timeout) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.repo.Ping(ctx)
+}
+
+func (t *timeout) Close() error {
+	return t.repo.Close()
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// hangingRepo blocks every Ping until the caller gives up.
+type hangingRepo struct {
+	Repository
+}
+
+func (hangingRepo) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestTimeoutBoundsCalls(t *testing.T) {
+	repo := Timeout(hangingRepo{}, 20*time.Millisecond)
+
+	start := time.Now()
+	err := repo.Ping(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("call took %v", elapsed)
+	}
+}
+
+func TestSQLiteHonoursCancellation(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(t.TempDir(), "hound.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := repo.GetMembers(ctx, "household"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context canceled", err)
+	}
+}
@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hperssn/hound/internal/domain"
+)
+
+// traced starts a span around every call to the wrapped repository.
+type traced struct {
+	repo   Repository
+	tracer trace.Tracer
+}
+
+// Trace wraps repo so each method runs in a child span of the caller's
+// context. ErrNotFound and ErrAlreadyFired are expected outcomes and do not
+// mark the span as failed.
+func Trace(repo Repository, tp trace.TracerProvider) Repository {
+	return &traced{repo: repo, tracer: tp.Tracer("github.com/hperssn/hound/internal/storage")}
+}
+
+func (t *traced) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "Repository."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.operation.name", method)),
+	)
+}
+
+func end(span trace.Span, err *error) {
+	if *err != nil && !errors.Is(*err, ErrNotFound) && !errors.Is(*err, ErrAlreadyFired) {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+func (t *traced) SaveSession(ctx context.Context, record *SessionRecord) (err error) {
+	ctx, span := t.start(ctx, "SaveSession")
+	defer end(span, &err)
+	return t.repo.SaveSession(ctx, record)
+}
+
+func (t *traced) GetSessionsByHousehold(ctx context.Context, householdID string) (_ []SessionRecord, err error) {
+	ctx, span := t.start(ctx, "GetSessionsByHousehold")
+	defer end(span, &err)
+	return t.repo.GetSessionsByHousehold(ctx, householdID)
+}
+
+func (t *traced) GetRecentSessions(ctx context.Context, householdID string, since time.Time) (_ []SessionRecord, err error) {
+	ctx, span := t.start(ctx, "GetRecentSessions")
+	defer end(span, &err)
+	return t.repo.GetRecentSessions(ctx, householdID, since)
+}
+
+func (t *traced) GetSessionStats(ctx context.Context, householdID string) (_ *SessionStats, err error) {
+	ctx, span := t.start(ctx, "GetSessionStats")
+	defer end(span, &err)
+	return t.repo.GetSessionStats(ctx, householdID)
+}
+
+func (t *traced) GetSession(ctx context.Context, id string) (_ *SessionRecord, err error) {
+	ctx, span := t.start(ctx, "GetSession")
+	defer end(span, &err)
+	return t.repo.GetSession(ctx, id)
+}
+
+func (t *traced) UpdateSession(ctx context.Context, id string, edit SessionEdit, userID string, at time.Time) (_ *SessionRecord, err error) {
+	ctx, span := t.start(ctx, "UpdateSession")
+	defer end(span, &err)
+	return t.repo.UpdateSession(ctx, id, edit, userID, at)
+}
+
+func (t *traced) DeleteSession(ctx context.Context, id, userID string, at time.Time) (err error) {
+	ctx, span := t.start(ctx, "DeleteSession")
+	defer end(span, &err)
+	return t.repo.DeleteSession(ctx, id, userID, at)
+}
+
+func (t *traced) RestoreSession(ctx context.Context, id, userID string, at time.Time) (err error) {
+	ctx, span := t.start(ctx, "RestoreSession")
+	defer end(span, &err)
+	return t.repo.RestoreSession(ctx, id, userID, at)
+}
+
+func (t *traced) GetSessionChanges(ctx context.Context, id string) (_ []SessionChange, err error) {
+	ctx, span := t.start(ctx, "GetSessionChanges")
+	defer end(span, &err)
+	return t.repo.GetSessionChanges(ctx, id)
+}
+
+func (t *traced) CreateHousehold(ctx context.Context, h *domain.Household, ownerID string) (err error) {
+	ctx, span := t.start(ctx, "CreateHousehold")
+	defer end(span, &err)
+	return t.repo.CreateHousehold(ctx, h, ownerID)
+}
+
+func (t *traced) GetHouseholdsForUser(ctx context.Context, userID string) (_ []domain.Membership, err error) {
+	ctx, span := t.start(ctx, "GetHouseholdsForUser")
+	defer end(span, &err)
+	return t.repo.GetHouseholdsForUser(ctx, userID)
+}
+
+func (t *traced) GetMemberRole(ctx context.Context, householdID, userID string) (_ domain.Role, err error) {
+	ctx, span := t.start(ctx, "GetMemberRole")
+	defer end(span, &err)
+	return t.repo.GetMemberRole(ctx, householdID, userID)
+}
+
+func (t *traced) GetMembers(ctx context.Context, householdID string) (_ []domain.Member, err error) {
+	ctx, span := t.start(ctx, "GetMembers")
+	defer end(span, &err)
+	return t.repo.GetMembers(ctx, householdID)
+}
+
+func (t *traced) SetMemberRole(ctx context.Context, householdID, userID string, role domain.Role) (err error) {
+	ctx, span := t.start(ctx, "SetMemberRole")
+	defer end(span, &err)
+	return t.repo.SetMemberRole(ctx, householdID, userID, role)
+}
+
+func (t *traced) RemoveMember(ctx context.Context, householdID, userID string) (err error) {
+	ctx, span := t.start(ctx, "RemoveMember")
+	defer end(span, &err)
+	return t.repo.RemoveMember(ctx, householdID, userID)
+}
+
+func (t *traced) CreateInvitation(ctx context.Context, inv *domain.Invitation) (err error) {
+	ctx, span := t.start(ctx, "CreateInvitation")
+	defer end(span, &err)
+	return t.repo.CreateInvitation(ctx, inv)
+}
+
+func (t *traced) GetInvitation(ctx context.Context, token string) (_ *domain.Invitation, err error) {
+	ctx, span := t.start(ctx, "GetInvitation")
+	defer end(span, &err)
+	return t.repo.GetInvitation(ctx, token)
+}
+
+func (t *traced) AcceptInvitation(ctx context.Context, token, userID string) (err error) {
+	ctx, span := t.start(ctx, "AcceptInvitation")
+	defer end(span, &err)
+	return t.repo.AcceptInvitation(ctx, token, userID)
+}
+
+func (t *traced) CreateShareLink(ctx context.Context, link *domain.ShareLink) (err error) {
+	ctx, span := t.start(ctx, "CreateShareLink")
+	defer end(span, &err)
+	return t.repo.CreateShareLink(ctx, link)
+}
+
+func (t *traced) GetShareLink(ctx context.Context, id string) (_ *domain.ShareLink, err error) {
+	ctx, span := t.start(ctx, "GetShareLink")
+	defer end(span, &err)
+	return t.repo.GetShareLink(ctx, id)
+}
+
+func (t *traced) GetShareLinks(ctx context.Context, sessionID string) (_ []domain.ShareLink, err error) {
+	ctx, span := t.start(ctx, "GetShareLinks")
+	defer end(span, &err)
+	return t.repo.GetShareLinks(ctx, sessionID)
+}
+
+func (t *traced) RevokeShareLink(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, span := t.start(ctx, "RevokeShareLink")
+	defer end(span, &err)
+	return t.repo.RevokeShareLink(ctx, id, at)
+}
+
+func (t *traced) CreateReminder(ctx context.Context, reminder *domain.Reminder) (err error) {
+	ctx, span := t.start(ctx, "CreateReminder")
+	defer end(span, &err)
+	return t.repo.CreateReminder(ctx, reminder)
+}
+
+func (t *traced) GetReminders(ctx context.Context, userID string) (_ []domain.Reminder, err error) {
+	ctx, span := t.start(ctx, "GetReminders")
+	defer end(span, &err)
+	return t.repo.GetReminders(ctx, userID)
+}
+
+func (t *traced) GetAllReminders(ctx context.Context) (_ []domain.Reminder, err error) {
+	ctx, span := t.start(ctx, "GetAllReminders")
+	defer end(span, &err)
+	return t.repo.GetAllReminders(ctx)
+}
+
+func (t *traced) DeleteReminder(ctx context.Context, id, userID string) (err error) {
+	ctx, span := t.start(ctx, "DeleteReminder")
+	defer end(span, &err)
+	return t.repo.DeleteReminder(ctx, id, userID)
+}
+
+func (t *traced) MarkReminderFired(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, span := t.start(ctx, "MarkReminderFired")
+	defer end(span, &err)
+	return t.repo.MarkReminderFired(ctx, id, at)
+}
+
+func (t *traced) SavePushSubscription(ctx context.Context, sub *domain.PushSubscription) (err error) {
+	ctx, span := t.start(ctx, "SavePushSubscription")
+	defer end(span, &err)
+	return t.repo.SavePushSubscription(ctx, sub)
+}
+
+func (t *traced) GetPushSubscriptions(ctx context.Context, userID string) (_ []domain.PushSubscription, err error) {
+	ctx, span := t.start(ctx, "GetPushSubscriptions")
+	defer end(span, &err)
+	return t.repo.GetPushSubscriptions(ctx, userID)
+}
+
+func (t *traced) DeletePushSubscription(ctx context.Context, userID, endpoint string) (err error) {
+	ctx, span := t.start(ctx, "DeletePushSubscription")
+	defer end(span, &err)
+	return t.repo.DeletePushSubscription(ctx, userID, endpoint)
+}
+
+func (t *traced) CreateWebhook(ctx context.Context, w *domain.Webhook) (err error) {
+	ctx, span := t.start(ctx, "CreateWebhook")
+	defer end(span, &err)
+	return t.repo.CreateWebhook(ctx, w)
+}
+
+func (t *traced) GetWebhooks(ctx context.Context, userID string) (_ []domain.Webhook, err error) {
+	ctx, span := t.start(ctx, "GetWebhooks")
+	defer end(span, &err)
+	return t.repo.GetWebhooks(ctx, userID)
+}
+
+func (t *traced) GetWebhook(ctx context.Context, id, userID string) (_ *domain.Webhook, err error) {
+	ctx, span := t.start(ctx, "GetWebhook")
+	defer end(span, &err)
+	return t.repo.GetWebhook(ctx, id, userID)
+}
+
+func (t *traced) DeleteWebhook(ctx context.Context, id, userID string) (err error) {
+	ctx, span := t.start(ctx, "DeleteWebhook")
+	defer end(span, &err)
+	return t.repo.DeleteWebhook(ctx, id, userID)
+}
+
+func (t *traced) AddWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) (err error) {
+	ctx, span := t.start(ctx, "AddWebhookDelivery")
+	defer end(span, &err)
+	return t.repo.AddWebhookDelivery(ctx, d)
+}
+
+func (t *traced) GetWebhookDeliveries(ctx context.Context, webhookID string, limit int) (_ []domain.WebhookDelivery, err error) {
+	ctx, span := t.start(ctx, "GetWebhookDeliveries")
+	defer end(span, &err)
+	return t.repo.GetWebhookDeliveries(ctx, webhookID, limit)
+}
+
+func (t *traced) SaveCheckpoints(ctx context.Context, sessions []domain.Session) (err error) {
+	ctx, span := t.start(ctx, "SaveCheckpoints")
+	defer end(span, &err)
+	return t.repo.SaveCheckpoints(ctx, sessions)
+}
+
+func (t *traced) TakeCheckpoints(ctx context.Context) (_ []domain.Session, err error) {
+	ctx, span := t.start(ctx, "TakeCheckpoints")
+	defer end(span, &err)
+	return t.repo.TakeCheckpoints(ctx)
+}
+
+func (t *traced) Ping(ctx context.Context) (err error) {
+	ctx, span := t.start(ctx, "Ping")
+	defer end(span, &err)
+	return t.repo.Ping(ctx)
+}
+
+func (t *traced) Close() error {
+	return t.repo.Close()
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/hperssn/hound/internal/config"
+)
+
+func TestNewProviderDisabled(t *testing.T) {
+	tp, shutdown, err := NewProvider(context.Background(), config.TracingConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tp.(noop.TracerProvider); !ok {
+		t.Fatalf("provider = %T, want no-op", tp)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewProviderOTLP(t *testing.T) {
+	tp, shutdown, err := NewProvider(context.Background(), config.TracingConfig{
+		Endpoint:    "http://localhost:4318",
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tp.(*sdktrace.TracerProvider); !ok {
+		t.Fatalf("provider = %T, want SDK provider", tp)
+	}
+
+	// nothing was recorded, so shutting down needs no collector
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,387 @@
+// Package webhook posts session lifecycle events to the URLs users have
+// registered, signed with each webhook's secret and retried with
+// exponential backoff. Every attempt is written to the delivery log.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/storage"
+)
+
+// storeTimeout bounds the store calls made outside of a request.
+const storeTimeout = 5 * time.Second
+
+// Payload is the JSON body posted to webhooks.
+type Payload struct {
+	// ID identifies the event and is repeated in DeliveryHeader.
+	ID        string              `json:"id"`
+	Event     domain.WebhookEvent `json:"event"`
+	CreatedAt time.Time           `json:"createdAt"`
+	Data      any                 `json:"data"`
+}
+
+// SessionData is the data of session.started and session.completed.
+type SessionData struct {
+	Session domain.Session `json:"session"`
+}
+
+// StepData is the data of step.started and step.completed.
+type StepData struct {
+	Session domain.Session `json:"session"`
+	Step    int            `json:"step"`
+}
+
+// SavedData is the data of session.saved.
+type SavedData struct {
+	Session storage.SessionRecord `json:"session"`
+}
+
+// Store is the part of storage.Repository the dispatcher needs.
+type Store interface {
+	GetWebhooks(ctx context.Context, userID string) ([]domain.Webhook, error)
+	AddWebhookDelivery(ctx context.Context, d *domain.WebhookDelivery) error
+}
+
+// Dispatcher delivers events to webhooks in the background. It is a
+// runner.Notifier for session and step events; saved sessions are reported
+// with SessionSaved.
+type Dispatcher struct {
+	store       Store
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	allowed     []netip.Prefix
+	log         *slog.Logger
+
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+type Option func(*Dispatcher)
+
+func WithClient(c *http.Client) Option {
+	return func(d *Dispatcher) { d.client = c }
+}
+
+// WithRetries sets how many attempts are made to deliver an event and the
+// wait before the first retry, which doubles with each further retry up to
+// ten minutes.
+func WithRetries(maxAttempts int, backoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+		d.backoff = backoff
+	}
+}
+
+func WithLogger(l *slog.Logger) Option {
+	return func(d *Dispatcher) { d.log = l }
+}
+
+// WithAllowedNetworks lets webhooks post to the given private networks,
+// such as the LAN a home automation server is on. Other targets must be
+// public addresses.
+func WithAllowedNetworks(prefixes []netip.Prefix) Option {
+	return func(d *Dispatcher) { d.allowed = prefixes }
+}
+
+func New(store Store, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:       store,
+		maxAttempts: 5,
+		backoff:     10 * time.Second,
+		maxBackoff:  10 * time.Minute,
+		log:         slog.Default(),
+		stop:        make(chan struct{}),
+	}
+	d.client = &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: d.transport(),
+		// a redirect would turn the POST into a GET; report it instead
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// transport refuses connections to addresses webhooks may not post to, so
+// users cannot reach the server's own network through them.
+func (d *Dispatcher) transport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !d.permits(addr.Addr()) {
+				return fmt.Errorf("webhook target %s is not a public address", addr.Addr())
+			}
+			return nil
+		},
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be the only address checked
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+func (d *Dispatcher) permits(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return domain.PublicAddr(addr) || slices.ContainsFunc(d.allowed, func(p netip.Prefix) bool {
+		return p.Contains(addr)
+	})
+}
+
+// CheckURL reports an error if webhooks may not post to target. Only hosts
+// given as addresses are checked; names are checked when connecting.
+func (d *Dispatcher) CheckURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		host = "127.0.0.1"
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !d.permits(addr) {
+		return errors.New("url must be on a public host")
+	}
+	return nil
+}
+
+func (d *Dispatcher) SessionStarted(ctx context.Context, s domain.Session) {
+	d.Dispatch(ctx, s.UserID, domain.WebhookSessionStarted, SessionData{Session: s})
+}
+
+func (d *Dispatcher) StepStarted(ctx context.Context, s domain.Session, idx int) {
+	d.Dispatch(ctx, s.UserID, domain.WebhookStepStarted, StepData{Session: s, Step: idx})
+}
+
+func (d *Dispatcher) StepCompleted(ctx context.Context, s domain.Session, idx int) {
+	d.Dispatch(ctx, s.UserID, domain.WebhookStepCompleted, StepData{Session: s, Step: idx})
+}
+
+func (d *Dispatcher) SessionCompleted(ctx context.Context, s domain.Session) {
+	d.Dispatch(ctx, s.UserID, domain.WebhookSessionCompleted, SessionData{Session: s})
+}
+
+func (d *Dispatcher) SessionSaved(ctx context.Context, rec storage.SessionRecord) {
+	d.Dispatch(ctx, rec.UserID, domain.WebhookSessionSaved, SavedData{Session: rec})
+}
+
+// Dispatch sends event to each of the user's webhooks subscribed to it. It
+// returns once the deliveries are queued; failures are logged.
+func (d *Dispatcher) Dispatch(ctx context.Context, userID string, event domain.WebhookEvent, data any) {
+	hooks, err := d.store.GetWebhooks(ctx, userID)
+	if err != nil {
+		d.log.Error("failed to load webhooks", "user_id", userID, "event", event, "err", err)
+		return
+	}
+
+	var body []byte
+	var eventID string
+	for _, h := range hooks {
+		if !h.Subscribes(event) {
+			continue
+		}
+		if body == nil {
+			eventID = uuid.New().String()
+			body, err = json.Marshal(Payload{ID: eventID, Event: event, CreatedAt: time.Now(), Data: data})
+			if err != nil {
+				d.log.Error("failed to encode webhook payload", "event", event, "err", err)
+				return
+			}
+		}
+		if !d.start(func() { d.deliver(h, eventID, event, body) }) {
+			d.log.Warn("dropped webhook event during shutdown", "webhook_id", h.ID, "event", event)
+		}
+	}
+}
+
+// Test sends a ping to the webhook once, without retrying, and returns the
+// logged attempt.
+func (d *Dispatcher) Test(ctx context.Context, h domain.Webhook) (*domain.WebhookDelivery, error) {
+	eventID := uuid.New().String()
+	body, err := json.Marshal(Payload{
+		ID:        eventID,
+		Event:     domain.WebhookPing,
+		CreatedAt: time.Now(),
+		Data:      map[string]string{"webhookId": h.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := d.attempt(ctx, h, eventID, domain.WebhookPing, body, 1)
+	if err := d.store.AddWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Shutdown stops retrying and waits for attempts in flight to finish or
+// ctx to be done. Events still waiting for a retry are dropped.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.stop)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// start runs fn in the background unless the dispatcher is shut down.
+func (d *Dispatcher) start(fn func()) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return false
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// deliver attempts to deliver an event until the receiver accepts it, fails
+// in a way retrying will not fix, or the attempts run out.
+func (d *Dispatcher) deliver(h domain.Webhook, eventID string, event domain.WebhookEvent, body []byte) {
+	for attempt := 1; ; attempt++ {
+		delivery := d.attempt(context.Background(), h, eventID, event, body, attempt)
+		d.record(delivery)
+		if delivery.Success {
+			return
+		}
+
+		if !retryable(delivery) || attempt >= d.maxAttempts {
+			d.log.Warn("giving up on webhook delivery",
+				"webhook_id", h.ID,
+				"event", event,
+				"attempts", attempt,
+				"status", delivery.StatusCode,
+				"err", delivery.Error,
+			)
+			return
+		}
+
+		select {
+		case <-time.After(d.backoffFor(attempt)):
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// attempt posts body to the webhook once.
+func (d *Dispatcher) attempt(ctx context.Context, h domain.Webhook, eventID string, event domain.WebhookEvent, body []byte, n int) *domain.WebhookDelivery {
+	delivery := &domain.WebhookDelivery{
+		ID:        uuid.New().String(),
+		WebhookID: h.ID,
+		EventID:   eventID,
+		Event:     event,
+		Attempt:   n,
+	}
+
+	start := time.Now()
+	defer func() {
+		delivery.DurationMs = time.Since(start).Milliseconds()
+		delivery.DeliveredAt = start
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Hound-Webhook/1")
+	req.Header.Set(EventHeader, string(event))
+	req.Header.Set(DeliveryHeader, eventID)
+	req.Header.Set(SignatureHeader, Sign(h.Secret, start, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	// drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode <= 299
+	return delivery
+}
+
+func (d *Dispatcher) record(delivery *domain.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	if err := d.store.AddWebhookDelivery(ctx, delivery); err != nil {
+		d.log.Error("failed to log webhook delivery", "webhook_id", delivery.WebhookID, "err", err)
+	}
+}
+
+func (d *Dispatcher) backoffFor(attempt int) time.Duration {
+	wait := d.backoff
+	for i := 1; i < attempt && wait < d.maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.maxBackoff)
+}
+
+// retryable reports whether a failed attempt may succeed later: the
+// receiver could not be reached, had trouble, or asked to be called back.
+func retryable(d *domain.WebhookDelivery) bool {
+	switch {
+	case d.StatusCode == 0:
+		return true
+	case d.StatusCode >= 500:
+		return true
+	case d.StatusCode == http.StatusRequestTimeout || d.StatusCode == http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
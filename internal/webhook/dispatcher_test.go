@@ -0,0 +1,304 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hperssn/hound/internal/domain"
+	"github.com/hperssn/hound/internal/runner"
+	"github.com/hperssn/hound/internal/storage"
+	"github.com/hperssn/hound/internal/webhook"
+)
+
+// allowLoopback lets the dispatcher reach the test receivers.
+var allowLoopback = webhook.WithAllowedNetworks([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")})
+
+// receiver is a webhook endpoint answering with the queued status codes,
+// then 200.
+type receiver struct {
+	*httptest.Server
+	t      *testing.T
+	secret string
+
+	mu       sync.Mutex
+	statuses []int
+	received []webhook.Payload
+	ids      []string
+}
+
+func newReceiver(t *testing.T, secret string, statuses ...int) *receiver {
+	rc := &receiver{t: t, secret: secret, statuses: statuses}
+	rc.Server = httptest.NewServer(http.HandlerFunc(rc.handle))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := webhook.Verify(rc.secret, r.Header.Get(webhook.SignatureHeader), body, time.Now(), time.Minute); err != nil {
+		rc.t.Errorf("delivery signature: %v", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var p webhook.Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		rc.t.Errorf("delivery body: %v", err)
+	}
+	if r.Header.Get(webhook.EventHeader) != string(p.Event) || r.Header.Get(webhook.DeliveryHeader) != p.ID {
+		rc.t.Errorf("delivery headers %v do not match payload %+v", r.Header, p)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received = append(rc.received, p)
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) payloads() []webhook.Payload {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]webhook.Payload(nil), rc.received...)
+}
+
+func addWebhook(t *testing.T, repo storage.Repository, userID, url string, events ...domain.WebhookEvent) *domain.Webhook {
+	t.Helper()
+
+	h, err := domain.NewWebhook(userID, url, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateWebhook(context.Background(), h); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// waitDeliveries waits for n attempts to be logged for the webhook and
+// returns them oldest first.
+func waitDeliveries(t *testing.T, repo storage.Repository, webhookID string, n int) []domain.WebhookDelivery {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		deliveries, _ := repo.GetWebhookDeliveries(context.Background(), webhookID, 100)
+		if len(deliveries) >= n || time.Now().After(deadline) {
+			if len(deliveries) != n {
+				t.Fatalf("logged %d deliveries, want %d: %+v", len(deliveries), n, deliveries)
+			}
+			for i, j := 0, len(deliveries)-1; i < j; i, j = i+1, j-1 {
+				deliveries[i], deliveries[j] = deliveries[j], deliveries[i]
+			}
+			return deliveries
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	d := webhook.New(repo, allowLoopback, webhook.WithRetries(4, 20*time.Millisecond))
+	defer d.Shutdown(context.Background())
+
+	rc := newReceiver(t, "", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	h := addWebhook(t, repo, "alice", rc.URL)
+	rc.secret = h.Secret
+
+	session := domain.Session{ID: "s1", UserID: "alice", Steps: []domain.Step{{Index: 0, Duration: 60}}}
+	d.StepStarted(context.Background(), session, 0)
+
+	deliveries := waitDeliveries(t, repo, h.ID, 3)
+	for i, want := range []int{503, 429, 200} {
+		if deliveries[i].StatusCode != want || deliveries[i].Attempt != i+1 || deliveries[i].Success != (want == 200) {
+			t.Fatalf("attempt %d = %+v, want status %d", i+1, deliveries[i], want)
+		}
+		if deliveries[i].EventID != deliveries[0].EventID || deliveries[i].Event != domain.WebhookStepStarted {
+			t.Fatalf("attempt %d = %+v, want a retry of the same event", i+1, deliveries[i])
+		}
+	}
+	if gap := deliveries[2].DeliveredAt.Sub(deliveries[1].DeliveredAt); gap < 40*time.Millisecond {
+		t.Fatalf("second retry came %v after the first, want the backoff doubled", gap)
+	}
+
+	p := rc.payloads()[2]
+	data := p.Data.(map[string]any)
+	if data["step"] != float64(0) || data["session"].(map[string]any)["ID"] != "s1" {
+		t.Fatalf("payload data = %+v", p.Data)
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	d := webhook.New(repo, allowLoopback, webhook.WithRetries(2, time.Millisecond))
+	defer d.Shutdown(context.Background())
+
+	rejecting := newReceiver(t, "", http.StatusBadRequest)
+	failing := newReceiver(t, "", 500, 500, 500)
+	hRejecting := addWebhook(t, repo, "alice", rejecting.URL)
+	hFailing := addWebhook(t, repo, "alice", failing.URL)
+	rejecting.secret, failing.secret = hRejecting.Secret, hFailing.Secret
+
+	d.SessionStarted(context.Background(), domain.Session{ID: "s1", UserID: "alice"})
+
+	// a receiver rejecting the event will not accept it later
+	waitDeliveries(t, repo, hRejecting.ID, 1)
+	waitDeliveries(t, repo, hFailing.ID, 2)
+}
+
+func TestDispatcherFiltersEvents(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	d := webhook.New(repo, allowLoopback)
+	defer d.Shutdown(context.Background())
+
+	rc := newReceiver(t, "")
+	lights := addWebhook(t, repo, "alice", rc.URL, domain.WebhookStepCompleted)
+	rc.secret = lights.Secret
+	other := newReceiver(t, "")
+	bobs := addWebhook(t, repo, "bob", other.URL)
+	other.secret = bobs.Secret
+
+	ctx := context.Background()
+	session := domain.Session{ID: "s1", UserID: "alice"}
+	d.SessionStarted(ctx, session)
+	d.StepStarted(ctx, session, 0)
+	d.StepCompleted(ctx, session, 0)
+	d.SessionSaved(ctx, storage.SessionRecord{ID: "s1", UserID: "alice"})
+
+	waitDeliveries(t, repo, lights.ID, 1)
+	if p := rc.payloads(); len(p) != 1 || p[0].Event != domain.WebhookStepCompleted {
+		t.Fatalf("lights received %+v, want only step.completed", p)
+	}
+	if p := other.payloads(); len(p) != 0 {
+		t.Fatalf("bob's webhook received %+v, want nothing", p)
+	}
+}
+
+func TestDispatcherTest(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	d := webhook.New(repo, allowLoopback, webhook.WithRetries(3, time.Millisecond))
+	defer d.Shutdown(context.Background())
+
+	rc := newReceiver(t, "", http.StatusBadGateway)
+	h := addWebhook(t, repo, "alice", rc.URL, domain.WebhookSessionSaved)
+	rc.secret = h.Secret
+
+	delivery, err := d.Test(context.Background(), *h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Success || delivery.StatusCode != http.StatusBadGateway || delivery.Event != domain.WebhookPing {
+		t.Fatalf("test delivery = %+v, want a failed ping", delivery)
+	}
+	// test-firing is not retried
+	time.Sleep(20 * time.Millisecond)
+	waitDeliveries(t, repo, h.ID, 1)
+
+	if delivery, _ := d.Test(context.Background(), *h); !delivery.Success {
+		t.Fatalf("second test delivery = %+v, want success", delivery)
+	}
+}
+
+func TestDispatcherShutdownDropsRetries(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	d := webhook.New(repo, allowLoopback, webhook.WithRetries(5, time.Hour))
+
+	rc := newReceiver(t, "", http.StatusServiceUnavailable)
+	h := addWebhook(t, repo, "alice", rc.URL)
+	rc.secret = h.Secret
+
+	d.SessionStarted(context.Background(), domain.Session{ID: "s1", UserID: "alice"})
+	waitDeliveries(t, repo, h.ID, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown waiting on a retry: %v", err)
+	}
+
+	d.SessionStarted(context.Background(), domain.Session{ID: "s2", UserID: "alice"})
+	time.Sleep(20 * time.Millisecond)
+	if p := rc.payloads(); len(p) != 1 {
+		t.Fatalf("received %+v after shutdown, want only the first attempt", p)
+	}
+}
+
+func TestDispatcherSessionCompletedByStoppedStep(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	d := webhook.New(repo, allowLoopback)
+	defer d.Shutdown(context.Background())
+
+	rc := newReceiver(t, "")
+	h := addWebhook(t, repo, "alice", rc.URL, domain.WebhookSessionCompleted)
+	rc.secret = h.Secret
+
+	manager := runner.NewSessionManager(runner.WithNotifier(d))
+	ctx := context.Background()
+	session := &domain.Session{ID: "s1", UserID: "alice", HouseholdID: "alice", Steps: []domain.Step{{Index: 0, Duration: 600}}}
+	if err := manager.StartSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StartStep(ctx, session.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StopStep(ctx, session.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := waitDeliveries(t, repo, h.ID, 1)[0]
+	if delivery.Event != domain.WebhookSessionCompleted || !delivery.Success {
+		t.Fatalf("delivery = %+v, want session.completed", delivery)
+	}
+	data := rc.payloads()[0].Data.(map[string]any)["session"].(map[string]any)
+	if data["ID"] != "s1" || data["State"] != string(domain.StateCompleted) {
+		t.Fatalf("payload session = %+v, want s1 completed", data)
+	}
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	d := webhook.New(repo, webhook.WithAllowedNetworks([]netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")}))
+	defer d.Shutdown(context.Background())
+
+	rc := newReceiver(t, "")
+	// a name is only checked once it is resolved
+	h := addWebhook(t, repo, "alice", strings.Replace(rc.URL, "127.0.0.1", "localhost", 1))
+	rc.secret = h.Secret
+
+	delivery, err := d.Test(context.Background(), *h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Success || delivery.StatusCode != 0 || !strings.Contains(delivery.Error, "not a public address") {
+		t.Fatalf("delivery = %+v, want a refused connection", delivery)
+	}
+	if p := rc.payloads(); len(p) != 0 {
+		t.Fatalf("receiver got %+v", p)
+	}
+
+	for target, ok := range map[string]bool{
+		"https://hooks.example/x":        true,
+		"http://192.168.1.20:8123/hook":  true,
+		"http://192.168.2.20:8123/hook":  false,
+		"http://169.254.169.254/latest":  false,
+		"http://[::1]:8080/":             false,
+		"http://localhost:8080/":         false,
+		"http://[::ffff:10.0.0.1]:8080/": false,
+		"https://93.184.215.14/webhook":  true,
+	} {
+		if err := d.CheckURL(target); (err == nil) != ok {
+			t.Errorf("CheckURL(%s) = %v, want allowed %v", target, err, ok)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers set on every delivery.
+const (
+	// SignatureHeader holds "t=<unix time>,v1=<hex HMAC-SHA256>", the HMAC
+	// being over "<unix time>.<body>" keyed with the webhook's secret.
+	SignatureHeader = "X-Hound-Signature"
+	EventHeader     = "X-Hound-Event"
+	// DeliveryHeader is the event ID, the same for every retry.
+	DeliveryHeader = "X-Hound-Delivery"
+)
+
+var ErrBadSignature = errors.New("invalid webhook signature")
+
+// Sign returns the SignatureHeader value for body sent at t. Signing the
+// time along with the body keeps a captured request from being replayed
+// later.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, mac(secret, ts, body))
+}
+
+// Verify checks a SignatureHeader value for body as a receiver would,
+// rejecting signatures made more than tolerance away from now.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrBadSignature
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrBadSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func mac(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
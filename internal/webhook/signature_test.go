@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"ping"}`)
+	header := Sign("secret", now, body)
+
+	// printf '1700000000.{"event":"ping"}' | openssl dgst -sha256 -hmac secret
+	const want = "t=1700000000,v1=4d39bd2442f073b6bc62e95d0297ce25475582a17389ab860abdc778fe1d9f77"
+	if header != want {
+		t.Fatalf("Sign = %q, want %q", header, want)
+	}
+
+	if err := Verify("secret", header, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Fatalf("Verify(own signature) = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   string
+		now    time.Time
+	}{
+		{"other secret", "other", header, string(body), now},
+		{"altered body", "secret", header, `{"event":"pong"}`, now},
+		{"replayed later", "secret", header, string(body), now.Add(time.Hour)},
+		{"malformed", "secret", "v1=abc", string(body), now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, []byte(tt.body), tt.now, 5*time.Minute); !errors.Is(err, ErrBadSignature) {
+				t.Fatalf("Verify = %v, want ErrBadSignature", err)
+			}
+		})
+	}
+}
@@ -6,9 +6,46 @@ let notificationsEnabled = false;
 if ("Notification" in window) {
     Notification.requestPermission().then(permission => {
         notificationsEnabled = permission === "granted";
+        if (notificationsEnabled) subscribeToPush();
     });
 }
 
+// subscribeToPush registers this device for Web Push, so steps finishing
+// are announced even after the tab is closed. Servers without a VAPID key
+// answer 404 and notifications stay tab-only.
+async function subscribeToPush() {
+    if (!("serviceWorker" in navigator) || !("PushManager" in window)) return;
+
+    try {
+        const res = await fetch('/push/key');
+        if (!res.ok) return;
+        const { publicKey } = await res.json();
+
+        const registration = await navigator.serviceWorker.register('/static/sw.js');
+        let subscription = await registration.pushManager.getSubscription();
+        if (!subscription) {
+            subscription = await registration.pushManager.subscribe({
+                userVisibleOnly: true,
+                applicationServerKey: base64UrlToBytes(publicKey)
+            });
+        }
+
+        await fetch('/push/subscriptions', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(subscription.toJSON())
+        });
+    } catch (err) {
+        console.error("Failed to subscribe to push notifications:", err);
+    }
+}
+
+function base64UrlToBytes(s) {
+    const base64 = s.replace(/-/g, '+').replace(/_/g, '/');
+    const raw = atob(base64 + '='.repeat((4 - base64.length % 4) % 4));
+    return Uint8Array.from(raw, c => c.charCodeAt(0));
+}
+
 function setActiveSession(id) {
     sessionId = id;
     if (id) {
@@ -34,7 +71,14 @@ document.addEventListener("DOMContentLoaded", () => {
 
 function hydrateTargetInput() {
     fetch('/next-target')
-        .then(res => res.json())
+        .then(res => {
+            if (res.status === 401) {
+                // OIDC mode: no valid session cookie yet
+                window.location.href = '/auth/login';
+                return null;
+            }
+            return res.json();
+        })
         .then(data => {
             if (!data || !data.nextTarget || data.nextTarget <= 0) return;
             document.getElementById("targetMin").value = formatTime(data.nextTarget);
@@ -159,6 +203,16 @@ async function startSession() {
             body.targetSec = targetSec;
         }
 
+        const restInput = document.getElementById("restSec").value;
+        if (restInput && restInput.trim() !== "") {
+            const restSec = parseInt(restInput);
+            if (isNaN(restSec) || restSec < 0) {
+                alert("Please enter a valid rest in seconds");
+                return;
+            }
+            body.autoAdvance = { restSec: restSec };
+        }
+
         const res = await fetch('/sessions', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
@@ -195,10 +249,17 @@ function displaySteps(steps) {
         div.id = `step-${step.Index}`;
         div.innerHTML = `
             <span class="step-label">Step ${step.Index + 1} - ${formatTime(step.Duration)}</span>
-	    <span class="step-timer" id="timer-${step.Index}">00:00</span>
+	    <span class="step-timer" id="timer-${step.Index}">${formatTime(step.Elapsed || 0)}</span>
             <div class="step-actions">
-                <button onclick="startStep(${step.Index})">Start</button>
-                <button onclick="stopStep(${step.Index})">Stop</button>
+                <button onclick="stepAction(${step.Index}, 'start')">Start</button>
+                <button onclick="stepAction(${step.Index}, 'pause')">Pause</button>
+                <button onclick="stepAction(${step.Index}, 'resume')">Resume</button>
+                <button onclick="stepAction(${step.Index}, 'stop')">Stop</button>
+            </div>
+            <div class="step-actions">
+                <button onclick="changeStepDuration(${step.Index})">Change</button>
+                <button onclick="insertStepBefore(${step.Index})">Add before</button>
+                <button onclick="removeStep(${step.Index})">Remove</button>
             </div>
         `;
         container.appendChild(div);
@@ -211,6 +272,32 @@ function connectToSession() {
     es = new EventSource(`/sessions/${sessionId}/events`);
     es.onmessage = (e) => {
         const data = JSON.parse(e.data);
+        if (data.type === "server_restarting") {
+            // the session is checkpointed; pick it up again once we're back
+            es.close();
+            document.getElementById("activeStep").textContent = "Server restarting, reconnecting...";
+            setTimeout(reconnectToSession, 5000);
+            return;
+        }
+        if (data.type === "rest_started") {
+            document.getElementById("activeStep").textContent =
+                `Rest ${formatTime(data.rest)} - step ${data.index + 1} starts next`;
+            notifyUser("Rest", `Step ${data.index + 1} starts in ${formatTime(data.rest)}`);
+            return;
+        }
+        if (data.type === "step_aborted") {
+            document.getElementById("activeStep").textContent =
+                `Step ${data.index + 1} was paused too long and has been abandoned`;
+            return;
+        }
+        if (data.type === "plan_changed") {
+            refreshSteps();
+            return;
+        }
+        if (data.type === "rest_ended") {
+            document.getElementById("activeStep").textContent = `Step ${data.index + 1}`;
+            return;
+        }
         if (data.index !== undefined && data.elapsed !== undefined) {
             const timerEl = document.getElementById(`timer-${data.index}`);
             if (timerEl) timerEl.textContent = formatTime(data.elapsed);
@@ -238,21 +325,77 @@ function connectToSession() {
     }
 }
 
-async function startStep(idx) {
+// action is start, pause, resume or stop. Stop ends the step early and
+// moves on to the next one.
+async function stepAction(idx, action) {
+    if (!sessionId) return;
+    try {
+        const res = await fetch(`/sessions/${sessionId}/steps/${idx}/${action}`, { method: "POST" });
+        if (!res.ok) {
+            const err = await res.json();
+            throw new Error(err.error || `failed to ${action} step`);
+        }
+    } catch (err) {
+        console.error(err);
+        document.getElementById("activeStep").textContent = err.message;
+    }
+}
+
+// Steps that have not started can be changed, added or removed while the
+// session runs.
+async function changeStepDuration(idx) {
+    const input = prompt("New duration (e.g., 0:30):", "");
+    if (!input) return;
+    const duration = parseTimeInput(input);
+    if (!duration || duration <= 0) {
+        alert("Please enter a valid duration");
+        return;
+    }
+    await editPlan(`/sessions/${sessionId}/steps/${idx}`, "PATCH", { duration: duration });
+}
+
+async function insertStepBefore(idx) {
+    const input = prompt("Duration of the new step (e.g., 0:30):", "");
+    if (!input) return;
+    const duration = parseTimeInput(input);
+    if (!duration || duration <= 0) {
+        alert("Please enter a valid duration");
+        return;
+    }
+    await editPlan(`/sessions/${sessionId}/steps`, "POST", { index: idx, duration: duration });
+}
+
+async function removeStep(idx) {
+    await editPlan(`/sessions/${sessionId}/steps/${idx}`, "DELETE");
+}
+
+async function editPlan(url, method, body) {
     if (!sessionId) return;
     try {
-        const res = await fetch(`/sessions/${sessionId}/steps/${idx}/start`, { method: "POST" });
-        if (!res.ok) throw new Error("failed to start step");
+        const options = { method: method };
+        if (body) {
+            options.headers = { "Content-Type": "application/json" };
+            options.body = JSON.stringify(body);
+        }
+        const res = await fetch(url, options);
+        if (!res.ok) {
+            const err = await res.json();
+            throw new Error(err.error || "failed to change the plan");
+        }
     } catch (err) {
         console.error(err);
+        document.getElementById("activeStep").textContent = err.message;
     }
 }
 
-async function stopStep(idx) {
+// refreshSteps reloads the plan after it was edited, here or elsewhere.
+async function refreshSteps() {
     if (!sessionId) return;
     try {
-        const res = await fetch(`/sessions/${sessionId}/steps/${idx}/stop`, { method: "POST" });
-        if (!res.ok) throw new Error("failed to stop step");
+        const res = await fetch(`/sessions/${sessionId}`);
+        if (!res.ok) return;
+        sessionData = await res.json();
+        displaySteps(sessionData.Steps);
     } catch (err) {
         console.error(err);
     }
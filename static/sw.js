@@ -0,0 +1,30 @@
+// Service worker showing the Web Push messages the server sends when steps
+// and sessions complete, or reminders come due.
+
+self.addEventListener("push", event => {
+    if (!event.data) return;
+    const msg = event.data.json();
+
+    // shares its tag with the in-page notifications, so a device with the
+    // tab open in the background shows one of them, not both
+    event.waitUntil(self.registration.showNotification(msg.title, {
+        body: msg.body,
+        icon: "/static/icon.png",
+        tag: msg.type === "reminder" ? "hound-reminder" : "hound-timer",
+        data: { sessionId: msg.sessionId }
+    }));
+});
+
+self.addEventListener("notificationclick", event => {
+    event.notification.close();
+
+    event.waitUntil((async () => {
+        const windows = await self.clients.matchAll({ type: "window", includeUncontrolled: true });
+        for (const client of windows) {
+            if (new URL(client.url).origin === self.location.origin) {
+                return client.focus();
+            }
+        }
+        return self.clients.openWindow("/");
+    })());
+});